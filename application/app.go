@@ -6,55 +6,171 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/SomeSuperCoder/global-chat/certs"
+	"github.com/SomeSuperCoder/global-chat/config"
+	"github.com/SomeSuperCoder/global-chat/handlers"
+	"github.com/SomeSuperCoder/global-chat/logging"
+	"github.com/SomeSuperCoder/global-chat/metrics"
+	"github.com/SomeSuperCoder/global-chat/repository"
+	"github.com/SomeSuperCoder/global-chat/repository/sqlstore"
+	"github.com/SomeSuperCoder/global-chat/tracing"
 	"go.mongodb.org/mongo-driver/v2/mongo"
-	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
 type App struct {
-	router http.Handler
+	config  *config.Config
+	router  http.Handler
+	stores  *repository.Stores
+	metrics *metrics.Metrics
+
+	// Only the connection of the configured backend is set
 	client *mongo.Client
 	db     *mongo.Database
+	sqlDB  *sqlstore.DB
 }
 
-func New() *App {
-	app := &App{}
+func New(cfg *config.Config) *App {
+	app := &App{
+		config:  cfg,
+		metrics: metrics.New(),
+	}
 
 	return app
 }
 
 func (a *App) Start(ctx context.Context) error {
-	var err error
-	// ========== MongoDB ==========
-	connectionString := "mongodb://localhost:27017"
-	a.client, err = mongo.Connect(options.Client().ApplyURI(connectionString))
+	// ========== Logging ==========
+	err := logging.Configure(logging.Config{
+		Format:        a.config.LogFormat,
+		Level:         a.config.LogLevel,
+		PackageLevels: a.config.LogLevels,
+	})
 	if err != nil {
-		return fmt.Errorf("failed to connect to MongoDB: %w", err)
+		return err
 	}
-	defer a.client.Disconnect(ctx)
 
-	// Ping MongoDB
-	timeoutCtx, cancel := context.WithTimeout(ctx, time.Second*3)
-	defer cancel()
-	err = a.client.Ping(timeoutCtx, nil)
+	// ========== Tracing ==========
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Config{
+		Exporter:    a.config.TraceExporter,
+		File:        a.config.TraceFile,
+		SampleRatio: a.config.TraceSampleRatio,
+	})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// Flush the spans that are still buffered
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(shutdownCtx); err != nil {
+			log.Errorf("Failed to flush traces: %v", err)
+		}
+	}()
+
+	// ========== Storage ==========
+	err = a.openStorage(ctx)
+	defer a.closeStorage(ctx)
+	if err != nil {
+		return err
+	}
+	if a.config.TraceExporter != tracing.ExporterNone {
+		a.stores = repository.Traced(a.stores)
+	}
+
+	// Pending migrations have been reported, nothing is served
+	if a.config.MigrateDryRun {
+		return nil
+	}
+
+	// ========== Registration policy ==========
+	pol, err := newPolicy(a.config)
+	if err != nil {
+		return err
+	}
+
+	hasher, err := newHasher(a.config)
+	if err != nil {
+		return err
+	}
+
+	// ========== Outgoing webhooks ==========
+	dispatcher, err := newDispatcher(a.config, a.stores)
 	if err != nil {
-		return fmt.Errorf("failed to ping MongoDB nor connect: %w", err)
+		return err
 	}
 
-	// Get the project database
-	a.db = a.client.Database("chat")
+	workerCtx, stopWorkers := context.WithCancel(ctx)
+	defer stopWorkers()
+	go dispatcher.Run(workerCtx)
 
 	// ========== Load Routes ==========
-	a.router = loadRoutes(a.db)
+	health := &handlers.HealthHandler{
+		Checks:  a.healthChecks(),
+		Timeout: a.config.ReadinessTimeout,
+	}
+	a.router = loadRoutes(a.stores, a.config, pol, hasher, dispatcher, a.metrics, health)
 
 	// ========== HTTP server ==========
 	server := &http.Server{
-		Addr:    ":8090",
+		Addr:    a.config.Addr,
 		Handler: a.router,
 	}
 
-	err = server.ListenAndServe()
-	if err != nil {
+	serveErr := make(chan error, 2)
+	if a.config.TLSCertFile == "" {
+		go func() {
+			serveErr <- server.ListenAndServe()
+		}()
+	} else {
+		var reloader *certs.Reloader
+		server.TLSConfig, reloader, err = newTLSConfig(a.config)
+		if err != nil {
+			return err
+		}
+		go watchCertificate(workerCtx, reloader, a.config.TLSReloadInterval)
+
+		server.Protocols = new(http.Protocols)
+		server.Protocols.SetHTTP1(true)
+		server.Protocols.SetHTTP2(true)
+
+		go func() {
+			// The certificate comes from the TLS config
+			serveErr <- server.ListenAndServeTLS("", "")
+		}()
+	}
+
+	var redirectServer *http.Server
+	if a.config.TLSRedirectAddr != "" {
+		redirectServer = &http.Server{
+			Addr:              a.config.TLSRedirectAddr,
+			Handler:           redirectToHTTPS(a.config.Addr),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		go func() {
+			serveErr <- redirectServer.ListenAndServe()
+		}()
+	}
+
+	select {
+	case err = <-serveErr:
 		return fmt.Errorf("failed to start server: %w", err)
+	case <-ctx.Done():
+	}
+
+	// ========== Shutdown ==========
+	// Keep serving while load balancers notice that readiness fails
+	log.Infof("Draining for %v", a.config.ShutdownDrain)
+	health.Drain()
+	time.Sleep(a.config.ShutdownDrain)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), a.config.ShutdownTimeout)
+	defer cancel()
+	if redirectServer != nil {
+		redirectServer.Close()
+	}
+	err = server.Shutdown(shutdownCtx)
+	if err != nil {
+		return fmt.Errorf("failed to shut down the server: %w", err)
 	}
 
 	return nil
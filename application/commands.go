@@ -0,0 +1,32 @@
+package application
+
+import (
+	"github.com/SomeSuperCoder/global-chat/commands"
+	"github.com/SomeSuperCoder/global-chat/handlers"
+	"github.com/SomeSuperCoder/global-chat/repository"
+)
+
+func newCommandRegistry(commandHandler *handlers.CommandHandler) *commands.Registry {
+	registry := commands.NewRegistry()
+	commandHandler.Register(registry)
+
+	// Custom commands are registered here, like
+	//
+	//	registry.MustRegister(commands.Command{
+	//		Name:        "roll",
+	//		Description: "Rolls a die",
+	//		Handler: func(call *commands.Call) (*commands.Result, error) {
+	//			return commands.Message(fmt.Sprintf("rolled a %d", rand.IntN(6)+1)), nil
+	//		},
+	//	})
+
+	return registry
+}
+
+func newCommandHandler(stores *repository.Stores) *handlers.CommandHandler {
+	return &handlers.CommandHandler{
+		Users:    stores.Users,
+		Settings: stores.Settings,
+		Audit:    stores.Audit,
+	}
+}
@@ -0,0 +1,70 @@
+package application
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/SomeSuperCoder/global-chat/handlers"
+	"github.com/SomeSuperCoder/global-chat/repository"
+	"github.com/SomeSuperCoder/global-chat/repository/postgres"
+	"github.com/SomeSuperCoder/global-chat/repository/sqlite"
+	"go.mongodb.org/mongo-driver/v2/mongo/readpref"
+)
+
+// The dependencies of the configured storage backend
+func (a *App) healthChecks() []handlers.HealthCheck {
+	switch a.config.Storage {
+	case StorageMongo:
+		return []handlers.HealthCheck{
+			{
+				Name: "mongo",
+				Run: func(ctx context.Context) error {
+					return a.client.Ping(ctx, readpref.Primary())
+				},
+			},
+			{
+				Name: "migrations",
+				Run: func(ctx context.Context) error {
+					pending, err := repository.PendingMigrations(ctx, a.db)
+					if err != nil {
+						return err
+					}
+					return pendingError(len(pending))
+				},
+			},
+		}
+	case StoragePostgres, StorageSQLite:
+		pendingMigrations := postgres.PendingMigrations
+		if a.config.Storage == StorageSQLite {
+			pendingMigrations = sqlite.PendingMigrations
+		}
+
+		return []handlers.HealthCheck{
+			{
+				Name: a.config.Storage,
+				Run: func(ctx context.Context) error {
+					return a.sqlDB.SQL.PingContext(ctx)
+				},
+			},
+			{
+				Name: "migrations",
+				Run: func(ctx context.Context) error {
+					pending, err := pendingMigrations(ctx, a.sqlDB)
+					if err != nil {
+						return err
+					}
+					return pendingError(len(pending))
+				},
+			},
+		}
+	default:
+		return nil
+	}
+}
+
+func pendingError(pending int) error {
+	if pending > 0 {
+		return fmt.Errorf("%d pending migrations", pending)
+	}
+	return nil
+}
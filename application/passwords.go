@@ -0,0 +1,39 @@
+package application
+
+import (
+	"fmt"
+
+	"github.com/SomeSuperCoder/global-chat/config"
+	"github.com/SomeSuperCoder/global-chat/passwords"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	HasherArgon2id = "argon2id"
+	HasherBcrypt   = "bcrypt"
+)
+
+// Both algorithms are always known, so hashes made before switching keep working
+func newHasher(cfg *config.Config) (*passwords.Manager, error) {
+	argon := passwords.DefaultArgon2id()
+	argon.Memory = uint32(cfg.Argon2Memory)
+	argon.Iterations = uint32(cfg.Argon2Iterations)
+	argon.Parallelism = uint8(cfg.Argon2Parallelism)
+	if cfg.Argon2Iterations < 1 || cfg.Argon2Parallelism < 1 || cfg.Argon2Parallelism > 255 || cfg.Argon2Memory < 8*cfg.Argon2Parallelism {
+		return nil, fmt.Errorf("invalid argon2id parameters m=%d,t=%d,p=%d", cfg.Argon2Memory, cfg.Argon2Iterations, cfg.Argon2Parallelism)
+	}
+
+	bc := &passwords.Bcrypt{Cost: cfg.BcryptCost}
+	if cfg.BcryptCost < bcrypt.MinCost || cfg.BcryptCost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("invalid bcrypt cost %d", cfg.BcryptCost)
+	}
+
+	switch cfg.PasswordHasher {
+	case HasherArgon2id:
+		return passwords.NewManager(argon, bc), nil
+	case HasherBcrypt:
+		return passwords.NewManager(bc, argon), nil
+	default:
+		return nil, fmt.Errorf("unknown password hasher %q", cfg.PasswordHasher)
+	}
+}
@@ -0,0 +1,37 @@
+package application
+
+import (
+	"fmt"
+
+	"github.com/SomeSuperCoder/global-chat/config"
+	"github.com/SomeSuperCoder/global-chat/policy"
+)
+
+func newPolicy(cfg *config.Config) (*policy.Policy, error) {
+	p := &policy.Policy{
+		UsernameMinLength:  cfg.UsernameMinLength,
+		UsernameMaxLength:  cfg.UsernameMaxLength,
+		UsernameCharset:    policy.Charset(cfg.UsernameCharset),
+		ReservedUsernames:  cfg.ReservedUsernames,
+		PasswordMinLength:  cfg.PasswordMinLength,
+		PasswordMaxLength:  cfg.PasswordMaxLength,
+		PasswordMinEntropy: cfg.PasswordMinEntropy,
+	}
+	if !p.UsernameCharset.Valid() {
+		return nil, fmt.Errorf("unknown username charset %q", cfg.UsernameCharset)
+	}
+
+	switch cfg.BreachedPasswordsPath {
+	case "off":
+	case "":
+		p.Breached = policy.DefaultBreached()
+	default:
+		var err error
+		p.Breached, err = policy.LoadBloomFilter(cfg.BreachedPasswordsPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load breached passwords: %w", err)
+		}
+	}
+
+	return p, nil
+}
@@ -0,0 +1,90 @@
+package application
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/SomeSuperCoder/global-chat/middleware"
+	"github.com/SomeSuperCoder/global-chat/models"
+	"github.com/SomeSuperCoder/global-chat/openapi"
+	"github.com/SomeSuperCoder/global-chat/rbac"
+	"github.com/SomeSuperCoder/global-chat/repository"
+)
+
+// router only registers routes that are in the OpenAPI document, and checks
+// their request bodies against it. Like http.ServeMux with a conflicting
+// pattern, it panics on an undocumented route, so every test and startup
+// that loads the routes fails until the document is updated.
+type router struct {
+	mux      *http.ServeMux
+	prefix   string
+	doc      *openapi.Document
+	sessions repository.SessionStore
+	// Patterns of the registered routes, for tests
+	patterns []string
+}
+
+// The prefix is the one the router is mounted at, "" for the root
+func newRouter(prefix string, doc *openapi.Document, sessions repository.SessionStore) *router {
+	return &router{
+		mux:      http.NewServeMux(),
+		prefix:   prefix,
+		doc:      doc,
+		sessions: sessions,
+	}
+}
+
+func (rt *router) Handle(pattern string, handler http.Handler) {
+	rt.mux.Handle(pattern, rt.doc.ValidateBody(rt.operation(pattern), handler))
+}
+
+func (rt *router) HandleFunc(pattern string, handler http.HandlerFunc) {
+	rt.Handle(pattern, handler)
+}
+
+// HandleAuth registers a route that needs a session or an API token with the
+// scope, and the permission unless it is empty. The body is only validated
+// once the caller is authorized, so strangers get a 401 and not a list of
+// what their body got wrong.
+func (rt *router) HandleAuth(pattern string, scope models.TokenScope, permission rbac.Permission, handler http.HandlerFunc) {
+	handler = rt.doc.ValidateBody(rt.operation(pattern), handler).ServeHTTP
+	if permission != "" {
+		handler = middleware.RequirePermission(handler, permission)
+	}
+	rt.mux.Handle(pattern, middleware.AuthMiddleware(handler, rt.sessions, scope))
+}
+
+// Panics if the route is missing from the document
+func (rt *router) operation(pattern string) *openapi.Operation {
+	fullPattern := rt.fullPattern(pattern)
+	operation, ok := rt.doc.Operation(fullPattern)
+	if !ok {
+		panic(fmt.Sprintf("route %q is missing from the OpenAPI document", fullPattern))
+	}
+
+	rt.patterns = append(rt.patterns, fullPattern)
+	return operation
+}
+
+// Mount adds a router for a whole prefix
+func (rt *router) Mount(prefix string, sub *router) {
+	rt.mux.Handle(prefix, sub.Handler())
+	rt.patterns = append(rt.patterns, sub.patterns...)
+}
+
+// Handler strips the prefix the router is mounted at
+func (rt *router) Handler() http.Handler {
+	if rt.prefix == "" {
+		return rt.mux
+	}
+	return http.StripPrefix(rt.prefix, middleware.RoutePattern(rt.prefix, rt.mux))
+}
+
+func (rt *router) fullPattern(pattern string) string {
+	method, path, ok := strings.Cut(pattern, " ")
+	if !ok {
+		return rt.prefix + pattern
+	}
+	return method + " " + rt.prefix + path
+}
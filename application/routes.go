@@ -4,53 +4,199 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/SomeSuperCoder/global-chat/config"
+	"github.com/SomeSuperCoder/global-chat/cookies"
 	"github.com/SomeSuperCoder/global-chat/handlers"
+	"github.com/SomeSuperCoder/global-chat/metrics"
 	"github.com/SomeSuperCoder/global-chat/middleware"
+	"github.com/SomeSuperCoder/global-chat/models"
+	"github.com/SomeSuperCoder/global-chat/openapi"
+	"github.com/SomeSuperCoder/global-chat/passwords"
+	"github.com/SomeSuperCoder/global-chat/policy"
+	"github.com/SomeSuperCoder/global-chat/ratelimit"
+	"github.com/SomeSuperCoder/global-chat/rbac"
 	"github.com/SomeSuperCoder/global-chat/repository"
-	"go.mongodb.org/mongo-driver/v2/mongo"
+	"github.com/SomeSuperCoder/global-chat/webhooks"
 )
 
-func loadRoutes(db *mongo.Database) http.Handler {
-	mux := http.NewServeMux()
+func loadRoutes(stores *repository.Stores, cfg *config.Config, pol *policy.Policy, hasher *passwords.Manager, events *webhooks.Dispatcher, m *metrics.Metrics, health *handlers.HealthHandler) http.Handler {
+	mux := loadRouter(stores, cfg, pol, hasher, events, m, health)
+
+	handler := middleware.RecoveryMiddleware(mux.Handler(), m, cfg.Debug)
+	if len(cfg.CORSOrigins) > 0 {
+		handler = middleware.CORSMiddleware(handler, middleware.CORSConfig{
+			AllowedOrigins:   cfg.CORSOrigins,
+			AllowCredentials: cfg.CORSCredentials,
+			ExposedHeaders:   cfg.CORSExposedHeaders,
+			MaxAge:           cfg.CORSMaxAge,
+		})
+	}
+	handler = middleware.CookieMiddleware(handler, newCookiePolicy(cfg))
+	handler = middleware.SecurityHeadersMiddleware(handler, middleware.SecurityConfig{
+		ContentSecurityPolicy: cfg.ContentSecurityPolicy,
+		FrameAncestors:        cfg.FrameAncestors,
+		ReferrerPolicy:        cfg.ReferrerPolicy,
+		HSTSMaxAge:            cfg.HSTSMaxAge,
+		HSTSIncludeSubdomains: cfg.HSTSIncludeSubdomains,
+	})
+	return middleware.LoggerMiddleware(middleware.TracingMiddleware(middleware.MetricsMiddleware(handler, m)))
+}
+
+// The routes without the middlewares that wrap all of them
+func loadRouter(stores *repository.Stores, cfg *config.Config, pol *policy.Policy, hasher *passwords.Manager, events *webhooks.Dispatcher, m *metrics.Metrics, health *handlers.HealthHandler) *router {
+	// Embedded, it only fails to load if it was edited badly
+	doc, err := openapi.Load()
+	if err != nil {
+		panic(err)
+	}
+	mux := newRouter("", doc, stores.Sessions)
 
 	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprintln(w, "OK")
 	})
-	mux.Handle("/auth/", loadAuthRoutes(db))
-	mux.Handle("/messages/", loadMessageRoutes(db))
+	mux.Handle("GET /openapi.json", openapi.Handler())
+	if health != nil {
+		mux.HandleFunc("GET /livez", health.Livez)
+		mux.HandleFunc("GET /readyz", health.Readyz)
+	}
+	if m != nil {
+		mux.Handle("GET /metrics", m.Handler())
+	}
+	mux.Mount("/auth/", loadAuthRoutes(stores, pol, hasher, doc))
+	mux.Mount("/messages/", loadMessageRoutes(stores, cfg, events, doc))
+	mux.Mount("/moderation/", loadModerationRoutes(stores, events, doc))
+	mux.Mount("/admin/", loadAdminRoutes(stores, cfg, pol, doc))
 
-	return middleware.LoggerMiddleware(mux)
+	// Authenticated by the token in the URL and the payload signature
+	webhookHandler := &handlers.WebhookHandler{
+		Repo:     stores.Webhooks,
+		Users:    stores.Users,
+		Messages: stores.Messages,
+		Events:   events,
+		Limiter:  ratelimit.New(),
+	}
+	mux.HandleFunc("POST /hooks/{token}", webhookHandler.ReceiveIncomingWebhook)
+
+	return mux
+}
+
+// config.Load has validated SameSite already
+func newCookiePolicy(cfg *config.Config) *cookies.Policy {
+	sameSite, ok := cookies.ParseSameSite(cfg.CookieSameSite)
+	if !ok {
+		sameSite = http.SameSiteLaxMode
+	}
+
+	return &cookies.Policy{
+		Secure:   cfg.CookieSecure,
+		SameSite: sameSite,
+		Domain:   cfg.CookieDomain,
+	}
 }
 
-func loadAuthRoutes(db *mongo.Database) http.Handler {
-	authMux := http.NewServeMux()
+func loadAuthRoutes(stores *repository.Stores, pol *policy.Policy, hasher *passwords.Manager, doc *openapi.Document) *router {
+	authMux := newRouter("/auth", doc, stores.Sessions)
+	tokenHandler := &handlers.TokenHandler{
+		Repo:  stores.Tokens,
+		Audit: stores.Audit,
+	}
 	authHandler := &handlers.UserHandler{
-		Repo: repository.UserRepo{
-			Database: db,
-		},
+		Repo:     stores.Users,
+		Sessions: stores.Sessions,
+		Audit:    stores.Audit,
+		Policy:   pol,
+		Hasher:   hasher,
 	}
 
 	authMux.HandleFunc("GET /{id}", authHandler.GetUser)
 	authMux.HandleFunc("GET /by-name/{username}", authHandler.GetUserByUsername)
 	authMux.HandleFunc("POST /register", authHandler.Register)
 	authMux.HandleFunc("POST /login", authHandler.Login)
-	authMux.HandleFunc("POST /logout", middleware.AuthMiddleware(authHandler.Logout, db))
+	authMux.HandleAuth("POST /logout", middleware.SessionOnly, "", authHandler.Logout)
+	authMux.HandleAuth("PATCH /{id}/role", models.ScopeAdmin, rbac.ManageUsers, authHandler.SetRole)
+
+	// Tokens are managed from a browser session only, so a leaked token cannot mint new ones
+	authMux.HandleAuth("GET /tokens", middleware.SessionOnly, "", tokenHandler.GetTokens)
+	authMux.HandleAuth("POST /tokens", middleware.SessionOnly, "", tokenHandler.CreateToken)
+	authMux.HandleAuth("DELETE /tokens/{id}", middleware.SessionOnly, "", tokenHandler.DeleteToken)
 
-	return http.StripPrefix("/auth", authMux)
+	return authMux
 }
 
-func loadMessageRoutes(db *mongo.Database) http.Handler {
-	messageMux := http.NewServeMux()
+func loadMessageRoutes(stores *repository.Stores, cfg *config.Config, events *webhooks.Dispatcher, doc *openapi.Document) *router {
+	messageMux := newRouter("/messages", doc, stores.Sessions)
+	commandHandler := newCommandHandler(stores)
 	messageHandler := &handlers.MessageHandler{
-		Repo: repository.MessageRepo{
-			Database: db,
-		},
+		Repo:                stores.Messages,
+		Reports:             stores.Reports,
+		Audit:               stores.Audit,
+		Events:              events,
+		Commands:            newCommandRegistry(commandHandler),
+		ReportHideThreshold: cfg.ReportHideThreshold,
+	}
+
+	messageMux.HandleAuth("GET /", models.ScopeReadMessages, "", messageHandler.GetMessages)
+	messageMux.HandleAuth("GET /topic", models.ScopeReadMessages, "", commandHandler.GetTopic)
+	messageMux.HandleAuth("POST /", models.ScopePostMessages, "", messageHandler.CreateMessage)
+	messageMux.HandleAuth("PATCH /{id}", models.ScopePostMessages, "", messageHandler.UpdateMessageText)
+	messageMux.HandleAuth("DELETE /{id}", models.ScopePostMessages, "", messageHandler.DeleteMessage)
+	messageMux.HandleAuth("POST /{id}/report", models.ScopePostMessages, "", messageHandler.ReportMessage)
+
+	return messageMux
+}
+
+func loadModerationRoutes(stores *repository.Stores, events *webhooks.Dispatcher, doc *openapi.Document) *router {
+	moderationMux := newRouter("/moderation", doc, stores.Sessions)
+	moderationHandler := &handlers.ModerationHandler{
+		Users:    stores.Users,
+		Messages: stores.Messages,
+		Reports:  stores.Reports,
+		Audit:    stores.Audit,
+		Events:   events,
 	}
 
-	messageMux.HandleFunc("GET /", middleware.AuthMiddleware(messageHandler.GetMessages, db))
-	messageMux.HandleFunc("POST /", middleware.AuthMiddleware(messageHandler.CreateMessage, db))
-	messageMux.HandleFunc("PATCH /{id}", middleware.AuthMiddleware(messageHandler.UpdateMessageText, db))
-	messageMux.HandleFunc("DELETE /{id}", middleware.AuthMiddleware(messageHandler.DeleteMessage, db))
+	moderationMux.HandleAuth("POST /users/{id}/ban", models.ScopeAdmin, rbac.ModerateUsers, moderationHandler.Ban)
+	moderationMux.HandleAuth("DELETE /users/{id}/ban", models.ScopeAdmin, rbac.ModerateUsers, moderationHandler.Unban)
+	moderationMux.HandleAuth("POST /users/{id}/mute", models.ScopeAdmin, rbac.ModerateUsers, moderationHandler.Mute)
+	moderationMux.HandleAuth("DELETE /users/{id}/mute", models.ScopeAdmin, rbac.ModerateUsers, moderationHandler.Unmute)
+	moderationMux.HandleAuth("POST /users/{id}/timeout", models.ScopeAdmin, rbac.ModerateUsers, moderationHandler.Timeout)
+	moderationMux.HandleAuth("GET /reports", models.ScopeAdmin, rbac.ModerateUsers, moderationHandler.GetReports)
+	moderationMux.HandleAuth("POST /reports/{id}/dismiss", models.ScopeAdmin, rbac.ModerateUsers, moderationHandler.DismissReports)
+	moderationMux.HandleAuth("POST /reports/{id}/delete", models.ScopeAdmin, rbac.ModerateUsers, moderationHandler.DeleteReportedMessage)
+	moderationMux.HandleAuth("POST /reports/{id}/sanction", models.ScopeAdmin, rbac.ModerateUsers, moderationHandler.SanctionReportedAuthor)
+
+	return moderationMux
+}
+
+func loadAdminRoutes(stores *repository.Stores, cfg *config.Config, pol *policy.Policy, doc *openapi.Document) *router {
+	adminMux := newRouter("/admin", doc, stores.Sessions)
+	auditHandler := &handlers.AuditHandler{
+		Repo: stores.Audit,
+	}
+	userHandler := &handlers.UserHandler{
+		Repo:   stores.Users,
+		Audit:  stores.Audit,
+		Policy: pol,
+	}
+	webhookHandler := &handlers.WebhookHandler{
+		Repo:             stores.Webhooks,
+		Deliveries:       stores.Deliveries,
+		Users:            stores.Users,
+		Audit:            stores.Audit,
+		DefaultRateLimit: cfg.WebhookRateLimit,
+	}
+
+	adminMux.HandleAuth("GET /audit", models.ScopeAdmin, rbac.ViewAuditLog, auditHandler.GetEvents)
+	adminMux.HandleAuth("POST /bots", models.ScopeAdmin, rbac.ManageBots, userHandler.CreateBot)
+	adminMux.HandleAuth("GET /webhooks", models.ScopeAdmin, rbac.ManageBots, webhookHandler.GetIncomingWebhooks)
+	adminMux.HandleAuth("POST /webhooks", models.ScopeAdmin, rbac.ManageBots, webhookHandler.CreateIncomingWebhook)
+	adminMux.HandleAuth("DELETE /webhooks/{id}", models.ScopeAdmin, rbac.ManageBots, webhookHandler.DeleteIncomingWebhook)
+
+	adminMux.HandleAuth("GET /webhooks/outgoing", models.ScopeAdmin, rbac.ManageWebhooks, webhookHandler.GetOutgoingWebhooks)
+	adminMux.HandleAuth("POST /webhooks/outgoing", models.ScopeAdmin, rbac.ManageWebhooks, webhookHandler.CreateOutgoingWebhook)
+	adminMux.HandleAuth("DELETE /webhooks/outgoing/{id}", models.ScopeAdmin, rbac.ManageWebhooks, webhookHandler.DeleteOutgoingWebhook)
+	adminMux.HandleAuth("GET /webhooks/outgoing/{id}/deliveries", models.ScopeAdmin, rbac.ManageWebhooks, webhookHandler.GetDeliveries)
+	adminMux.HandleAuth("POST /webhooks/deliveries/{id}/retry", models.ScopeAdmin, rbac.ManageWebhooks, webhookHandler.RetryDelivery)
 
-	return http.StripPrefix("/messages", messageMux)
+	return adminMux
 }
@@ -0,0 +1,147 @@
+package application
+
+import (
+	"context"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/SomeSuperCoder/global-chat/config"
+	"github.com/SomeSuperCoder/global-chat/handlers"
+	"github.com/SomeSuperCoder/global-chat/metrics"
+	"github.com/SomeSuperCoder/global-chat/middleware"
+	"github.com/SomeSuperCoder/global-chat/models"
+	"github.com/SomeSuperCoder/global-chat/openapi"
+	"github.com/SomeSuperCoder/global-chat/passwords"
+	"github.com/SomeSuperCoder/global-chat/policy"
+	"github.com/SomeSuperCoder/global-chat/repository"
+	"github.com/SomeSuperCoder/global-chat/repository/memory"
+)
+
+// What the routes of a memory backed app need
+func testDependencies(t *testing.T) (*repository.Stores, *config.Config, *policy.Policy, *passwords.Manager) {
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.PasswordHasher = "bcrypt"
+	cfg.BcryptCost = 4
+
+	pol, err := newPolicy(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hasher, err := newHasher(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return memory.NewStores(), cfg, pol, hasher
+}
+
+func TestRoutesMatchDocument(t *testing.T) {
+	// With every optional route enabled
+	stores, cfg, pol, hasher := testDependencies(t)
+	rt := loadRouter(stores, cfg, pol, hasher, nil, metrics.New(), &handlers.HealthHandler{})
+	doc, err := openapi.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, pattern := range rt.patterns {
+		if _, ok := doc.Operation(pattern); !ok {
+			t.Errorf("Route %q is not documented", pattern)
+		}
+	}
+
+	for path, operations := range doc.Paths {
+		for method := range operations {
+			pattern := strings.ToUpper(method) + " " + path
+			if !slices.Contains(rt.patterns, pattern) && !slices.Contains(rt.patterns, path) {
+				t.Errorf("Documented operation %q has no route", pattern)
+			}
+		}
+	}
+}
+
+func TestBodyValidatedAfterAuth(t *testing.T) {
+	stores, cfg, pol, hasher := testDependencies(t)
+	srv := httptest.NewServer(loadRoutes(stores, cfg, pol, hasher, nil, nil, nil))
+	defer srv.Close()
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Jar: jar}
+	csrf := ""
+
+	do := func(method, path, contentType, body string) int {
+		req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", contentType)
+		if csrf != "" {
+			req.Header.Set(middleware.CSRFTokenHeader, csrf)
+		}
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if token := res.Header.Get(middleware.CSRFTokenHeader); token != "" {
+			csrf = token
+		}
+		return res.StatusCode
+	}
+
+	userID := "0123456789abcdef01234567"
+	invalid := []struct{ method, path, body string }{
+		{http.MethodPost, "/messages/", `{"text": 5}`},
+		{http.MethodPost, "/moderation/users/" + userID + "/ban", `{}`},
+		{http.MethodPost, "/admin/webhooks/outgoing", `{"events": []}`},
+	}
+
+	for _, request := range invalid {
+		if code := do(request.method, request.path, "application/json", request.body); code != http.StatusUnauthorized {
+			t.Errorf("%s %s without a session: got %d, want 401", request.method, request.path, code)
+		}
+	}
+
+	form := url.Values{"username": {"validation_user"}, "password": {"Correct-Horse-42"}}.Encode()
+	if code := do(http.MethodPost, "/auth/register", "application/x-www-form-urlencoded", form); code != http.StatusOK {
+		t.Fatalf("Register: got %d", code)
+	}
+	if code := do(http.MethodPost, "/auth/login", "application/x-www-form-urlencoded", form); code != http.StatusOK {
+		t.Fatalf("Login: got %d", code)
+	}
+
+	// Authorized for the messages only
+	if code := do(invalid[0].method, invalid[0].path, "application/json", invalid[0].body); code != http.StatusUnprocessableEntity {
+		t.Errorf("%s %s with a session: got %d, want 422", invalid[0].method, invalid[0].path, code)
+	}
+	for _, request := range invalid[1:] {
+		if code := do(request.method, request.path, "application/json", request.body); code != http.StatusForbidden {
+			t.Errorf("%s %s without the permission: got %d, want 403", request.method, request.path, code)
+		}
+	}
+
+	user, err := stores.Users.GetUserByUsername(context.Background(), "validation_user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = stores.Users.SetRole(context.Background(), user.ID, models.RoleAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, request := range invalid[1:] {
+		if code := do(request.method, request.path, "application/json", request.body); code != http.StatusUnprocessableEntity {
+			t.Errorf("%s %s as an admin: got %d, want 422", request.method, request.path, code)
+		}
+	}
+}
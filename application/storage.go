@@ -0,0 +1,179 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/SomeSuperCoder/global-chat/logging"
+	"github.com/SomeSuperCoder/global-chat/repository"
+	"github.com/SomeSuperCoder/global-chat/repository/memory"
+	"github.com/SomeSuperCoder/global-chat/repository/postgres"
+	"github.com/SomeSuperCoder/global-chat/repository/sqlite"
+	"github.com/SomeSuperCoder/global-chat/repository/sqlstore"
+	"github.com/SomeSuperCoder/global-chat/tracing"
+	"go.mongodb.org/mongo-driver/v2/event"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+var log = logging.Package("application")
+
+const (
+	StorageSQLite   = "sqlite"
+	StorageMongo    = "mongo"
+	StoragePostgres = "postgres"
+	StorageMemory   = "memory"
+)
+
+func (a *App) openStorage(ctx context.Context) error {
+	switch a.config.Storage {
+	case StorageSQLite:
+		return a.openSQLite(ctx)
+	case StorageMongo:
+		return a.openMongo(ctx)
+	case StoragePostgres:
+		return a.openPostgres(ctx)
+	case StorageMemory:
+		a.stores = memory.NewStores()
+		return nil
+	default:
+		return fmt.Errorf("unknown storage backend %q", a.config.Storage)
+	}
+}
+
+func (a *App) closeStorage(ctx context.Context) {
+	if a.client != nil {
+		a.client.Disconnect(ctx)
+	}
+	if a.sqlDB != nil {
+		a.sqlDB.SQL.Close()
+	}
+}
+
+func (a *App) openMongo(ctx context.Context) error {
+	var err error
+	monitor := combineMonitors(a.metrics.MongoMonitor(), tracing.MongoMonitor())
+	opts := options.Client().ApplyURI(a.config.MongoURI).SetMonitor(monitor)
+	a.client, err = mongo.Connect(opts)
+	if err != nil {
+		return fmt.Errorf("failed to connect to MongoDB: %w", err)
+	}
+
+	// Ping MongoDB
+	timeoutCtx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+	err = a.client.Ping(timeoutCtx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to ping MongoDB nor connect: %w", err)
+	}
+
+	// Get the project database
+	a.db = a.client.Database(a.config.MongoDatabase)
+
+	// Indexes and validators
+	migrations, err := repository.Migrate(ctx, a.db, a.config.MigrateDryRun)
+	if err != nil {
+		return fmt.Errorf("failed to migrate MongoDB: %w", err)
+	}
+	for _, m := range migrations {
+		a.logMigration(fmt.Sprintf("%d (%s)", m.Version, m.Description))
+	}
+
+	a.stores = repository.NewMongoStores(a.db)
+
+	return nil
+}
+
+func (a *App) openPostgres(ctx context.Context) error {
+	var err error
+	a.sqlDB, err = postgres.Open(ctx, a.config.PostgresDSN)
+	if err != nil {
+		return fmt.Errorf("failed to connect to PostgreSQL: %w", err)
+	}
+
+	err = a.migrateSQL(ctx, postgres.PendingMigrations, postgres.Migrate)
+	if err != nil {
+		return fmt.Errorf("failed to migrate PostgreSQL: %w", err)
+	}
+
+	a.stores = postgres.NewStores(a.sqlDB)
+
+	return nil
+}
+
+func (a *App) openSQLite(ctx context.Context) error {
+	var err error
+	a.sqlDB, err = sqlite.Open(ctx, a.config.SQLitePath)
+	if err != nil {
+		return fmt.Errorf("failed to open SQLite database: %w", err)
+	}
+
+	err = a.migrateSQL(ctx, sqlite.PendingMigrations, sqlite.Migrate)
+	if err != nil {
+		return fmt.Errorf("failed to migrate SQLite: %w", err)
+	}
+
+	a.stores = sqlite.NewStores(a.sqlDB)
+
+	return nil
+}
+
+func (a *App) migrateSQL(
+	ctx context.Context,
+	pending func(context.Context, *sqlstore.DB) ([]string, error),
+	migrate func(context.Context, *sqlstore.DB) error,
+) error {
+	migrations, err := pending(ctx, a.sqlDB)
+	if err != nil {
+		return err
+	}
+
+	if !a.config.MigrateDryRun {
+		err = migrate(ctx, a.sqlDB)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, m := range migrations {
+		a.logMigration(m)
+	}
+
+	return nil
+}
+
+func (a *App) logMigration(name string) {
+	if a.config.MigrateDryRun {
+		log.Infof("Pending migration %s", name)
+		return
+	}
+	log.Infof("Applied migration %s", name)
+}
+
+// The driver takes a single monitor
+func combineMonitors(monitors ...*event.CommandMonitor) *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			for _, m := range monitors {
+				if m.Started != nil {
+					m.Started(ctx, e)
+				}
+			}
+		},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			for _, m := range monitors {
+				if m.Succeeded != nil {
+					m.Succeeded(ctx, e)
+				}
+			}
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			for _, m := range monitors {
+				if m.Failed != nil {
+					m.Failed(ctx, e)
+				}
+			}
+		},
+	}
+}
@@ -0,0 +1,88 @@
+package application
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/SomeSuperCoder/global-chat/certs"
+	"github.com/SomeSuperCoder/global-chat/config"
+)
+
+func newTLSConfig(cfg *config.Config) (*tls.Config, *certs.Reloader, error) {
+	minVersion, err := certs.ParseVersion(cfg.TLSMinVersion)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cipherSuites, err := certs.ParseCipherSuites(cfg.TLSCipherSuites)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reloader, err := certs.NewReloader(cfg.TLSCertFile, cfg.TLSKeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:     minVersion,
+		CipherSuites:   cipherSuites,
+		GetCertificate: reloader.GetCertificate,
+	}
+	return tlsConfig, reloader, nil
+}
+
+// Reloads the certificate when its files change and on SIGHUP, until the
+// context is done
+func watchCertificate(ctx context.Context, reloader *certs.Reloader, interval time.Duration) {
+	go reloader.Watch(ctx, interval)
+
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+		}
+
+		log.Info("Reloading the certificate on SIGHUP")
+		if err := reloader.Reload(); err != nil {
+			log.Errorf("Failed to reload the certificate, keeping the current one: %v", err)
+		}
+	}
+}
+
+// Sends plain HTTP requests to the same URL on the TLS listener
+func redirectToHTTPS(tlsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(tlsAddr)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host == "" {
+			http.Error(w, "Missing host", http.StatusBadRequest)
+			return
+		}
+
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = strings.Trim(r.Host, "[]")
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+
+		// 308 keeps the method and body
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
@@ -0,0 +1,102 @@
+package application
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/SomeSuperCoder/global-chat/certs"
+	"github.com/SomeSuperCoder/global-chat/config"
+)
+
+func TestRedirectToHTTPS(t *testing.T) {
+	tests := []struct {
+		tlsAddr, host, target string
+		status                int
+		location              string
+	}{
+		{":443", "chat.example.com", "/messages/?page=2", http.StatusPermanentRedirect, "https://chat.example.com/messages/?page=2"},
+		{":443", "chat.example.com:80", "/", http.StatusPermanentRedirect, "https://chat.example.com/"},
+		{":8443", "chat.example.com:8080", "/auth/login", http.StatusPermanentRedirect, "https://chat.example.com:8443/auth/login"},
+		{":443", "[2001:db8::1]:80", "/", http.StatusPermanentRedirect, "https://[2001:db8::1]/"},
+		{":8443", "[2001:db8::1]", "/", http.StatusPermanentRedirect, "https://[2001:db8::1]:8443/"},
+		{":443", "", "/", http.StatusBadRequest, ""},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodPost, test.target, nil)
+		r.Host = test.host
+		w := httptest.NewRecorder()
+		redirectToHTTPS(test.tlsAddr).ServeHTTP(w, r)
+
+		if w.Code != test.status || w.Header().Get("Location") != test.location {
+			t.Errorf("%s on %s: got %d %q, want %d %q",
+				test.host, test.tlsAddr, w.Code, w.Header().Get("Location"), test.status, test.location)
+		}
+	}
+}
+
+func TestTLSConfigServesHTTP2(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	err := certs.WriteSelfSigned(certFile, keyFile, []string{"127.0.0.1"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tlsConfig, _, err := newTLSConfig(&config.Config{
+		TLSCertFile:   certFile,
+		TLSKeyFile:    keyFile,
+		TLSMinVersion: "1.2",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Served like App.Start does
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{
+		Handler:   http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		TLSConfig: tlsConfig,
+		Protocols: new(http.Protocols),
+	}
+	server.Protocols.SetHTTP1(true)
+	server.Protocols.SetHTTP2(true)
+	go server.ServeTLS(listener, "", "")
+	t.Cleanup(func() { server.Close() })
+
+	pem, err := os.ReadFile(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(pem)
+
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: roots},
+		ForceAttemptHTTP2: true,
+	}}
+	res, err := client.Get("https://" + listener.Addr().String() + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.ProtoMajor != 2 {
+		t.Fatalf("Served over %s, want HTTP/2", res.Proto)
+	}
+
+	// Older versions than the minimum are refused
+	_, err = tls.Dial("tcp", listener.Addr().String(), &tls.Config{RootCAs: roots, MaxVersion: tls.VersionTLS11})
+	if err == nil {
+		t.Fatal("A TLS 1.1 handshake succeeded")
+	}
+}
@@ -0,0 +1,32 @@
+package application
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/SomeSuperCoder/global-chat/config"
+	"github.com/SomeSuperCoder/global-chat/repository"
+	"github.com/SomeSuperCoder/global-chat/webhooks"
+)
+
+func newDispatcher(cfg *config.Config, stores *repository.Stores) (*webhooks.Dispatcher, error) {
+	if cfg.WebhookMaxAttempts < 1 {
+		return nil, fmt.Errorf("invalid webhook max attempts %d", cfg.WebhookMaxAttempts)
+	}
+	if cfg.WebhookBackoff <= 0 || cfg.WebhookMaxBackoff < cfg.WebhookBackoff {
+		return nil, fmt.Errorf("invalid webhook backoff %s up to %s", cfg.WebhookBackoff, cfg.WebhookMaxBackoff)
+	}
+	if cfg.WebhookTimeout <= 0 {
+		return nil, fmt.Errorf("invalid webhook timeout %s", cfg.WebhookTimeout)
+	}
+
+	dispatcher := webhooks.New(stores.Webhooks, stores.Deliveries)
+	dispatcher.Client.Timeout = cfg.WebhookTimeout
+	dispatcher.MaxAttempts = cfg.WebhookMaxAttempts
+	dispatcher.BaseBackoff = cfg.WebhookBackoff
+	dispatcher.MaxBackoff = cfg.WebhookMaxBackoff
+	// A slow receiver must not get its delivery claimed by a second worker
+	dispatcher.Lease = max(time.Minute, 2*cfg.WebhookTimeout)
+
+	return dispatcher, nil
+}
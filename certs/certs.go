@@ -0,0 +1,129 @@
+// Package certs serves a TLS certificate from files and swaps it when the
+// files change, so renewed certificates are picked up without a restart.
+// Connections that are already open keep the certificate they started with.
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/SomeSuperCoder/global-chat/logging"
+)
+
+var log = logging.Package("certs")
+
+type Reloader struct {
+	certFile string
+	keyFile  string
+
+	cert atomic.Pointer[tls.Certificate]
+
+	// Guards the file state of the last load
+	mu       sync.Mutex
+	modified [2]fileState
+}
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// NewReloader loads the key pair once, a broken pair fails right away
+func NewReloader(certFile, keyFile string) (*Reloader, error) {
+	r := &Reloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate is meant for tls.Config.GetCertificate
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.cert.Load(), nil
+}
+
+// Reload loads the key pair again. On failure the current certificate stays
+// in use, a renewal that writes the two files one after the other may be
+// caught halfway.
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	states, err := r.stat()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load the certificate: %w", err)
+	}
+
+	r.cert.Store(&cert)
+	r.modified = states
+	if cert.Leaf != nil {
+		log.Infof("Loaded the certificate for %v, valid until %v", cert.Leaf.DNSNames, cert.Leaf.NotAfter)
+	}
+	return nil
+}
+
+// Watch checks the files every interval and reloads once either changed,
+// until the context is done. An interval of 0 or less turns the checks off,
+// then only Reload loads new files.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if !r.changed() {
+			continue
+		}
+		if err := r.Reload(); err != nil {
+			log.Errorf("Failed to reload the certificate, keeping the current one: %v", err)
+		}
+	}
+}
+
+func (r *Reloader) changed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	states, err := r.stat()
+	if err != nil {
+		// Likely replaced right now, the next tick sees the new files
+		return false
+	}
+	return states != r.modified
+}
+
+// Needs the lock. Stat follows symlinks, so swapped Kubernetes secret
+// mounts count as changes.
+func (r *Reloader) stat() ([2]fileState, error) {
+	var states [2]fileState
+	for i, name := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return states, fmt.Errorf("failed to read the certificate: %w", err)
+		}
+		states[i] = fileState{modTime: info.ModTime(), size: info.Size()}
+	}
+	return states, nil
+}
@@ -0,0 +1,151 @@
+package certs
+
+import (
+	"context"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writePair(t *testing.T) (string, string) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	err := WriteSelfSigned(certFile, keyFile, []string{"localhost", "127.0.0.1"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func serial(t *testing.T, r *Reloader) *big.Int {
+	cert, err := r.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert.Leaf.SerialNumber
+}
+
+func TestWatchReloadsChangedFiles(t *testing.T) {
+	certFile, keyFile := writePair(t)
+	r, err := NewReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := serial(t, r)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Watch(ctx, 10*time.Millisecond)
+
+	err = WriteSelfSigned(certFile, keyFile, []string{"localhost"}, 2*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for serial(t, r).Cmp(first) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("The changed certificate was not loaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWatchWithoutInterval(t *testing.T) {
+	certFile, keyFile := writePair(t)
+	r, err := NewReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := serial(t, r)
+
+	for _, interval := range []time.Duration{0, -time.Second} {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			r.Watch(context.Background(), interval)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Watch(%v) did not return", interval)
+		}
+	}
+
+	// Reloading by hand, like on SIGHUP, still works
+	err = WriteSelfSigned(certFile, keyFile, []string{"localhost"}, 2*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if serial(t, r).Cmp(first) != 0 {
+		t.Fatal("The certificate changed without a reload")
+	}
+	err = r.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if serial(t, r).Cmp(first) == 0 {
+		t.Fatal("The changed certificate was not loaded")
+	}
+}
+
+func TestBadPairKeepsCertificate(t *testing.T) {
+	certFile, keyFile := writePair(t)
+	r, err := NewReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := serial(t, r)
+
+	// A key that belongs to another certificate
+	otherCert, otherKey := writePair(t)
+	key, err := os.ReadFile(otherKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(keyFile, key, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reload(); err == nil {
+		t.Fatal("A mismatched key pair was loaded")
+	}
+	if serial(t, r).Cmp(first) != 0 {
+		t.Fatal("The certificate changed after a failed reload")
+	}
+
+	// Half written
+	err = os.WriteFile(certFile, []byte("-----BEGIN CERTIFICATE-----\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reload(); err == nil {
+		t.Fatal("A broken certificate was loaded")
+	}
+	if serial(t, r).Cmp(first) != 0 {
+		t.Fatal("The certificate changed after a failed reload")
+	}
+
+	// Fixed again
+	cert, err := os.ReadFile(otherCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(certFile, cert, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if serial(t, r).Cmp(first) == 0 {
+		t.Fatal("The fixed certificate was not loaded")
+	}
+
+	if _, err := NewReloader(certFile, filepath.Join(t.TempDir(), "missing.pem")); err == nil {
+		t.Fatal("A missing key was accepted")
+	}
+}
@@ -0,0 +1,47 @@
+package certs
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+)
+
+// ParseVersion accepts "1.2" or "1.3", older versions are broken
+func ParseVersion(version string) (uint16, error) {
+	switch version {
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported TLS version %q, expected 1.2 or 1.3", version)
+	}
+}
+
+// ParseCipherSuites looks up suites by their standard names, like
+// "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256". Insecure suites are refused. The
+// list only applies to TLS 1.2, the TLS 1.3 suites are not configurable.
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	known := map[string]uint16{}
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+	insecure := map[string]bool{}
+	for _, suite := range tls.InsecureCipherSuites() {
+		insecure[suite.Name] = true
+	}
+
+	var ids []uint16
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if insecure[name] {
+			return nil, fmt.Errorf("cipher suite %s is insecure", name)
+		}
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,56 @@
+// Command breachgen builds the breached password bloom filter used by the
+// password policy from a newline separated password list, for example
+//
+//	go run ./cmd/breachgen -o policy/breached.bloom < passwords.txt
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/SomeSuperCoder/global-chat/policy"
+)
+
+func main() {
+	output := flag.String("o", "breached.bloom", "output file")
+	falsePositiveRate := flag.Float64("fp", 0.001, "false positive rate")
+	flag.Parse()
+
+	// Read the list
+	var passwords []string
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			passwords = append(passwords, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Println("failed to read passwords:", err)
+		os.Exit(1)
+	}
+
+	// Build the filter
+	filter := policy.NewBloomFilter(len(passwords), *falsePositiveRate)
+	for _, password := range passwords {
+		filter.Add(password)
+	}
+
+	// Write it
+	file, err := os.Create(*output)
+	if err != nil {
+		fmt.Println("failed to create output:", err)
+		os.Exit(1)
+	}
+	_, err = filter.WriteTo(file)
+	if err == nil {
+		err = file.Close()
+	}
+	if err != nil {
+		fmt.Println("failed to write bloom filter:", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Wrote %d passwords to %s\n", len(passwords), *output)
+}
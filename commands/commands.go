@@ -0,0 +1,241 @@
+// Package commands implements slash commands typed into the message input.
+// Commands are kept in a registry, the built-in /help lists the ones the
+// caller is allowed to use.
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/SomeSuperCoder/global-chat/models"
+	"github.com/SomeSuperCoder/global-chat/rbac"
+	"github.com/SomeSuperCoder/global-chat/repository"
+)
+
+var (
+	ErrUnknownCommand = errors.New("unknown command")
+	ErrForbidden      = errors.New("you are not allowed to use this command")
+)
+
+// Shown to the caller as is, like a missing argument
+type Error struct {
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+type ResultKind string
+
+const (
+	// Posted to the chat as a message of the caller
+	ResultMessage ResultKind = "message"
+	// Only shown to the caller, nothing is stored
+	ResultEphemeral ResultKind = "ephemeral"
+	// Something was changed, the text says what
+	ResultAction ResultKind = "action"
+)
+
+type Result struct {
+	Kind ResultKind `json:"kind"`
+	Text string     `json:"text"`
+}
+
+func Message(text string) *Result {
+	return &Result{Kind: ResultMessage, Text: text}
+}
+
+func Ephemeral(text string) *Result {
+	return &Result{Kind: ResultEphemeral, Text: text}
+}
+
+func Action(text string) *Result {
+	return &Result{Kind: ResultAction, Text: text}
+}
+
+// One invocation of a command
+type Call struct {
+	Request  *http.Request
+	UserAuth *repository.UserAuth
+	Command  *Command
+	// Everything after the command name, trimmed
+	Args string
+}
+
+func (c *Call) Errorf(format string, args ...any) error {
+	return &Error{Message: fmt.Sprintf(format, args...)}
+}
+
+func (c *Call) UsageError() error {
+	return c.Errorf("Usage: %s", c.Command.Usage)
+}
+
+type Handler func(call *Call) (*Result, error)
+
+type Command struct {
+	Name        string // Without the slash, lowercase
+	Usage       string // Like "/mute @user <duration> [reason]"
+	Description string
+	// Empty means everyone may use the command
+	Permission rbac.Permission
+	Handler    Handler
+}
+
+var namePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,31}$`)
+
+type Registry struct {
+	mu       sync.RWMutex
+	commands map[string]*Command
+}
+
+// NewRegistry returns a registry that only knows /help
+func NewRegistry() *Registry {
+	r := &Registry{
+		commands: map[string]*Command{},
+	}
+
+	r.MustRegister(Command{
+		Name:        "help",
+		Usage:       "/help [command]",
+		Description: "Lists the commands you can use",
+		Handler:     r.help,
+	})
+
+	return r
+}
+
+func (r *Registry) Register(command Command) error {
+	if !namePattern.MatchString(command.Name) {
+		return fmt.Errorf("invalid command name %q", command.Name)
+	}
+	if command.Handler == nil {
+		return fmt.Errorf("command /%s has no handler", command.Name)
+	}
+	if command.Usage == "" {
+		command.Usage = "/" + command.Name
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.commands[command.Name]; ok {
+		return fmt.Errorf("command /%s is already registered", command.Name)
+	}
+	r.commands[command.Name] = &command
+
+	return nil
+}
+
+func (r *Registry) MustRegister(commands ...Command) {
+	for _, command := range commands {
+		if err := r.Register(command); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func (r *Registry) Lookup(name string) (*Command, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	command, ok := r.commands[strings.ToLower(name)]
+	return command, ok
+}
+
+// The commands the user may use, sorted by name
+func (r *Registry) Available(userAuth *repository.UserAuth) []*Command {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var available []*Command
+	for _, command := range r.commands {
+		if allowed(userAuth, command) {
+			available = append(available, command)
+		}
+	}
+	slices.SortFunc(available, func(a, b *Command) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return available
+}
+
+func allowed(userAuth *repository.UserAuth, command *Command) bool {
+	return command.Permission == "" || Can(userAuth, command.Permission)
+}
+
+// Can reports whether the caller of a command has the permission. It needs
+// the permission from the role, and API tokens need the admin scope too, like
+// the routes do. Commands that only need a permission for some of their
+// arguments check it with Can themselves.
+func Can(userAuth *repository.UserAuth, permission rbac.Permission) bool {
+	if userAuth.Token != nil && !userAuth.Token.Allows(models.ScopeAdmin) {
+		return false
+	}
+	return rbac.Can(userAuth.Role, permission)
+}
+
+// IsCommand reports whether the text starts with a slash. Two slashes escape
+// it, see Unescape.
+func IsCommand(text string) bool {
+	return strings.HasPrefix(text, "/") && !strings.HasPrefix(text, "//")
+}
+
+// Turns "//text" into the message "/text"
+func Unescape(text string) string {
+	if strings.HasPrefix(text, "//") {
+		return text[1:]
+	}
+	return text
+}
+
+// Splits "/name args" into its parts
+func Parse(text string) (name, args string) {
+	text = strings.TrimPrefix(text, "/")
+	name, args, _ = strings.Cut(text, " ")
+	return strings.ToLower(name), strings.TrimSpace(args)
+}
+
+func (r *Registry) Execute(req *http.Request, userAuth *repository.UserAuth, text string) (*Result, error) {
+	name, args := Parse(text)
+
+	command, ok := r.Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("%w /%s", ErrUnknownCommand, name)
+	}
+	if !allowed(userAuth, command) {
+		return nil, ErrForbidden
+	}
+
+	return command.Handler(&Call{
+		Request:  req,
+		UserAuth: userAuth,
+		Command:  command,
+		Args:     args,
+	})
+}
+
+func (r *Registry) help(call *Call) (*Result, error) {
+	if call.Args != "" {
+		command, ok := r.Lookup(strings.TrimPrefix(call.Args, "/"))
+		if !ok || !allowed(call.UserAuth, command) {
+			return nil, call.Errorf("Unknown command /%s", strings.TrimPrefix(call.Args, "/"))
+		}
+		return Ephemeral(fmt.Sprintf("%s\n%s", command.Usage, command.Description)), nil
+	}
+
+	var b strings.Builder
+	b.WriteString("Available commands:")
+	for _, command := range r.Available(call.UserAuth) {
+		fmt.Fprintf(&b, "\n%s - %s", command.Usage, command.Description)
+	}
+	b.WriteString("\nStart a message with // to send it as is")
+
+	return Ephemeral(b.String()), nil
+}
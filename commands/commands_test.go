@@ -0,0 +1,32 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/SomeSuperCoder/global-chat/models"
+	"github.com/SomeSuperCoder/global-chat/rbac"
+	"github.com/SomeSuperCoder/global-chat/repository"
+)
+
+func TestCan(t *testing.T) {
+	postToken := &models.APIToken{Scopes: []models.TokenScope{models.ScopePostMessages}}
+	adminToken := &models.APIToken{Scopes: []models.TokenScope{models.ScopeAdmin}}
+
+	tests := []struct {
+		name     string
+		userAuth *repository.UserAuth
+		want     bool
+	}{
+		{"user session", &repository.UserAuth{Role: models.RoleUser}, false},
+		{"moderator session", &repository.UserAuth{Role: models.RoleModerator}, true},
+		{"moderator token without admin scope", &repository.UserAuth{Role: models.RoleModerator, Token: postToken}, false},
+		{"moderator token with admin scope", &repository.UserAuth{Role: models.RoleModerator, Token: adminToken}, true},
+		{"user token with admin scope", &repository.UserAuth{Role: models.RoleUser, Token: adminToken}, false},
+	}
+
+	for _, test := range tests {
+		if got := Can(test.userAuth, rbac.SetTopic); got != test.want {
+			t.Errorf("%s: Can = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
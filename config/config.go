@@ -0,0 +1,383 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Config struct {
+	Addr string
+
+	// TLS is on when a certificate is set. The files are reloaded once they
+	// change or on SIGHUP. Cipher suites only apply to TLS 1.2, empty uses
+	// the Go defaults. The redirect listener sends plain HTTP to HTTPS.
+	TLSCertFile       string
+	TLSKeyFile        string
+	TLSMinVersion     string
+	TLSCipherSuites   []string
+	TLSReloadInterval time.Duration
+	TLSRedirectAddr   string
+
+	// Development only, error responses include panic messages
+	Debug bool
+
+	// Cookie policy. SameSite is lax, strict or none, none needs Secure.
+	// Secure cookies get the __Host- prefix, or __Secure- with a domain.
+	CookieSecure   bool
+	CookieSameSite string
+	CookieDomain   string
+
+	// Security headers, frame-ancestors is added to the CSP. A zero HSTS max
+	// age leaves the header out.
+	ContentSecurityPolicy string
+	FrameAncestors        string
+	ReferrerPolicy        string
+	HSTSMaxAge            time.Duration
+	HSTSIncludeSubdomains bool
+
+	// Origins of browser frontends, CORS is off without any. Patterns like
+	// "https://*.example.com" allow every subdomain.
+	CORSOrigins        []string
+	CORSCredentials    bool
+	CORSExposedHeaders []string
+	CORSMaxAge         time.Duration
+
+	// Readiness fails if a dependency takes longer than the timeout. On
+	// shutdown readiness reports draining for the drain period before the
+	// server stops taking requests, and in-flight requests get the shutdown
+	// timeout to finish.
+	ReadinessTimeout time.Duration
+	ShutdownDrain    time.Duration
+	ShutdownTimeout  time.Duration
+
+	// Logging, text or json. Package levels look like "handlers=debug".
+	LogFormat string
+	LogLevel  string
+	LogLevels []string
+
+	// Tracing, none, stdout, file or otlp
+	TraceExporter    string
+	TraceFile        string
+	TraceSampleRatio float64
+
+	// One of sqlite, mongo, postgres or memory
+	Storage       string
+	MongoURI      string
+	MongoDatabase string
+	PostgresDSN   string
+	SQLitePath    string
+
+	// Only report pending migrations and exit without serving
+	MigrateDryRun bool
+
+	// Number of distinct reports after which a message is hidden automatically
+	ReportHideThreshold int
+
+	// Registration policy, lengths are in characters
+	UsernameMinLength  int
+	UsernameMaxLength  int
+	UsernameCharset    string // ascii or unicode
+	ReservedUsernames  []string
+	PasswordMinLength  int
+	PasswordMaxLength  int
+	PasswordMinEntropy float64
+
+	// argon2id or bcrypt, existing hashes are upgraded on login
+	PasswordHasher    string
+	Argon2Memory      int // In KiB
+	Argon2Iterations  int
+	Argon2Parallelism int
+	BcryptCost        int
+
+	// Messages per minute of incoming webhooks created without a limit
+	WebhookRateLimit int
+
+	// Outgoing webhook deliveries, the backoff doubles after every failed attempt
+	WebhookMaxAttempts int
+	WebhookBackoff     time.Duration
+	WebhookMaxBackoff  time.Duration
+	WebhookTimeout     time.Duration
+
+	// Bloom filter built with cmd/breachgen. Empty uses the built-in list of
+	// common passwords, "off" disables the check.
+	BreachedPasswordsPath string
+}
+
+func Load() (*Config, error) {
+	var err error
+	cfg := &Config{
+		Addr:          getString("CHAT_ADDR", ":8090"),
+		LogFormat:     getString("CHAT_LOG_FORMAT", "text"),
+		LogLevel:      getString("CHAT_LOG_LEVEL", "info"),
+		LogLevels:     getList("CHAT_LOG_LEVELS", nil),
+		TraceExporter: getString("CHAT_TRACE_EXPORTER", "none"),
+		TraceFile:     getString("CHAT_TRACE_FILE", "traces.json"),
+		Storage:       getString("CHAT_STORAGE", "sqlite"),
+		MongoURI:      getString("CHAT_MONGO_URI", "mongodb://localhost:27017"),
+		MongoDatabase: getString("CHAT_MONGO_DATABASE", "chat"),
+		PostgresDSN:   getString("CHAT_POSTGRES_DSN", "postgres://localhost:5432/chat?sslmode=disable"),
+		SQLitePath:    getString("CHAT_SQLITE_PATH", "chat.db"),
+
+		UsernameCharset:       getString("CHAT_USERNAME_CHARSET", "unicode"),
+		ReservedUsernames:     getList("CHAT_RESERVED_USERNAMES", []string{"admin", "administrator", "system", "moderator", "root", "support"}),
+		BreachedPasswordsPath: getString("CHAT_BREACHED_PASSWORDS_PATH", ""),
+		PasswordHasher:        getString("CHAT_PASSWORD_HASHER", "argon2id"),
+
+		TLSCertFile:     getString("CHAT_TLS_CERT", ""),
+		TLSKeyFile:      getString("CHAT_TLS_KEY", ""),
+		TLSMinVersion:   getString("CHAT_TLS_MIN_VERSION", "1.2"),
+		TLSCipherSuites: getList("CHAT_TLS_CIPHER_SUITES", nil),
+		TLSRedirectAddr: getString("CHAT_TLS_REDIRECT_ADDR", ""),
+
+		CookieSameSite: getString("CHAT_COOKIE_SAMESITE", "lax"),
+		CookieDomain:   getString("CHAT_COOKIE_DOMAIN", ""),
+
+		ContentSecurityPolicy: getString("CHAT_CSP", "default-src 'none'"),
+		FrameAncestors:        getString("CHAT_FRAME_ANCESTORS", "'none'"),
+		ReferrerPolicy:        getString("CHAT_REFERRER_POLICY", "no-referrer"),
+
+		CORSOrigins:        getList("CHAT_CORS_ORIGINS", nil),
+		CORSExposedHeaders: getList("CHAT_CORS_EXPOSED_HEADERS", []string{"X-Request-ID", "X-CSRF-Token", "Retry-After"}),
+	}
+
+	cfg.TraceSampleRatio, err = getFloat("CHAT_TRACE_SAMPLE_RATIO", 1)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.ReportHideThreshold, err = getInt("CHAT_REPORT_HIDE_THRESHOLD", 5)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.WebhookRateLimit, err = getInt("CHAT_WEBHOOK_RATE_LIMIT", 30)
+	if err != nil {
+		return nil, err
+	}
+	// A limit of 0 would reject every message of webhooks created without one
+	if cfg.WebhookRateLimit <= 0 {
+		return nil, fmt.Errorf("CHAT_WEBHOOK_RATE_LIMIT has to be positive, got %d", cfg.WebhookRateLimit)
+	}
+
+	cfg.WebhookMaxAttempts, err = getInt("CHAT_WEBHOOK_MAX_ATTEMPTS", 8)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.WebhookBackoff, err = getDuration("CHAT_WEBHOOK_BACKOFF", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.WebhookMaxBackoff, err = getDuration("CHAT_WEBHOOK_MAX_BACKOFF", time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.WebhookTimeout, err = getDuration("CHAT_WEBHOOK_TIMEOUT", 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.Debug, err = getBool("CHAT_DEBUG", false)
+	if err != nil {
+		return nil, err
+	}
+
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return nil, fmt.Errorf("CHAT_TLS_CERT and CHAT_TLS_KEY have to be set together")
+	}
+	if cfg.TLSRedirectAddr != "" && cfg.TLSCertFile == "" {
+		return nil, fmt.Errorf("CHAT_TLS_REDIRECT_ADDR needs TLS")
+	}
+
+	cfg.TLSReloadInterval, err = getDuration("CHAT_TLS_RELOAD_INTERVAL", 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	// 0 only reloads on SIGHUP
+	if cfg.TLSReloadInterval < 0 {
+		return nil, fmt.Errorf("CHAT_TLS_RELOAD_INTERVAL cannot be negative, got %v", cfg.TLSReloadInterval)
+	}
+
+	// Secure by default when served over TLS
+	cfg.CookieSecure, err = getBool("CHAT_COOKIE_SECURE", cfg.TLSCertFile != "")
+	if err != nil {
+		return nil, err
+	}
+	switch cfg.CookieSameSite {
+	case "lax", "strict":
+	case "none":
+		if !cfg.CookieSecure {
+			return nil, fmt.Errorf("CHAT_COOKIE_SAMESITE=none needs CHAT_COOKIE_SECURE=true")
+		}
+	default:
+		return nil, fmt.Errorf("invalid CHAT_COOKIE_SAMESITE %q, expected lax, strict or none", cfg.CookieSameSite)
+	}
+
+	cfg.HSTSMaxAge, err = getDuration("CHAT_HSTS_MAX_AGE", 365*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.HSTSIncludeSubdomains, err = getBool("CHAT_HSTS_INCLUDE_SUBDOMAINS", false)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.CORSCredentials, err = getBool("CHAT_CORS_CREDENTIALS", true)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.CORSMaxAge, err = getDuration("CHAT_CORS_MAX_AGE", 10*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.ReadinessTimeout, err = getDuration("CHAT_READINESS_TIMEOUT", 2*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.ShutdownDrain, err = getDuration("CHAT_SHUTDOWN_DRAIN", 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.ShutdownTimeout, err = getDuration("CHAT_SHUTDOWN_TIMEOUT", 15*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.MigrateDryRun, err = getBool("CHAT_MIGRATE_DRY_RUN", false)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.UsernameMinLength, err = getInt("CHAT_USERNAME_MIN_LENGTH", 8)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.UsernameMaxLength, err = getInt("CHAT_USERNAME_MAX_LENGTH", 32)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.PasswordMinLength, err = getInt("CHAT_PASSWORD_MIN_LENGTH", 8)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.PasswordMaxLength, err = getInt("CHAT_PASSWORD_MAX_LENGTH", 64)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.PasswordMinEntropy, err = getFloat("CHAT_PASSWORD_MIN_ENTROPY", 40)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.Argon2Memory, err = getInt("CHAT_ARGON2_MEMORY", 19*1024)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.Argon2Iterations, err = getInt("CHAT_ARGON2_ITERATIONS", 2)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.Argon2Parallelism, err = getInt("CHAT_ARGON2_PARALLELISM", 1)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.BcryptCost, err = getInt("CHAT_BCRYPT_COST", 12)
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+func getString(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func getInt(key string, fallback int) (int, error) {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback, nil
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return parsed, nil
+}
+
+func getBool(key string, fallback bool) (bool, error) {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback, nil
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return parsed, nil
+}
+
+func getFloat(key string, fallback float64) (float64, error) {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback, nil
+	}
+
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return parsed, nil
+}
+
+// In Go syntax, like 30s or 1h
+func getDuration(key string, fallback time.Duration) (time.Duration, error) {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback, nil
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return parsed, nil
+}
+
+// Comma separated, an empty value gives an empty list
+func getList(key string, fallback []string) []string {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
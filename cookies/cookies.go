@@ -0,0 +1,107 @@
+// Package cookies holds the one policy that every cookie of the server is
+// set, read and cleared with, so login and logout cannot drift apart.
+package cookies
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+const (
+	sessionName = "session_token"
+	csrfName    = "csrf_token"
+)
+
+type Policy struct {
+	// Only sent over TLS. Names get the __Host- prefix, or __Secure- with a
+	// domain, so a subdomain or plain HTTP response cannot overwrite them.
+	Secure   bool
+	SameSite http.SameSite
+	// Empty for the host that set the cookie only
+	Domain string
+}
+
+// Default is used for requests that carry no policy, like in tests
+var Default = &Policy{SameSite: http.SameSiteLaxMode}
+
+func (p *Policy) SessionName() string {
+	return p.name(sessionName)
+}
+
+func (p *Policy) CSRFName() string {
+	return p.name(csrfName)
+}
+
+// Session is the cookie that authenticates, scripts cannot read it
+func (p *Policy) Session(value string, expires time.Time) *http.Cookie {
+	cookie := p.cookie(sessionName, value, expires)
+	cookie.HttpOnly = true
+	return cookie
+}
+
+// CSRF is read by the frontend and sent back in the X-CSRF-Token header
+func (p *Policy) CSRF(value string, expires time.Time) *http.Cookie {
+	return p.cookie(csrfName, value, expires)
+}
+
+// Clear removes both cookies, they have to match the ones set in name, path
+// and domain or browsers keep them
+func (p *Policy) Clear(w http.ResponseWriter) {
+	for _, cookie := range []*http.Cookie{p.Session("", time.Time{}), p.CSRF("", time.Time{})} {
+		cookie.MaxAge = -1
+		http.SetCookie(w, cookie)
+	}
+}
+
+func (p *Policy) cookie(name, value string, expires time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:     p.name(name),
+		Value:    value,
+		Path:     "/",
+		Domain:   p.Domain,
+		Expires:  expires,
+		Secure:   p.Secure,
+		SameSite: p.SameSite,
+	}
+}
+
+func (p *Policy) name(name string) string {
+	switch {
+	case !p.Secure:
+		return name
+	case p.Domain == "":
+		return "__Host-" + name
+	default:
+		return "__Secure-" + name
+	}
+}
+
+// ParseSameSite accepts lax, strict or none
+func ParseSameSite(value string) (http.SameSite, bool) {
+	switch value {
+	case "lax":
+		return http.SameSiteLaxMode, true
+	case "strict":
+		return http.SameSiteStrictMode, true
+	case "none":
+		return http.SameSiteNoneMode, true
+	default:
+		return 0, false
+	}
+}
+
+type contextKey struct{}
+
+func NewContext(ctx context.Context, p *Policy) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// FromContext returns Default if the context has no policy
+func FromContext(ctx context.Context) *Policy {
+	p, ok := ctx.Value(contextKey{}).(*Policy)
+	if !ok || p == nil {
+		return Default
+	}
+	return p
+}
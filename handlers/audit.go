@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SomeSuperCoder/global-chat/models"
+	"github.com/SomeSuperCoder/global-chat/repository"
+	"github.com/SomeSuperCoder/global-chat/utils"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type AuditHandler struct {
+	Repo repository.AuditStore
+}
+
+type AuditResponse struct {
+	Events     []models.AuditEvent `json:"events"`
+	TotalCount int64               `json:"total_count"`
+}
+
+// This functions needs to be wrapped with an auth middleware
+func (h *AuditHandler) GetEvents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	// Get data
+	page := query.Get("page")
+	limit := query.Get("limit")
+
+	// Validate
+	if page == "" {
+		http.Error(w, "No page number provided", http.StatusBadRequest)
+		return
+	}
+	if limit == "" {
+		http.Error(w, "No limit number provided", http.StatusBadRequest)
+		return
+	}
+
+	pageNumber, err := strconv.Atoi(page)
+	if utils.CheckError(w, err, "Invalid page number", http.StatusBadRequest) {
+		return
+	}
+
+	limitNumber, err := strconv.Atoi(limit)
+	if utils.CheckError(w, err, "Invalid limit number", http.StatusBadRequest) {
+		return
+	}
+
+	// Parse filters
+	var filter repository.AuditFilter
+	for _, f := range []struct {
+		name string
+		dst  *bson.ObjectID
+	}{
+		{"actor", &filter.Actor},
+		{"target", &filter.Target},
+		{"message_id", &filter.MessageID},
+	} {
+		if value := query.Get(f.name); value != "" {
+			*f.dst, err = bson.ObjectIDFromHex(value)
+			if utils.CheckError(w, err, "Invalid "+f.name, http.StatusBadRequest) {
+				return
+			}
+		}
+	}
+	for _, f := range []struct {
+		name string
+		dst  *time.Time
+	}{
+		{"since", &filter.Since},
+		{"until", &filter.Until},
+	} {
+		if value := query.Get(f.name); value != "" {
+			*f.dst, err = time.Parse(time.RFC3339, value)
+			if utils.CheckError(w, err, "Invalid "+f.name, http.StatusBadRequest) {
+				return
+			}
+		}
+	}
+	if actions := query.Get("action"); actions != "" {
+		for _, action := range strings.Split(actions, ",") {
+			filter.Actions = append(filter.Actions, models.AuditAction(action))
+		}
+	}
+	filter.IP = query.Get("ip")
+
+	// Do work
+	events, totalCount, err := h.Repo.FindPaged(r.Context(), filter, int64(pageNumber), int64(limitNumber))
+	if utils.CheckError(w, err, "Failed to fetch audit events", http.StatusInternalServerError) {
+		return
+	}
+
+	// Respond
+	result := &AuditResponse{
+		Events:     events,
+		TotalCount: totalCount,
+	}
+	resultString, err := json.Marshal(result)
+	if utils.CheckError(w, err, "Failed to from a proper response", http.StatusInternalServerError) {
+		return
+	}
+
+	fmt.Fprintln(w, string(resultString))
+}
+
+// Fills in the request metadata and stores the event. The audited action
+// already happened at this point, so a failure is only logged
+func recordAudit(r *http.Request, repo repository.AuditStore, event models.AuditEvent) {
+	event.IP = clientIP(r)
+	event.UserAgent = r.UserAgent()
+	if event.CratedAt.IsZero() {
+		event.CratedAt = time.Now()
+	}
+
+	err := repo.Record(r.Context(), event)
+	if err != nil {
+		log.WithContext(r.Context()).Errorf("Failed to record audit event %s: %v", event.Action, err)
+	}
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
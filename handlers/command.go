@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/SomeSuperCoder/global-chat/commands"
+	"github.com/SomeSuperCoder/global-chat/markdown"
+	"github.com/SomeSuperCoder/global-chat/models"
+	"github.com/SomeSuperCoder/global-chat/rbac"
+	"github.com/SomeSuperCoder/global-chat/repository"
+	"github.com/SomeSuperCoder/global-chat/utils"
+)
+
+const (
+	maxTopicLength = 200
+	// Same limit as the sanction API
+	maxMuteDuration = 365 * 24 * time.Hour
+)
+
+const shrug = `¯\_(ツ)_/¯`
+
+// Built-in slash commands
+type CommandHandler struct {
+	Users    repository.UserStore
+	Settings repository.SettingsStore
+	Audit    repository.AuditStore
+}
+
+type TopicResponse struct {
+	Topic     string    `json:"topic"`
+	UpdatedAt time.Time `json:"updated_at,omitzero"`
+}
+
+// Register adds the built-in commands to the registry
+func (h *CommandHandler) Register(registry *commands.Registry) {
+	registry.MustRegister(
+		commands.Command{
+			Name:        "me",
+			Usage:       "/me <action>",
+			Description: "Posts an action, like \"/me waves\"",
+			Handler:     h.me,
+		},
+		commands.Command{
+			Name:        "shrug",
+			Usage:       "/shrug [text]",
+			Description: "Posts the text followed by " + shrug,
+			Handler:     h.shrug,
+		},
+		commands.Command{
+			Name:        "topic",
+			Usage:       "/topic [text]",
+			Description: "Shows the topic, moderators can change it",
+			Handler:     h.topic,
+		},
+		commands.Command{
+			Name:        "mute",
+			Usage:       "/mute @user <duration> [reason]",
+			Description: "Mutes a user for a duration like 10m, 2h or 1d",
+			Permission:  rbac.ModerateUsers,
+			Handler:     h.mute,
+		},
+	)
+}
+
+func (h *CommandHandler) GetTopic(w http.ResponseWriter, r *http.Request) {
+	// Do work
+	setting, err := h.Settings.GetSetting(r.Context(), models.SettingTopic)
+	if errors.Is(err, repository.ErrNotFound) {
+		setting = &models.Setting{}
+	} else if utils.CheckError(w, err, "Failed to fetch the topic", http.StatusInternalServerError) {
+		return
+	}
+
+	// Respond
+	resultString, err := json.Marshal(TopicResponse{
+		Topic:     setting.Value,
+		UpdatedAt: setting.UpdatedAt,
+	})
+	if utils.CheckError(w, err, "Failed to from a proper response", http.StatusInternalServerError) {
+		return
+	}
+
+	fmt.Fprintln(w, string(resultString))
+}
+
+// ==============================================================
+// ================ Commands ====================================
+// ==============================================================
+
+func (h *CommandHandler) me(call *commands.Call) (*commands.Result, error) {
+	if call.Args == "" {
+		return nil, call.UsageError()
+	}
+
+	return commands.Message(fmt.Sprintf("_%s_ %s", markdown.Escape(call.UserAuth.Username), call.Args)), nil
+}
+
+func (h *CommandHandler) shrug(call *commands.Call) (*commands.Result, error) {
+	return commands.Message(strings.TrimSpace(call.Args + " " + markdown.Escape(shrug))), nil
+}
+
+// Without text the topic is shown, only to the caller
+func (h *CommandHandler) topic(call *commands.Call) (*commands.Result, error) {
+	ctx := call.Request.Context()
+
+	if call.Args == "" {
+		setting, err := h.Settings.GetSetting(ctx, models.SettingTopic)
+		if errors.Is(err, repository.ErrNotFound) || (err == nil && setting.Value == "") {
+			return commands.Ephemeral("No topic is set"), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return commands.Ephemeral("Topic: " + setting.Value), nil
+	}
+
+	if !commands.Can(call.UserAuth, rbac.SetTopic) {
+		return nil, commands.ErrForbidden
+	}
+	if utf8.RuneCountInString(call.Args) > maxTopicLength {
+		return nil, call.Errorf("The topic can be at most %d characters long", maxTopicLength)
+	}
+
+	now := time.Now()
+	err := h.Settings.PutSetting(ctx, models.Setting{
+		Key:       models.SettingTopic,
+		Value:     call.Args,
+		UpdatedBy: call.UserAuth.UserID,
+		UpdatedAt: now,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	recordAudit(call.Request, h.Audit, models.AuditEvent{
+		Action:   models.AuditTopicChanged,
+		Actor:    call.UserAuth.UserID,
+		Username: call.UserAuth.Username,
+		Reason:   call.Args,
+		CratedAt: now,
+	})
+
+	return commands.Action(fmt.Sprintf("%s changed the topic to: %s", call.UserAuth.Username, call.Args)), nil
+}
+
+func (h *CommandHandler) mute(call *commands.Call) (*commands.Result, error) {
+	fields := strings.Fields(call.Args)
+	if len(fields) < 2 || !strings.HasPrefix(fields[0], "@") {
+		return nil, call.UsageError()
+	}
+
+	username := strings.TrimPrefix(fields[0], "@")
+	duration, ok := parseMuteDuration(fields[1])
+	if !ok {
+		return nil, call.Errorf("Invalid duration %q, use something like 10m, 2h or 1d, at most 365d", fields[1])
+	}
+	reason := strings.Join(fields[2:], " ")
+	if reason == "" {
+		reason = "Muted with /mute"
+	}
+	if utf8.RuneCountInString(reason) > 500 {
+		return nil, call.Errorf("The reason can be at most 500 characters long")
+	}
+
+	ctx := call.Request.Context()
+	target, err := h.Users.GetUserByUsername(ctx, username)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, call.Errorf("User @%s not found", username)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !rbac.CanActOnUser(call.UserAuth, rbac.ModerateUsers, target) {
+		return nil, call.Errorf("You are not allowed to moderate @%s", target.Username)
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(duration)
+	err = sanctionUser(call.Request, h.Users, h.Audit, call.UserAuth, target, models.Sanction{
+		Kind:      models.SanctionMute,
+		Reason:    reason,
+		IssuedBy:  call.UserAuth.UserID,
+		ExpiresAt: &expiresAt,
+		CratedAt:  now,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return commands.Action(fmt.Sprintf("%s muted %s for %s", call.UserAuth.Username, target.Username, fields[1])), nil
+}
+
+// Accepts Go durations and whole days, like "1d"
+func parseMuteDuration(text string) (time.Duration, bool) {
+	var duration time.Duration
+	if days, ok := strings.CutSuffix(text, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, false
+		}
+		duration = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		duration, err = time.ParseDuration(text)
+		if err != nil {
+			return 0, false
+		}
+	}
+
+	return duration, duration >= time.Minute && duration <= maxMuteDuration
+}
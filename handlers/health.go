@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/SomeSuperCoder/global-chat/utils"
+)
+
+// Statuses of checks and of the whole instance
+const (
+	HealthOK          = "ok"
+	HealthFailed      = "failed"
+	HealthUnavailable = "unavailable"
+	HealthDraining    = "draining"
+)
+
+type HealthCheck struct {
+	Name string
+	// Gets a context with the check timeout
+	Run func(ctx context.Context) error
+}
+
+type HealthHandler struct {
+	Checks []HealthCheck
+	// Per check, a check that takes longer fails
+	Timeout time.Duration
+
+	draining atomic.Bool
+}
+
+type CheckResult struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type ReadinessResponse struct {
+	Status string        `json:"status"`
+	Checks []CheckResult `json:"checks"`
+}
+
+// Drain makes readiness fail from now on, so load balancers stop routing
+// here while the server shuts down
+func (h *HealthHandler) Drain() {
+	h.draining.Store(true)
+}
+
+// Livez only tells that the process serves requests, dependencies are left
+// to Readyz so an outage does not get every instance restarted
+func (h *HealthHandler) Livez(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintln(w, `{"status":"ok"}`)
+}
+
+func (h *HealthHandler) Readyz(w http.ResponseWriter, r *http.Request) {
+	// Do work
+	results := make([]CheckResult, len(h.Checks))
+	var wg sync.WaitGroup
+	for i, check := range h.Checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = h.run(r.Context(), check)
+		}()
+	}
+	wg.Wait()
+
+	result := &ReadinessResponse{
+		Status: HealthOK,
+		Checks: results,
+	}
+	for _, check := range results {
+		if check.Status != HealthOK {
+			result.Status = HealthUnavailable
+		}
+	}
+	if h.draining.Load() {
+		result.Status = HealthDraining
+	}
+
+	// Respond
+	resultString, err := json.Marshal(result)
+	if utils.CheckError(w, err, "Failed to from a proper response", http.StatusInternalServerError) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if result.Status != HealthOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	fmt.Fprintln(w, string(resultString))
+}
+
+func (h *HealthHandler) run(ctx context.Context, check HealthCheck) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, h.Timeout)
+	defer cancel()
+
+	// A check that ignores the context cannot hold up the response
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- check.Run(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := CheckResult{
+		Name:      check.Name,
+		Status:    HealthOK,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = HealthFailed
+		result.Error = err.Error()
+	}
+
+	return result
+}
@@ -2,24 +2,44 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/SomeSuperCoder/global-chat/commands"
+	"github.com/SomeSuperCoder/global-chat/logging"
+	"github.com/SomeSuperCoder/global-chat/markdown"
+	"github.com/SomeSuperCoder/global-chat/metrics"
 	"github.com/SomeSuperCoder/global-chat/middleware"
 	"github.com/SomeSuperCoder/global-chat/models"
+	"github.com/SomeSuperCoder/global-chat/rbac"
 	"github.com/SomeSuperCoder/global-chat/repository"
+	"github.com/SomeSuperCoder/global-chat/tracing"
 	"github.com/SomeSuperCoder/global-chat/utils"
+	"github.com/SomeSuperCoder/global-chat/webhooks"
 	"github.com/go-playground/validator/v10"
-	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.opentelemetry.io/otel"
 )
 
 var validate = validator.New()
 
+var log = logging.Package("handlers")
+
+var tracer = otel.Tracer("github.com/SomeSuperCoder/global-chat/handlers")
+
 type MessageHandler struct {
-	Repo repository.MessageRepo
+	Repo    repository.MessageStore
+	Reports repository.ReportStore
+	Audit   repository.AuditStore
+	Events  *webhooks.Dispatcher
+	// Nil disables slash commands, texts are posted as is
+	Commands *commands.Registry
+
+	// Zero disables automatic hiding
+	ReportHideThreshold int
 }
 
 type MessageResponse struct {
@@ -58,17 +78,26 @@ func (h *MessageHandler) GetMessages(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Messages stored before rendering was introduced
+	for i := range messages {
+		if messages[i].Rendered.Tokens == nil {
+			messages[i].Rendered = markdown.Render(messages[i].Text)
+		}
+	}
+
 	// Respond
 	result := &MessageResponse{
 		Messages:   messages,
 		TotalCount: totalCount,
 	}
+	_, span := tracer.Start(r.Context(), "encode messages")
 	resultString, err := json.Marshal(result)
+	tracing.End(span, err)
 	if utils.CheckError(w, err, "Failed to from a proper response", http.StatusInternalServerError) {
 		return
 	}
 
-	logrus.Info("Sending messages response")
+	log.WithContext(r.Context()).Debug("Sending messages response")
 	fmt.Fprintln(w, string(resultString))
 }
 
@@ -91,14 +120,20 @@ func (h *MessageHandler) CreateMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Do work
-	err = h.Repo.CreateMessage(r.Context(), models.Message{
-		Author:   userAuth.UserID,
-		Text:     request.Text,
-		CratedAt: time.Now(),
-	})
+	// Slash commands
+	if h.Commands != nil {
+		if commands.IsCommand(request.Text) {
+			h.runCommand(w, r, userAuth, request.Text)
+			return
+		}
+		request.Text = commands.Unescape(request.Text)
+	}
 
-	if utils.CheckError(w, err, "Failed to create a message", http.StatusInternalServerError) {
+	// Do work
+	if !checkNotMuted(w, userAuth) {
+		return
+	}
+	if !h.postMessage(w, r, userAuth, request.Text, metrics.SourceUser) {
 		return
 	}
 
@@ -106,7 +141,76 @@ func (h *MessageHandler) CreateMessage(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "Message successfully created")
 }
 
+// Results of commands are sent as JSON, a message result is posted first
+func (h *MessageHandler) runCommand(w http.ResponseWriter, r *http.Request, userAuth *repository.UserAuth, text string) {
+	// Do work
+	result, err := h.Commands.Execute(r, userAuth, text)
+
+	var commandErr *commands.Error
+	switch {
+	case errors.Is(err, commands.ErrUnknownCommand):
+		name, _ := commands.Parse(text)
+		http.Error(w, fmt.Sprintf("Unknown command /%s, see /help", name), http.StatusBadRequest)
+		return
+	case errors.Is(err, commands.ErrForbidden):
+		http.Error(w, "You are not allowed to use this command", http.StatusForbidden)
+		return
+	case errors.As(err, &commandErr):
+		http.Error(w, commandErr.Message, http.StatusBadRequest)
+		return
+	}
+	if utils.CheckError(w, err, "Failed to run the command", http.StatusInternalServerError) {
+		return
+	}
+
+	if result.Kind == commands.ResultMessage {
+		err = validate.Var(result.Text, "required,max=500")
+		if utils.CheckError(w, err, "The message is too long", http.StatusBadRequest) {
+			return
+		}
+		if !checkNotMuted(w, userAuth) {
+			return
+		}
+		if !h.postMessage(w, r, userAuth, result.Text, metrics.SourceCommand) {
+			return
+		}
+	}
+
+	// Respond
+	resultString, err := json.Marshal(result)
+	if utils.CheckError(w, err, "Failed to from a proper response", http.StatusInternalServerError) {
+		return
+	}
+
+	fmt.Fprintln(w, string(resultString))
+}
+
+func (h *MessageHandler) postMessage(w http.ResponseWriter, r *http.Request, userAuth *repository.UserAuth, text string, source string) bool {
+	message := models.Message{
+		ID:       bson.NewObjectID(),
+		Author:   userAuth.UserID,
+		Text:     text,
+		Rendered: markdown.Render(text),
+		CratedAt: time.Now(),
+	}
+	err := h.Repo.CreateMessage(r.Context(), message)
+	if utils.CheckError(w, err, "Failed to create a message", http.StatusInternalServerError) {
+		return false
+	}
+
+	metrics.FromContext(r.Context()).MessageCreated(source)
+	publishEvent(r, h.Events, models.EventMessageCreated, message)
+
+	return true
+}
+
 func (h *MessageHandler) UpdateMessageText(w http.ResponseWriter, r *http.Request) {
+	// Get auth data
+	userAuth := middleware.ExtractUserAuth(r)
+	if !checkNotMuted(w, userAuth) {
+		return
+	}
+
 	// Parse path params
 	messageID := r.PathValue("id")
 
@@ -114,6 +218,16 @@ func (h *MessageHandler) UpdateMessageText(w http.ResponseWriter, r *http.Reques
 	if utils.CheckError(w, err, "Invalid message ID provided", http.StatusBadRequest) {
 		return
 	}
+
+	// Check permissions
+	message, ok := h.getMessage(w, r, parsedMessageID)
+	if !ok {
+		return
+	}
+	if !rbac.CanEditMessage(userAuth, message) {
+		http.Error(w, "You are not allowed to edit this message", http.StatusForbidden)
+		return
+	}
 	// Parse body
 	var request struct {
 		Text string `json:"text" bson:"text,omitempty" validate:"omitempty,min=1,max=500"`
@@ -129,17 +243,43 @@ func (h *MessageHandler) UpdateMessageText(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	// Nothing to change
+	if request.Text == "" {
+		fmt.Fprintf(w, "Message updated successfully")
+		return
+	}
+
 	// Do work
-	err = h.Repo.UpdateMessage(r.Context(), parsedMessageID, request)
+	rendered := markdown.Render(request.Text)
+	update := models.MessageUpdate{
+		Text:     &request.Text,
+		Rendered: &rendered,
+	}
+	err = h.Repo.UpdateMessage(r.Context(), parsedMessageID, update)
 	if utils.CheckError(w, err, "Failed to update the message", http.StatusInternalServerError) {
 		return
 	}
 
+	recordAudit(r, h.Audit, models.AuditEvent{
+		Action:    models.AuditMessageEdited,
+		Actor:     userAuth.UserID,
+		Username:  userAuth.Username,
+		Target:    message.Author,
+		MessageID: parsedMessageID,
+	})
+
+	message.Text = request.Text
+	message.Rendered = rendered
+	publishEvent(r, h.Events, models.EventMessageUpdated, message)
+
 	// Respond
 	fmt.Fprintf(w, "Message updated successfully")
 }
 
 func (h *MessageHandler) DeleteMessage(w http.ResponseWriter, r *http.Request) {
+	// Get auth data
+	userAuth := middleware.ExtractUserAuth(r)
+
 	// Parse
 	messageID := r.PathValue("id")
 
@@ -148,9 +288,129 @@ func (h *MessageHandler) DeleteMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Check permissions
+	message, ok := h.getMessage(w, r, parsedMessageID)
+	if !ok {
+		return
+	}
+	if !rbac.CanDeleteMessage(userAuth, message) {
+		http.Error(w, "You are not allowed to delete this message", http.StatusForbidden)
+		return
+	}
+
 	// Delete the message
-	h.Repo.DeleteMessage(r.Context(), parsedMessageID)
+	err = h.Repo.DeleteMessage(r.Context(), parsedMessageID)
+	if utils.CheckError(w, err, "Failed to delete the message", http.StatusInternalServerError) {
+		return
+	}
+
+	recordAudit(r, h.Audit, models.AuditEvent{
+		Action:    models.AuditMessageDeleted,
+		Actor:     userAuth.UserID,
+		Username:  userAuth.Username,
+		Target:    message.Author,
+		MessageID: parsedMessageID,
+	})
+	publishEvent(r, h.Events, models.EventMessageDeleted, message)
 
 	// Respond
 	fmt.Fprintf(w, "Message deleted successfully")
 }
+
+func (h *MessageHandler) ReportMessage(w http.ResponseWriter, r *http.Request) {
+	// Get auth data
+	userAuth := middleware.ExtractUserAuth(r)
+
+	// Parse
+	messageID := r.PathValue("id")
+
+	parsedMessageID, err := bson.ObjectIDFromHex(messageID)
+	if utils.CheckError(w, err, "Invalid message ID provided", http.StatusBadRequest) {
+		return
+	}
+
+	var request struct {
+		Category models.ReportCategory `json:"category" validate:"required,oneof=spam harassment hate nsfw other"`
+		Reason   string                `json:"reason" validate:"max=500"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if utils.CheckError(w, err, "Failed to parse JSON", http.StatusBadRequest) {
+		return
+	}
+
+	// Validate
+	err = validate.Struct(request)
+	if utils.CheckError(w, err, "JSON validation failed", http.StatusBadRequest) {
+		return
+	}
+
+	message, ok := h.getMessage(w, r, parsedMessageID)
+	if !ok {
+		return
+	}
+	if message.Author == userAuth.UserID {
+		http.Error(w, "You cannot report your own message", http.StatusBadRequest)
+		return
+	}
+
+	// Do work
+	created, err := h.Reports.AddReport(r.Context(), models.Report{
+		MessageID: parsedMessageID,
+		Reporter:  userAuth.UserID,
+		Category:  request.Category,
+		Reason:    request.Reason,
+		CratedAt:  time.Now(),
+	})
+	if utils.CheckError(w, err, "Failed to report the message", http.StatusInternalServerError) {
+		return
+	}
+	if !created {
+		http.Error(w, "You have already reported this message", http.StatusConflict)
+		return
+	}
+
+	// Hide the message once enough distinct users reported it
+	if h.ReportHideThreshold > 0 && !message.Hidden {
+		count, err := h.Reports.CountOpen(r.Context(), parsedMessageID)
+		if utils.CheckError(w, err, "Failed to count reports", http.StatusInternalServerError) {
+			return
+		}
+
+		if count >= int64(h.ReportHideThreshold) {
+			err = h.Repo.SetHidden(r.Context(), parsedMessageID, true)
+			if utils.CheckError(w, err, "Failed to hide the message", http.StatusInternalServerError) {
+				return
+			}
+			recordAudit(r, h.Audit, models.AuditEvent{
+				Action:    models.AuditMessageHidden,
+				Target:    message.Author,
+				MessageID: parsedMessageID,
+				Reason:    fmt.Sprintf("%d reports", count),
+			})
+		}
+	}
+
+	// Respond
+	fmt.Fprintf(w, "Message reported successfully")
+}
+
+func (h *MessageHandler) getMessage(w http.ResponseWriter, r *http.Request, messageID bson.ObjectID) (*models.Message, bool) {
+	message, err := h.Repo.GetMessageByID(r.Context(), messageID)
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return nil, false
+	}
+	if utils.CheckError(w, err, "Failed to fetch the message", http.StatusInternalServerError) {
+		return nil, false
+	}
+
+	return message, true
+}
+
+func checkNotMuted(w http.ResponseWriter, userAuth *repository.UserAuth) bool {
+	if mute := models.ActiveMute(userAuth.Sanctions, time.Now()); mute != nil {
+		http.Error(w, "You are muted: "+describeSanction(mute), http.StatusForbidden)
+		return false
+	}
+	return true
+}
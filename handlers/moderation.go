@@ -0,0 +1,423 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/SomeSuperCoder/global-chat/middleware"
+	"github.com/SomeSuperCoder/global-chat/models"
+	"github.com/SomeSuperCoder/global-chat/rbac"
+	"github.com/SomeSuperCoder/global-chat/repository"
+	"github.com/SomeSuperCoder/global-chat/utils"
+	"github.com/SomeSuperCoder/global-chat/webhooks"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type ModerationHandler struct {
+	Users    repository.UserStore
+	Messages repository.MessageStore
+	Reports  repository.ReportStore
+	Audit    repository.AuditStore
+	Events   *webhooks.Dispatcher
+}
+
+type ReportQueueResponse struct {
+	Reports    []models.ReportGroup `json:"reports"`
+	TotalCount int64                `json:"total_count"`
+}
+
+type sanctionRequest struct {
+	Kind    models.SanctionKind `json:"kind" validate:"omitempty,oneof=ban mute timeout"`
+	Reason  string              `json:"reason" validate:"required,min=1,max=500"`
+	Minutes int                 `json:"minutes" validate:"min=0,max=525600"`
+}
+
+var sanctionAuditActions = map[models.SanctionKind]models.AuditAction{
+	models.SanctionBan:     models.AuditUserBanned,
+	models.SanctionMute:    models.AuditUserMuted,
+	models.SanctionTimeout: models.AuditUserTimedOut,
+}
+
+// All of these need to be wrapped with an auth middleware
+
+// ==============================================================
+// ================ Sanction handlers ===========================
+// ==============================================================
+
+func (h *ModerationHandler) Ban(w http.ResponseWriter, r *http.Request) {
+	h.addSanction(w, r, models.SanctionBan)
+}
+
+func (h *ModerationHandler) Mute(w http.ResponseWriter, r *http.Request) {
+	h.addSanction(w, r, models.SanctionMute)
+}
+
+func (h *ModerationHandler) Timeout(w http.ResponseWriter, r *http.Request) {
+	h.addSanction(w, r, models.SanctionTimeout)
+}
+
+func (h *ModerationHandler) Unban(w http.ResponseWriter, r *http.Request) {
+	h.liftSanctions(w, r, models.AuditUserUnbanned, models.SanctionBan)
+}
+
+func (h *ModerationHandler) Unmute(w http.ResponseWriter, r *http.Request) {
+	h.liftSanctions(w, r, models.AuditUserUnmuted, models.SanctionMute, models.SanctionTimeout)
+}
+
+func (h *ModerationHandler) addSanction(w http.ResponseWriter, r *http.Request, kind models.SanctionKind) {
+	// Get auth data
+	userAuth := middleware.ExtractUserAuth(r)
+
+	// Parse
+	targetID, err := bson.ObjectIDFromHex(r.PathValue("id"))
+	if utils.CheckError(w, err, "Invalid user ID provided", http.StatusBadRequest) {
+		return
+	}
+
+	request, ok := parseSanctionRequest(w, r)
+	if !ok {
+		return
+	}
+	request.Kind = kind
+
+	target, ok := h.getTarget(w, r, userAuth, targetID)
+	if !ok {
+		return
+	}
+
+	// Do work
+	if !h.applySanction(w, r, userAuth, target, request) {
+		return
+	}
+
+	// Respond
+	fmt.Fprintf(w, "User %s received a %s", target.Username, kind)
+}
+
+func (h *ModerationHandler) liftSanctions(w http.ResponseWriter, r *http.Request, action models.AuditAction, kinds ...models.SanctionKind) {
+	// Get auth data
+	userAuth := middleware.ExtractUserAuth(r)
+
+	// Parse
+	targetID, err := bson.ObjectIDFromHex(r.PathValue("id"))
+	if utils.CheckError(w, err, "Invalid user ID provided", http.StatusBadRequest) {
+		return
+	}
+
+	target, ok := h.getTarget(w, r, userAuth, targetID)
+	if !ok {
+		return
+	}
+
+	// Do work
+	now := time.Now()
+	err = h.Users.LiftSanctions(r.Context(), target.ID, kinds, now)
+	if utils.CheckError(w, err, "Failed to lift the sanctions", http.StatusInternalServerError) {
+		return
+	}
+
+	recordAudit(r, h.Audit, models.AuditEvent{
+		Action:   action,
+		Actor:    userAuth.UserID,
+		Username: userAuth.Username,
+		Target:   target.ID,
+		CratedAt: now,
+	})
+
+	// Respond
+	fmt.Fprintf(w, "Sanctions lifted from user %s", target.Username)
+}
+
+// ==============================================================
+// ================ Report queue handlers =======================
+// ==============================================================
+
+func (h *ModerationHandler) GetReports(w http.ResponseWriter, r *http.Request) {
+	// Get data
+	page := r.URL.Query().Get("page")
+	limit := r.URL.Query().Get("limit")
+
+	// Validate
+	if page == "" {
+		http.Error(w, "No page number provided", http.StatusBadRequest)
+		return
+	}
+	if limit == "" {
+		http.Error(w, "No limit number provided", http.StatusBadRequest)
+		return
+	}
+
+	pageNumber, err := strconv.Atoi(page)
+	if utils.CheckError(w, err, "Invalid page number", http.StatusBadRequest) {
+		return
+	}
+
+	limitNumber, err := strconv.Atoi(limit)
+	if utils.CheckError(w, err, "Invalid limit number", http.StatusBadRequest) {
+		return
+	}
+
+	// Do work
+	groups, totalCount, err := h.Reports.FindOpenGrouped(r.Context(), int64(pageNumber), int64(limitNumber))
+	if utils.CheckError(w, err, "Failed to fetch reports", http.StatusInternalServerError) {
+		return
+	}
+
+	// Respond
+	result := &ReportQueueResponse{
+		Reports:    groups,
+		TotalCount: totalCount,
+	}
+	resultString, err := json.Marshal(result)
+	if utils.CheckError(w, err, "Failed to from a proper response", http.StatusInternalServerError) {
+		return
+	}
+
+	fmt.Fprintln(w, string(resultString))
+}
+
+func (h *ModerationHandler) DismissReports(w http.ResponseWriter, r *http.Request) {
+	// Get auth data
+	userAuth := middleware.ExtractUserAuth(r)
+
+	// Parse
+	messageID, err := bson.ObjectIDFromHex(r.PathValue("id"))
+	if utils.CheckError(w, err, "Invalid message ID provided", http.StatusBadRequest) {
+		return
+	}
+
+	// Do work
+	now := time.Now()
+	resolved, err := h.Reports.ResolveAll(r.Context(), messageID, models.ResolutionDismissed, userAuth.UserID, now)
+	if utils.CheckError(w, err, "Failed to dismiss the reports", http.StatusInternalServerError) {
+		return
+	}
+	if resolved == 0 {
+		http.Error(w, "No open reports for this message", http.StatusNotFound)
+		return
+	}
+
+	// Undo an automatic hide
+	err = h.Messages.SetHidden(r.Context(), messageID, false)
+	if utils.CheckError(w, err, "Failed to unhide the message", http.StatusInternalServerError) {
+		return
+	}
+
+	recordAudit(r, h.Audit, models.AuditEvent{
+		Action:    models.AuditReportsDismissed,
+		Actor:     userAuth.UserID,
+		Username:  userAuth.Username,
+		MessageID: messageID,
+		CratedAt:  now,
+	})
+
+	// Respond
+	fmt.Fprintf(w, "%d reports dismissed", resolved)
+}
+
+func (h *ModerationHandler) DeleteReportedMessage(w http.ResponseWriter, r *http.Request) {
+	// Get auth data
+	userAuth := middleware.ExtractUserAuth(r)
+
+	// Parse
+	messageID, err := bson.ObjectIDFromHex(r.PathValue("id"))
+	if utils.CheckError(w, err, "Invalid message ID provided", http.StatusBadRequest) {
+		return
+	}
+
+	message, ok := h.getReportedMessage(w, r, userAuth, messageID, rbac.CanDeleteMessage)
+	if !ok {
+		return
+	}
+
+	// Do work
+	err = h.Messages.DeleteMessage(r.Context(), messageID)
+	if utils.CheckError(w, err, "Failed to delete the message", http.StatusInternalServerError) {
+		return
+	}
+
+	now := time.Now()
+	_, err = h.Reports.ResolveAll(r.Context(), messageID, models.ResolutionMessageDeleted, userAuth.UserID, now)
+	if utils.CheckError(w, err, "Failed to resolve the reports", http.StatusInternalServerError) {
+		return
+	}
+
+	recordAudit(r, h.Audit, models.AuditEvent{
+		Action:    models.AuditMessageDeleted,
+		Actor:     userAuth.UserID,
+		Username:  userAuth.Username,
+		Target:    message.Author,
+		MessageID: messageID,
+		CratedAt:  now,
+	})
+	publishEvent(r, h.Events, models.EventMessageDeleted, message)
+
+	// Respond
+	fmt.Fprintf(w, "Message deleted successfully")
+}
+
+func (h *ModerationHandler) SanctionReportedAuthor(w http.ResponseWriter, r *http.Request) {
+	// Get auth data
+	userAuth := middleware.ExtractUserAuth(r)
+
+	// Parse
+	messageID, err := bson.ObjectIDFromHex(r.PathValue("id"))
+	if utils.CheckError(w, err, "Invalid message ID provided", http.StatusBadRequest) {
+		return
+	}
+
+	request, ok := parseSanctionRequest(w, r)
+	if !ok {
+		return
+	}
+	if request.Kind == "" {
+		http.Error(w, "No sanction kind provided", http.StatusBadRequest)
+		return
+	}
+
+	message, ok := h.getReportedMessage(w, r, userAuth, messageID, nil)
+	if !ok {
+		return
+	}
+
+	target, ok := h.getTarget(w, r, userAuth, message.Author)
+	if !ok {
+		return
+	}
+
+	// Do work
+	if !h.applySanction(w, r, userAuth, target, request) {
+		return
+	}
+
+	_, err = h.Reports.ResolveAll(r.Context(), messageID, models.ResolutionAuthorSanctioned, userAuth.UserID, time.Now())
+	if utils.CheckError(w, err, "Failed to resolve the reports", http.StatusInternalServerError) {
+		return
+	}
+
+	// Respond
+	fmt.Fprintf(w, "User %s received a %s", target.Username, request.Kind)
+}
+
+// ==============================================================
+// ================ Helpers =====================================
+// ==============================================================
+
+func parseSanctionRequest(w http.ResponseWriter, r *http.Request) (*sanctionRequest, bool) {
+	var request sanctionRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if utils.CheckError(w, err, "Failed to parse JSON", http.StatusBadRequest) {
+		return nil, false
+	}
+
+	err = validate.Struct(request)
+	if utils.CheckError(w, err, "JSON validation failed", http.StatusBadRequest) {
+		return nil, false
+	}
+
+	return &request, true
+}
+
+func (h *ModerationHandler) applySanction(w http.ResponseWriter, r *http.Request, userAuth *repository.UserAuth, target *models.User, request *sanctionRequest) bool {
+	if request.Kind == models.SanctionTimeout && request.Minutes == 0 {
+		http.Error(w, "A timeout needs a duration in minutes", http.StatusBadRequest)
+		return false
+	}
+
+	now := time.Now()
+	sanction := models.Sanction{
+		Kind:     request.Kind,
+		Reason:   request.Reason,
+		IssuedBy: userAuth.UserID,
+		CratedAt: now,
+	}
+	if request.Minutes > 0 {
+		expiresAt := now.Add(time.Duration(request.Minutes) * time.Minute)
+		sanction.ExpiresAt = &expiresAt
+	}
+
+	err := sanctionUser(r, h.Users, h.Audit, userAuth, target, sanction)
+	if utils.CheckError(w, err, "Failed to apply the sanction", http.StatusInternalServerError) {
+		return false
+	}
+
+	return true
+}
+
+// Stores the sanction and records it in the audit log, shared with the slash
+// commands
+func sanctionUser(r *http.Request, users repository.UserStore, audit repository.AuditStore, userAuth *repository.UserAuth, target *models.User, sanction models.Sanction) error {
+	err := users.AddSanction(r.Context(), target.ID, sanction)
+	if err != nil {
+		return err
+	}
+
+	recordAudit(r, audit, models.AuditEvent{
+		Action:    sanctionAuditActions[sanction.Kind],
+		Actor:     userAuth.UserID,
+		Username:  userAuth.Username,
+		Target:    target.ID,
+		Reason:    sanction.Reason,
+		ExpiresAt: sanction.ExpiresAt,
+		CratedAt:  sanction.CratedAt,
+	})
+	if sanction.Kind == models.SanctionBan {
+		recordAudit(r, audit, models.AuditEvent{
+			Action:   models.AuditSessionsRevoked,
+			Actor:    userAuth.UserID,
+			Username: userAuth.Username,
+			Target:   target.ID,
+			Reason:   sanction.Reason,
+			CratedAt: sanction.CratedAt,
+		})
+	}
+
+	return nil
+}
+
+func (h *ModerationHandler) getTarget(w http.ResponseWriter, r *http.Request, userAuth *repository.UserAuth, targetID bson.ObjectID) (*models.User, bool) {
+	target, err := h.Users.GetUserByID(r.Context(), targetID)
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return nil, false
+	}
+	if utils.CheckError(w, err, "Failed to fetch the user", http.StatusInternalServerError) {
+		return nil, false
+	}
+
+	if !rbac.CanActOnUser(userAuth, rbac.ModerateUsers, target) {
+		http.Error(w, "You are not allowed to moderate this user", http.StatusForbidden)
+		return nil, false
+	}
+
+	return target, true
+}
+
+func (h *ModerationHandler) getReportedMessage(w http.ResponseWriter, r *http.Request, userAuth *repository.UserAuth, messageID bson.ObjectID, allowed func(*repository.UserAuth, *models.Message) bool) (*models.Message, bool) {
+	message, err := h.Messages.GetMessageByID(r.Context(), messageID)
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return nil, false
+	}
+	if utils.CheckError(w, err, "Failed to fetch the message", http.StatusInternalServerError) {
+		return nil, false
+	}
+
+	if allowed != nil && !allowed(userAuth, message) {
+		http.Error(w, "You are not allowed to act on this message", http.StatusForbidden)
+		return nil, false
+	}
+
+	return message, true
+}
+
+func describeSanction(sanction *models.Sanction) string {
+	if sanction.ExpiresAt == nil {
+		return sanction.Reason
+	}
+	return fmt.Sprintf("%s (until %s)", sanction.Reason, sanction.ExpiresAt.UTC().Format(time.RFC3339))
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/SomeSuperCoder/global-chat/middleware"
+	"github.com/SomeSuperCoder/global-chat/models"
+	"github.com/SomeSuperCoder/global-chat/repository"
+	"github.com/SomeSuperCoder/global-chat/utils"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type TokenHandler struct {
+	Repo  repository.TokenStore
+	Audit repository.AuditStore
+}
+
+type createTokenRequest struct {
+	Name   string              `json:"name" validate:"required,min=1,max=100"`
+	Scopes []models.TokenScope `json:"scopes" validate:"required,min=1,dive,oneof=messages:read messages:post admin"`
+	// Zero means the token never expires
+	ExpiresInDays int `json:"expires_in_days" validate:"min=0,max=365"`
+}
+
+// The token itself is only ever part of this response
+type CreateTokenResponse struct {
+	models.APIToken
+	Token string `json:"token"`
+}
+
+// All of these need to be wrapped with an auth middleware
+
+func (h *TokenHandler) CreateToken(w http.ResponseWriter, r *http.Request) {
+	// Get auth data
+	userAuth := middleware.ExtractUserAuth(r)
+
+	// Parse
+	var request createTokenRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if utils.CheckError(w, err, "Failed to parse JSON", http.StatusBadRequest) {
+		return
+	}
+
+	// Validate
+	err = validate.Struct(request)
+	if utils.CheckError(w, err, "JSON validation failed", http.StatusBadRequest) {
+		return
+	}
+
+	// Do work
+	now := time.Now()
+	token := utils.GenerateAPIToken()
+	apiToken := models.APIToken{
+		ID:          bson.NewObjectID(),
+		UserID:      userAuth.UserID,
+		Name:        request.Name,
+		Prefix:      token[:len(utils.APITokenPrefix)+8],
+		HashedToken: utils.HashToken(token),
+		Scopes:      request.Scopes,
+		CratedAt:    now,
+	}
+	if request.ExpiresInDays > 0 {
+		expiresAt := now.AddDate(0, 0, request.ExpiresInDays)
+		apiToken.ExpiresAt = &expiresAt
+	}
+
+	err = h.Repo.CreateToken(r.Context(), apiToken)
+	if utils.CheckError(w, err, "Failed to create the token", http.StatusInternalServerError) {
+		return
+	}
+
+	recordAudit(r, h.Audit, models.AuditEvent{
+		Action:    models.AuditTokenCreated,
+		Actor:     userAuth.UserID,
+		Username:  userAuth.Username,
+		Reason:    apiToken.Name,
+		ExpiresAt: apiToken.ExpiresAt,
+		CratedAt:  now,
+	})
+
+	// Respond
+	response, err := json.Marshal(CreateTokenResponse{APIToken: apiToken, Token: token})
+	if utils.CheckError(w, err, "Failed to from a proper response", http.StatusInternalServerError) {
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprintln(w, string(response))
+}
+
+func (h *TokenHandler) GetTokens(w http.ResponseWriter, r *http.Request) {
+	// Get auth data
+	userAuth := middleware.ExtractUserAuth(r)
+
+	// Do work
+	tokens, err := h.Repo.FindTokens(r.Context(), userAuth.UserID)
+	if utils.CheckError(w, err, "Failed to get tokens", http.StatusInternalServerError) {
+		return
+	}
+
+	// Respond
+	response, err := json.Marshal(tokens)
+	if utils.CheckError(w, err, "Failed to from a proper response", http.StatusInternalServerError) {
+		return
+	}
+
+	fmt.Fprintln(w, string(response))
+}
+
+func (h *TokenHandler) DeleteToken(w http.ResponseWriter, r *http.Request) {
+	// Get auth data
+	userAuth := middleware.ExtractUserAuth(r)
+
+	// Parse
+	tokenID, err := bson.ObjectIDFromHex(r.PathValue("id"))
+	if utils.CheckError(w, err, "Invalid token ID provided", http.StatusBadRequest) {
+		return
+	}
+
+	// Do work
+	deleted, err := h.Repo.DeleteToken(r.Context(), userAuth.UserID, tokenID)
+	if utils.CheckError(w, err, "Failed to delete the token", http.StatusInternalServerError) {
+		return
+	}
+	if !deleted {
+		http.Error(w, "Token not found", http.StatusNotFound)
+		return
+	}
+
+	recordAudit(r, h.Audit, models.AuditEvent{
+		Action:   models.AuditTokenRevoked,
+		Actor:    userAuth.UserID,
+		Username: userAuth.Username,
+	})
+
+	// Respond
+	fmt.Fprintln(w, "Token revoked")
+}
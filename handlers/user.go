@@ -7,16 +7,24 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/SomeSuperCoder/global-chat/cookies"
+	"github.com/SomeSuperCoder/global-chat/metrics"
 	"github.com/SomeSuperCoder/global-chat/middleware"
 	"github.com/SomeSuperCoder/global-chat/models"
+	"github.com/SomeSuperCoder/global-chat/passwords"
+	"github.com/SomeSuperCoder/global-chat/policy"
+	"github.com/SomeSuperCoder/global-chat/rbac"
 	"github.com/SomeSuperCoder/global-chat/repository"
 	"github.com/SomeSuperCoder/global-chat/utils"
 	"go.mongodb.org/mongo-driver/v2/bson"
-	"go.mongodb.org/mongo-driver/v2/mongo"
 )
 
 type UserHandler struct {
-	Repo repository.UserRepo
+	Repo     repository.UserStore
+	Sessions repository.SessionStore
+	Audit    repository.AuditStore
+	Policy   *policy.Policy
+	Hasher   *passwords.Manager
 }
 
 // ==============================================================
@@ -27,34 +35,53 @@ func (h *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
 	username := r.FormValue("username") // Allowed
 	password := r.FormValue("password") // Allowed
 
-	// Check username and password length
-	if len(username) < 8 || len(password) < 8 {
-		http.Error(w, "Invalid username/password", http.StatusNotAcceptable)
+	// Validate against the registration policy
+	err := h.Policy.Check(username, password)
+	var validationErr *policy.ValidationError
+	if errors.As(err, &validationErr) {
+		writeValidationError(w, validationErr)
 		return
 	}
 
-	// Make sure such user does not already exist
-	doesExist := h.Repo.DoesExist(r.Context(), username)
-	if doesExist {
-		http.Error(w, "User already exists", http.StatusConflict)
+	// Create new user
+	hashedPassword, err := h.Hasher.Hash(password)
+	if errors.Is(err, passwords.ErrPasswordTooLong) {
+		writeValidationError(w, &policy.ValidationError{Errors: []policy.FieldError{{
+			Field:   "password",
+			Code:    policy.CodeTooLong,
+			Message: "Password is too long",
+		}}})
+		return
+	}
+	if utils.CheckError(w, err, "Failed to hash password", http.StatusInternalServerError) {
 		return
 	}
-
-	// Create new user
-	hashedPassword, _ := utils.HashPassword(password)
 	newUser := &models.User{
 		Username:       username,
 		HashedPassword: hashedPassword,
+		Role:           models.RoleUser,
 		Sessions:       []models.UserSession{},
+		Sanctions:      []models.Sanction{},
 		CratedAt:       time.Now(),
 	}
 
-	err := h.Repo.CreateUser(r.Context(), newUser)
+	// The store rejects names that are taken, even in a different case or with lookalike letters
+	err = h.Repo.CreateUser(r.Context(), newUser)
+	if errors.Is(err, repository.ErrAlreadyExists) {
+		http.Error(w, "User already exists", http.StatusConflict)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
+	recordAudit(r, h.Audit, models.AuditEvent{
+		Action:   models.AuditUserRegistered,
+		Actor:    newUser.ID,
+		Username: username,
+	})
+
 	fmt.Fprintln(w, "User registered successfully!")
 
 }
@@ -67,92 +94,250 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 	user, err := h.Repo.GetUserByUsername(r.Context(), username)
 
 	// Check if user exists
-	if errors.Is(err, mongo.ErrNoDocuments) {
+	if errors.Is(err, repository.ErrNotFound) {
+		h.loginFailed(r, models.AuditEvent{
+			Username: username,
+			Reason:   "unknown user",
+		})
 		http.Error(w, "Wrong username or password", http.StatusUnauthorized)
 		return
 	}
 
 	// Check for any other errors
 	if err != nil {
-		fmt.Println("Any other error!")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		log.WithContext(r.Context()).Errorf("Failed to fetch the user %q: %v", username, err)
+		http.Error(w, "Failed to fetch the user", http.StatusInternalServerError)
+		return
+	}
+
+	// Bots have no password and only post through webhooks
+	if user.IsBot {
+		h.loginFailed(r, models.AuditEvent{
+			Actor:    user.ID,
+			Username: username,
+			Reason:   "bot account",
+		})
+		http.Error(w, "Invalid username or password", http.StatusUnauthorized)
 		return
 	}
 
 	// Verify password
-	if !utils.CheckPasswordhash(password, user.HashedPassword) {
+	ok, rehash, err := h.Hasher.Verify(password, user.HashedPassword)
+	if err != nil {
+		log.WithContext(r.Context()).Errorf("Failed to verify the password of %s: %v", user.ID.Hex(), err)
+	}
+	if !ok {
+		h.loginFailed(r, models.AuditEvent{
+			Actor:    user.ID,
+			Username: username,
+			Reason:   "wrong password",
+		})
 		http.Error(w, "Invalid username or password", http.StatusUnauthorized)
 		return
 	}
 
+	// Upgrade hashes made with an older algorithm or weaker parameters, the
+	// plain password is only available now
+	if rehash {
+		h.rehashPassword(r, user, password)
+	}
+
+	// Banned users cannot log in
+	if ban := models.ActiveBan(user.Sanctions, time.Now()); ban != nil {
+		h.loginFailed(r, models.AuditEvent{
+			Actor:    user.ID,
+			Username: username,
+			Reason:   "banned",
+		})
+		http.Error(w, "User is banned: "+describeSanction(ban), http.StatusForbidden)
+		return
+	}
+
 	// Generate tokens and expire date
 	sessionToken := utils.GenerateToken(32)
 	csrfToken := utils.GenerateToken(32)
 	expires := time.Now().Add(7 * 24 * time.Hour) // 1 week
 
-	// Set token cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_token",
-		Value:    sessionToken,
-		Expires:  expires,
-		HttpOnly: true,
-		Path:     "/",
-	})
+	// Set token and CSRF token cookies
+	cookiePolicy := cookies.FromContext(r.Context())
+	http.SetCookie(w, cookiePolicy.Session(sessionToken, expires))
+	http.SetCookie(w, cookiePolicy.CSRF(csrfToken, expires))
 
-	// Set CSRF token cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "csrf_token",
-		Value:    csrfToken,
-		Expires:  expires,
-		HttpOnly: false,
-		Path:     "/",
-	})
+	// Frontends on another origin cannot read the cookie
+	w.Header().Set(middleware.CSRFTokenHeader, csrfToken)
 
-	// Store token in DB
+	// Store token in DB, under the name as registered since the lookup ignores case
 	newSession := models.UserSession{
 		SessionToken: sessionToken,
 		CSRFToken:    csrfToken,
 		CratedAt:     time.Now(),
 	}
-	err = h.Repo.AddLoginSession(r.Context(), username, newSession)
+	err = h.Sessions.AddLoginSession(r.Context(), user.Username, newSession)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	metrics.FromContext(r.Context()).AuthAttempt(metrics.AuthPassword, true)
+	recordAudit(r, h.Audit, models.AuditEvent{
+		Action:   models.AuditLoginSucceeded,
+		Actor:    user.ID,
+		Username: user.Username,
+	})
+
 	fmt.Fprintln(w, "Login successful!")
 }
 
+// Failed logins are audited and counted the same way
+func (h *UserHandler) loginFailed(r *http.Request, event models.AuditEvent) {
+	event.Action = models.AuditLoginFailed
+	recordAudit(r, h.Audit, event)
+	metrics.FromContext(r.Context()).AuthAttempt(metrics.AuthPassword, false)
+}
+
 // This functions needs to be wrapped with an auth middleware
 func (h *UserHandler) Logout(w http.ResponseWriter, r *http.Request) {
 	userAuth := middleware.ExtractUserAuth(r)
 
 	// Reset cookies
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_token",
-		Value:    "",
-		Expires:  time.Now().Add(-time.Hour),
-		HttpOnly: true,
-	})
-
-	http.SetCookie(w, &http.Cookie{
-		Name:     "csrf_token",
-		Value:    "",
-		Expires:  time.Now().Add(-time.Hour),
-		HttpOnly: false,
-	})
+	cookiePolicy := cookies.FromContext(r.Context())
+	cookiePolicy.Clear(w)
 
 	// Get session token
-	sessionToken, _ := r.Cookie("session_token")
+	sessionToken, _ := r.Cookie(cookiePolicy.SessionName())
 	// Remove session from database
-	err := h.Repo.FinalizeSession(r.Context(), userAuth.Username, sessionToken.Value)
+	err := h.Sessions.FinalizeSession(r.Context(), userAuth.Username, sessionToken.Value)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
+	recordAudit(r, h.Audit, models.AuditEvent{
+		Action:   models.AuditLogout,
+		Actor:    userAuth.UserID,
+		Username: userAuth.Username,
+	})
+
 	fmt.Fprintln(w, "Logged out successfully!")
 }
 
+// A failed rehash does not fail the login, the old hash stays valid
+func (h *UserHandler) rehashPassword(r *http.Request, user *models.User, password string) {
+	hashedPassword, err := h.Hasher.Hash(password)
+	if err == nil {
+		err = h.Repo.SetPasswordHash(r.Context(), user.ID, hashedPassword)
+	}
+	if err != nil {
+		log.WithContext(r.Context()).Errorf("Failed to rehash the password of %s: %v", user.ID.Hex(), err)
+	}
+}
+
+// ==============================================================
+// ================ User management handlers ====================
+// ==============================================================
+
+// This functions needs to be wrapped with an auth middleware
+func (h *UserHandler) SetRole(w http.ResponseWriter, r *http.Request) {
+	userAuth := middleware.ExtractUserAuth(r)
+
+	// Parse
+	parsedUserID, err := bson.ObjectIDFromHex(r.PathValue("id"))
+	if utils.CheckError(w, err, "Invalid user ID provided", http.StatusBadRequest) {
+		return
+	}
+
+	role := models.Role(r.FormValue("role"))
+	if !role.Valid() {
+		http.Error(w, "Invalid role", http.StatusBadRequest)
+		return
+	}
+
+	// Check permissions
+	target, err := h.Repo.GetUserByID(r.Context(), parsedUserID)
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if utils.CheckError(w, err, "Failed to fetch the user", http.StatusInternalServerError) {
+		return
+	}
+	if !rbac.CanActOnUser(userAuth, rbac.ManageUsers, target) || !userAuth.Role.AtLeast(role) {
+		http.Error(w, "You are not allowed to change this user's role", http.StatusForbidden)
+		return
+	}
+
+	// Do work
+	err = h.Repo.SetRole(r.Context(), parsedUserID, role)
+	if utils.CheckError(w, err, "Failed to update the role", http.StatusInternalServerError) {
+		return
+	}
+
+	recordAudit(r, h.Audit, models.AuditEvent{
+		Action:   models.AuditRoleChanged,
+		Actor:    userAuth.UserID,
+		Username: userAuth.Username,
+		Target:   target.ID,
+		Reason:   fmt.Sprintf("%s -> %s", target.Role, role),
+	})
+
+	fmt.Fprintln(w, "Role updated successfully!")
+}
+
+// This functions needs to be wrapped with an auth middleware
+func (h *UserHandler) CreateBot(w http.ResponseWriter, r *http.Request) {
+	userAuth := middleware.ExtractUserAuth(r)
+
+	// Parse
+	var request struct {
+		Username string `json:"username"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if utils.CheckError(w, err, "Failed to parse JSON", http.StatusBadRequest) {
+		return
+	}
+
+	// Validate, bots follow the same naming rules as everyone else
+	if fieldErrors := h.Policy.CheckUsername(request.Username); len(fieldErrors) > 0 {
+		writeValidationError(w, &policy.ValidationError{Errors: fieldErrors})
+		return
+	}
+
+	// Do work
+	bot := &models.User{
+		Username:  request.Username,
+		Role:      models.RoleUser,
+		IsBot:     true,
+		Sessions:  []models.UserSession{},
+		Sanctions: []models.Sanction{},
+		CratedAt:  time.Now(),
+	}
+	err = h.Repo.CreateUser(r.Context(), bot)
+	if errors.Is(err, repository.ErrAlreadyExists) {
+		http.Error(w, "User already exists", http.StatusConflict)
+		return
+	}
+	if utils.CheckError(w, err, "Failed to create the bot", http.StatusInternalServerError) {
+		return
+	}
+
+	recordAudit(r, h.Audit, models.AuditEvent{
+		Action:   models.AuditBotCreated,
+		Actor:    userAuth.UserID,
+		Username: userAuth.Username,
+		Target:   bot.ID,
+		Reason:   bot.Username,
+	})
+
+	// Respond
+	response, err := json.Marshal(bot)
+	if utils.CheckError(w, err, "Failed to from a proper response", http.StatusInternalServerError) {
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprintln(w, string(response))
+}
+
 // ==============================================================
 // ================ Non-auth-related handlers ===================
 // ==============================================================
@@ -179,7 +364,7 @@ func (h *UserHandler) GetUserByUsername(w http.ResponseWriter, r *http.Request)
 
 func getUserCommon(user *models.User, err error, w http.ResponseWriter) {
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
+		if errors.Is(err, repository.ErrNotFound) {
 			http.Error(w, err.Error(), http.StatusNotFound)
 			return
 		}
@@ -189,6 +374,7 @@ func getUserCommon(user *models.User, err error, w http.ResponseWriter) {
 
 	// Security
 	user.HashedPassword = "SECRET"
+	user.Sanctions = nil
 
 	serializedUser, err := json.Marshal(&user)
 	if err != nil {
@@ -198,3 +384,15 @@ func getUserCommon(user *models.User, err error, w http.ResponseWriter) {
 
 	fmt.Fprintln(w, string(serializedUser))
 }
+
+// Responds with every violated rule, so clients can show them next to the fields
+func writeValidationError(w http.ResponseWriter, validationErr *policy.ValidationError) {
+	response, err := json.Marshal(validationErr)
+	if utils.CheckError(w, err, "Failed to from a proper response", http.StatusInternalServerError) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	fmt.Fprintln(w, string(response))
+}
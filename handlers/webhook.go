@@ -0,0 +1,498 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/SomeSuperCoder/global-chat/markdown"
+	"github.com/SomeSuperCoder/global-chat/metrics"
+	"github.com/SomeSuperCoder/global-chat/middleware"
+	"github.com/SomeSuperCoder/global-chat/models"
+	"github.com/SomeSuperCoder/global-chat/ratelimit"
+	"github.com/SomeSuperCoder/global-chat/repository"
+	"github.com/SomeSuperCoder/global-chat/utils"
+	"github.com/SomeSuperCoder/global-chat/webhooks"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Signatures with a timestamp further away than this are rejected, so a
+// captured request can only be replayed for a short time
+const maxSignatureAge = 5 * time.Minute
+
+// Larger payloads are rejected before the signature is checked
+const maxWebhookPayload = 64 << 10
+
+type WebhookHandler struct {
+	Repo       repository.WebhookStore
+	Deliveries repository.DeliveryStore
+	Users      repository.UserStore
+	Messages   repository.MessageStore
+	Audit      repository.AuditStore
+	Events     *webhooks.Dispatcher
+	Limiter    *ratelimit.Limiter
+
+	// Used when a webhook is created without a limit
+	DefaultRateLimit int
+}
+
+type createIncomingWebhookRequest struct {
+	Name  string `json:"name" validate:"required,min=1,max=100"`
+	BotID string `json:"bot_id" validate:"required,mongodb"`
+	// Messages per minute, zero uses the default
+	RateLimit int `json:"rate_limit" validate:"min=0,max=600"`
+}
+
+// The URL token and the secret are only ever part of this response
+type CreateIncomingWebhookResponse struct {
+	models.IncomingWebhook
+	URL    string `json:"url"`
+	Secret string `json:"secret"`
+}
+
+// ==============================================================
+// ================ Management handlers =========================
+// ==============================================================
+
+// All of these need to be wrapped with an auth middleware
+
+func (h *WebhookHandler) CreateIncomingWebhook(w http.ResponseWriter, r *http.Request) {
+	// Get auth data
+	userAuth := middleware.ExtractUserAuth(r)
+
+	// Parse
+	var request createIncomingWebhookRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if utils.CheckError(w, err, "Failed to parse JSON", http.StatusBadRequest) {
+		return
+	}
+
+	// Validate
+	err = validate.Struct(request)
+	if utils.CheckError(w, err, "JSON validation failed", http.StatusBadRequest) {
+		return
+	}
+
+	botID, _ := bson.ObjectIDFromHex(request.BotID)
+	bot, err := h.Users.GetUserByID(r.Context(), botID)
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "Bot not found", http.StatusNotFound)
+		return
+	}
+	if utils.CheckError(w, err, "Failed to fetch the bot", http.StatusInternalServerError) {
+		return
+	}
+	if !bot.IsBot {
+		http.Error(w, "Webhooks can only post as a bot", http.StatusBadRequest)
+		return
+	}
+
+	// Do work
+	token := utils.GenerateToken(32)
+	secret := utils.GenerateToken(32)
+	hook := models.IncomingWebhook{
+		ID:          bson.NewObjectID(),
+		BotID:       bot.ID,
+		Name:        request.Name,
+		HashedToken: utils.HashToken(token),
+		Secret:      secret,
+		RateLimit:   request.RateLimit,
+		CreatedBy:   userAuth.UserID,
+		CratedAt:    time.Now(),
+	}
+	if hook.RateLimit == 0 {
+		hook.RateLimit = h.DefaultRateLimit
+	}
+
+	err = h.Repo.CreateIncomingWebhook(r.Context(), hook)
+	if utils.CheckError(w, err, "Failed to create the webhook", http.StatusInternalServerError) {
+		return
+	}
+
+	recordAudit(r, h.Audit, models.AuditEvent{
+		Action:   models.AuditWebhookCreated,
+		Actor:    userAuth.UserID,
+		Username: userAuth.Username,
+		Target:   bot.ID,
+		Reason:   hook.Name,
+	})
+
+	// Respond
+	response, err := json.Marshal(CreateIncomingWebhookResponse{
+		IncomingWebhook: hook,
+		URL:             "/hooks/" + token,
+		Secret:          secret,
+	})
+	if utils.CheckError(w, err, "Failed to from a proper response", http.StatusInternalServerError) {
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprintln(w, string(response))
+}
+
+func (h *WebhookHandler) GetIncomingWebhooks(w http.ResponseWriter, r *http.Request) {
+	// Do work
+	hooks, err := h.Repo.FindIncomingWebhooks(r.Context())
+	if utils.CheckError(w, err, "Failed to get webhooks", http.StatusInternalServerError) {
+		return
+	}
+
+	// Respond
+	response, err := json.Marshal(hooks)
+	if utils.CheckError(w, err, "Failed to from a proper response", http.StatusInternalServerError) {
+		return
+	}
+
+	fmt.Fprintln(w, string(response))
+}
+
+func (h *WebhookHandler) DeleteIncomingWebhook(w http.ResponseWriter, r *http.Request) {
+	// Get auth data
+	userAuth := middleware.ExtractUserAuth(r)
+
+	// Parse
+	hookID, err := bson.ObjectIDFromHex(r.PathValue("id"))
+	if utils.CheckError(w, err, "Invalid webhook ID provided", http.StatusBadRequest) {
+		return
+	}
+
+	// Do work
+	deleted, err := h.Repo.DeleteIncomingWebhook(r.Context(), hookID)
+	if utils.CheckError(w, err, "Failed to delete the webhook", http.StatusInternalServerError) {
+		return
+	}
+	if !deleted {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+
+	recordAudit(r, h.Audit, models.AuditEvent{
+		Action:   models.AuditWebhookDeleted,
+		Actor:    userAuth.UserID,
+		Username: userAuth.Username,
+		Reason:   hookID.Hex(),
+	})
+
+	// Respond
+	fmt.Fprintln(w, "Webhook deleted")
+}
+
+type createOutgoingWebhookRequest struct {
+	Name   string                `json:"name" validate:"required,min=1,max=100"`
+	URL    string                `json:"url" validate:"required,max=2000,http_url"`
+	Events []models.WebhookEvent `json:"events" validate:"required,min=1,unique,dive,oneof=message.created message.updated message.deleted"`
+}
+
+// The secret is only ever part of this response
+type CreateOutgoingWebhookResponse struct {
+	models.OutgoingWebhook
+	Secret string `json:"secret"`
+}
+
+type DeliveryLogResponse struct {
+	Deliveries []models.WebhookDelivery `json:"deliveries"`
+	TotalCount int64                    `json:"total_count"`
+}
+
+func (h *WebhookHandler) CreateOutgoingWebhook(w http.ResponseWriter, r *http.Request) {
+	// Get auth data
+	userAuth := middleware.ExtractUserAuth(r)
+
+	// Parse
+	var request createOutgoingWebhookRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if utils.CheckError(w, err, "Failed to parse JSON", http.StatusBadRequest) {
+		return
+	}
+
+	// Validate
+	err = validate.Struct(request)
+	if utils.CheckError(w, err, "JSON validation failed", http.StatusBadRequest) {
+		return
+	}
+
+	// Do work
+	secret := utils.GenerateToken(32)
+	hook := models.OutgoingWebhook{
+		ID:        bson.NewObjectID(),
+		Name:      request.Name,
+		URL:       request.URL,
+		Secret:    secret,
+		Events:    request.Events,
+		CreatedBy: userAuth.UserID,
+		CratedAt:  time.Now(),
+	}
+
+	err = h.Repo.CreateOutgoingWebhook(r.Context(), hook)
+	if utils.CheckError(w, err, "Failed to create the webhook", http.StatusInternalServerError) {
+		return
+	}
+
+	recordAudit(r, h.Audit, models.AuditEvent{
+		Action:   models.AuditWebhookCreated,
+		Actor:    userAuth.UserID,
+		Username: userAuth.Username,
+		Reason:   hook.Name,
+	})
+
+	// Respond
+	response, err := json.Marshal(CreateOutgoingWebhookResponse{OutgoingWebhook: hook, Secret: secret})
+	if utils.CheckError(w, err, "Failed to from a proper response", http.StatusInternalServerError) {
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprintln(w, string(response))
+}
+
+func (h *WebhookHandler) GetOutgoingWebhooks(w http.ResponseWriter, r *http.Request) {
+	// Do work
+	hooks, err := h.Repo.FindOutgoingWebhooks(r.Context())
+	if utils.CheckError(w, err, "Failed to get webhooks", http.StatusInternalServerError) {
+		return
+	}
+
+	// Respond
+	response, err := json.Marshal(hooks)
+	if utils.CheckError(w, err, "Failed to from a proper response", http.StatusInternalServerError) {
+		return
+	}
+
+	fmt.Fprintln(w, string(response))
+}
+
+func (h *WebhookHandler) DeleteOutgoingWebhook(w http.ResponseWriter, r *http.Request) {
+	// Get auth data
+	userAuth := middleware.ExtractUserAuth(r)
+
+	// Parse
+	hookID, err := bson.ObjectIDFromHex(r.PathValue("id"))
+	if utils.CheckError(w, err, "Invalid webhook ID provided", http.StatusBadRequest) {
+		return
+	}
+
+	// Do work
+	deleted, err := h.Repo.DeleteOutgoingWebhook(r.Context(), hookID)
+	if utils.CheckError(w, err, "Failed to delete the webhook", http.StatusInternalServerError) {
+		return
+	}
+	if !deleted {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+
+	recordAudit(r, h.Audit, models.AuditEvent{
+		Action:   models.AuditWebhookDeleted,
+		Actor:    userAuth.UserID,
+		Username: userAuth.Username,
+		Reason:   hookID.Hex(),
+	})
+
+	// Respond
+	fmt.Fprintln(w, "Webhook deleted")
+}
+
+// Newest first, optionally only the deliveries in one state
+func (h *WebhookHandler) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	// Parse
+	hookID, err := bson.ObjectIDFromHex(r.PathValue("id"))
+	if utils.CheckError(w, err, "Invalid webhook ID provided", http.StatusBadRequest) {
+		return
+	}
+
+	query := r.URL.Query()
+	page := query.Get("page")
+	limit := query.Get("limit")
+	status := models.DeliveryStatus(query.Get("status"))
+
+	// Validate
+	if page == "" {
+		http.Error(w, "No page number provided", http.StatusBadRequest)
+		return
+	}
+	if limit == "" {
+		http.Error(w, "No limit number provided", http.StatusBadRequest)
+		return
+	}
+
+	pageNumber, err := strconv.Atoi(page)
+	if utils.CheckError(w, err, "Invalid page number", http.StatusBadRequest) {
+		return
+	}
+
+	limitNumber, err := strconv.Atoi(limit)
+	if utils.CheckError(w, err, "Invalid limit number", http.StatusBadRequest) {
+		return
+	}
+
+	if status != "" && !status.Valid() {
+		http.Error(w, "Invalid status", http.StatusBadRequest)
+		return
+	}
+
+	// Do work
+	_, err = h.Repo.GetOutgoingWebhook(r.Context(), hookID)
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+	if utils.CheckError(w, err, "Failed to fetch the webhook", http.StatusInternalServerError) {
+		return
+	}
+
+	deliveries, count, err := h.Deliveries.FindDeliveries(r.Context(), hookID, status, int64(pageNumber), int64(limitNumber))
+	if utils.CheckError(w, err, "Failed to get deliveries", http.StatusInternalServerError) {
+		return
+	}
+
+	// Respond
+	response, err := json.Marshal(DeliveryLogResponse{
+		Deliveries: deliveries,
+		TotalCount: count,
+	})
+	if utils.CheckError(w, err, "Failed to from a proper response", http.StatusInternalServerError) {
+		return
+	}
+
+	fmt.Fprintln(w, string(response))
+}
+
+// Dead deliveries are retried by hand once the receiver is fixed
+func (h *WebhookHandler) RetryDelivery(w http.ResponseWriter, r *http.Request) {
+	// Parse
+	deliveryID, err := bson.ObjectIDFromHex(r.PathValue("id"))
+	if utils.CheckError(w, err, "Invalid delivery ID provided", http.StatusBadRequest) {
+		return
+	}
+
+	// Do work
+	retried, err := h.Deliveries.RetryDelivery(r.Context(), deliveryID, time.Now())
+	if utils.CheckError(w, err, "Failed to retry the delivery", http.StatusInternalServerError) {
+		return
+	}
+	if !retried {
+		http.Error(w, "No dead delivery with this ID", http.StatusNotFound)
+		return
+	}
+
+	// Respond
+	fmt.Fprintln(w, "Delivery queued again")
+}
+
+// ==============================================================
+// ================ Public handlers =============================
+// ==============================================================
+
+// The token in the URL identifies the webhook, the signature proves the
+// sender knows its secret
+func (h *WebhookHandler) ReceiveIncomingWebhook(w http.ResponseWriter, r *http.Request) {
+	// Get the webhook
+	hook, err := h.Repo.GetIncomingWebhook(r.Context(), utils.HashToken(r.PathValue("token")))
+	if errors.Is(err, repository.ErrNotFound) {
+		metrics.FromContext(r.Context()).AuthAttempt(metrics.AuthWebhook, false)
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+	if utils.CheckError(w, err, "Failed to fetch the webhook", http.StatusInternalServerError) {
+		return
+	}
+
+	// Verify the signature before anything counts against the limit
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookPayload))
+	if utils.CheckError(w, err, "Failed to read the payload", http.StatusRequestEntityTooLarge) {
+		return
+	}
+	valid := validSignature(r, hook.Secret, payload)
+	metrics.FromContext(r.Context()).AuthAttempt(metrics.AuthWebhook, valid)
+	if !valid {
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	allowed, wait := h.Limiter.Allow(hook.ID.Hex(), hook.RateLimit)
+	if !allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+		return
+	}
+
+	// Parse
+	var request struct {
+		Text string `json:"text" validate:"required,min=1,max=500"`
+	}
+	err = json.Unmarshal(payload, &request)
+	if utils.CheckError(w, err, "Failed to parse JSON", http.StatusBadRequest) {
+		return
+	}
+
+	// Validate
+	err = validate.Struct(request)
+	if utils.CheckError(w, err, "JSON validation failed", http.StatusBadRequest) {
+		return
+	}
+
+	// Bots can be muted and banned like anyone else
+	bot, err := h.Users.GetUserByID(r.Context(), hook.BotID)
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "Bot not found", http.StatusNotFound)
+		return
+	}
+	if utils.CheckError(w, err, "Failed to fetch the bot", http.StatusInternalServerError) {
+		return
+	}
+	if sanction := models.ActiveSanction(bot.Sanctions, time.Now(), models.SanctionBan, models.SanctionMute, models.SanctionTimeout); sanction != nil {
+		http.Error(w, "Bot is not allowed to post: "+describeSanction(sanction), http.StatusForbidden)
+		return
+	}
+
+	// Do work
+	message := models.Message{
+		ID:       bson.NewObjectID(),
+		Author:   bot.ID,
+		Text:     request.Text,
+		Rendered: markdown.Render(request.Text),
+		CratedAt: time.Now(),
+	}
+	err = h.Messages.CreateMessage(r.Context(), message)
+	if utils.CheckError(w, err, "Failed to create a message", http.StatusInternalServerError) {
+		return
+	}
+
+	metrics.FromContext(r.Context()).MessageCreated(metrics.SourceWebhook)
+	publishEvent(r, h.Events, models.EventMessageCreated, message)
+
+	// Respond
+	fmt.Fprintf(w, "Message successfully created")
+}
+
+func validSignature(r *http.Request, secret string, payload []byte) bool {
+	timestamp, err := strconv.ParseInt(r.Header.Get(webhooks.TimestampHeader), 10, 64)
+	if err != nil {
+		return false
+	}
+
+	age := time.Since(time.Unix(timestamp, 0))
+	if age > maxSignatureAge || age < -maxSignatureAge {
+		return false
+	}
+
+	return utils.VerifySignature(secret, timestamp, payload, r.Header.Get(webhooks.SignatureHeader))
+}
+
+// Failing to queue an event does not fail the request, like the audit log
+func publishEvent(r *http.Request, events *webhooks.Dispatcher, event models.WebhookEvent, data any) {
+	if events == nil {
+		return
+	}
+
+	err := events.Publish(r.Context(), event, data)
+	if err != nil {
+		log.WithContext(r.Context()).Errorf("Failed to queue webhook event %s: %v", event, err)
+	}
+}
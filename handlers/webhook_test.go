@@ -0,0 +1,161 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SomeSuperCoder/global-chat/handlers"
+	"github.com/SomeSuperCoder/global-chat/models"
+	"github.com/SomeSuperCoder/global-chat/ratelimit"
+	"github.com/SomeSuperCoder/global-chat/repository/memory"
+	"github.com/SomeSuperCoder/global-chat/utils"
+	"github.com/SomeSuperCoder/global-chat/webhooks"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	hookToken  = "token"
+	hookSecret = "secret"
+	hookBody   = `{"text": "Build passed"}`
+)
+
+func newWebhookHandler(t *testing.T, rateLimit int) *handlers.WebhookHandler {
+	ctx := context.Background()
+	stores := memory.NewStores()
+
+	bot := &models.User{Username: "ci", IsBot: true, CratedAt: time.Now()}
+	err := stores.Users.CreateUser(ctx, bot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = stores.Webhooks.CreateIncomingWebhook(ctx, models.IncomingWebhook{
+		ID:          bson.NewObjectID(),
+		BotID:       bot.ID,
+		Name:        "ci",
+		HashedToken: utils.HashToken(hookToken),
+		Secret:      hookSecret,
+		RateLimit:   rateLimit,
+		CratedAt:    time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &handlers.WebhookHandler{
+		Repo:     stores.Webhooks,
+		Users:    stores.Users,
+		Messages: stores.Messages,
+		Limiter:  ratelimit.New(),
+	}
+}
+
+func postHook(h *handlers.WebhookHandler, body string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/hooks/"+hookToken, strings.NewReader(body))
+	req.SetPathValue("token", hookToken)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	rec := httptest.NewRecorder()
+	h.ReceiveIncomingWebhook(rec, req)
+	return rec
+}
+
+// Headers of a request signed with secret at timestamp
+func signed(secret string, timestamp int64, body string) http.Header {
+	header := http.Header{}
+	header.Set(webhooks.TimestampHeader, strconv.FormatInt(timestamp, 10))
+	header.Set(webhooks.SignatureHeader, utils.SignPayload(secret, timestamp, []byte(body)))
+	return header
+}
+
+func TestReceiveIncomingWebhookSignature(t *testing.T) {
+	now := time.Now().Unix()
+	tests := []struct {
+		name   string
+		header http.Header
+		want   int
+	}{
+		{"valid", signed(hookSecret, now, hookBody), http.StatusOK},
+		{"slightly in the future", signed(hookSecret, now+60, hookBody), http.StatusOK},
+		{"no headers", http.Header{}, http.StatusUnauthorized},
+		{"no signature", func() http.Header {
+			header := signed(hookSecret, now, hookBody)
+			header.Del(webhooks.SignatureHeader)
+			return header
+		}(), http.StatusUnauthorized},
+		{"no timestamp", func() http.Header {
+			header := signed(hookSecret, now, hookBody)
+			header.Del(webhooks.TimestampHeader)
+			return header
+		}(), http.StatusUnauthorized},
+		{"malformed signature", func() http.Header {
+			header := signed(hookSecret, now, hookBody)
+			header.Set(webhooks.SignatureHeader, "sha256=not-hex")
+			return header
+		}(), http.StatusUnauthorized},
+		{"malformed timestamp", func() http.Header {
+			header := signed(hookSecret, now, hookBody)
+			header.Set(webhooks.TimestampHeader, "yesterday")
+			return header
+		}(), http.StatusUnauthorized},
+		{"wrong secret", signed("other", now, hookBody), http.StatusUnauthorized},
+		{"other body", signed(hookSecret, now, `{"text": "Build failed"}`), http.StatusUnauthorized},
+		{"other timestamp", func() http.Header {
+			header := signed(hookSecret, now, hookBody)
+			header.Set(webhooks.TimestampHeader, strconv.FormatInt(now-1, 10))
+			return header
+		}(), http.StatusUnauthorized},
+		{"too old", signed(hookSecret, now-int64((10*time.Minute).Seconds()), hookBody), http.StatusUnauthorized},
+		{"too far in the future", signed(hookSecret, now+int64((10*time.Minute).Seconds()), hookBody), http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := newWebhookHandler(t, 10)
+			rec := postHook(h, hookBody, test.header)
+			if rec.Code != test.want {
+				t.Errorf("Status = %d, want %d (%s)", rec.Code, test.want, strings.TrimSpace(rec.Body.String()))
+			}
+		})
+	}
+}
+
+func TestReceiveIncomingWebhookRateLimit(t *testing.T) {
+	h := newWebhookHandler(t, 2)
+	start := time.Now()
+	h.Limiter.Now = func() time.Time { return start }
+
+	for i := range 2 {
+		rec := postHook(h, hookBody, signed(hookSecret, start.Unix(), hookBody))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Request %d: status = %d, want %d", i+1, rec.Code, http.StatusOK)
+		}
+	}
+
+	rec := postHook(h, hookBody, signed(hookSecret, start.Unix(), hookBody))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("Status = %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+	// Two messages per minute refill one every 30 seconds
+	if got := rec.Header().Get("Retry-After"); got != "30" {
+		t.Errorf("Retry-After = %q, want %q", got, "30")
+	}
+
+	// Rejected signatures do not count against the limit
+	h.Limiter.Now = func() time.Time { return start.Add(30 * time.Second) }
+	rec = postHook(h, hookBody, signed("other", start.Unix(), hookBody))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("Status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	rec = postHook(h, hookBody, signed(hookSecret, start.Unix(), hookBody))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Status after the refill = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,179 @@
+// Package logging configures the one logrus output of the server. Every
+// package logs through its own logger from Package, so levels can be set per
+// package, and lines logged with a request context carry the request fields.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+type Config struct {
+	Format string // text or json
+	Level  string // Default for packages without a level of their own
+	// Like "handlers=debug", the name is the one given to Package
+	PackageLevels []string
+	Output        io.Writer // Stderr if nil
+}
+
+var (
+	mu      sync.Mutex
+	loggers = map[string]*logrus.Logger{}
+
+	// Applied to every logger
+	formatter     logrus.Formatter = &logrus.TextFormatter{FullTimestamp: true}
+	output        io.Writer        = os.Stderr
+	defaultLevel  logrus.Level     = logrus.InfoLevel
+	packageLevels map[string]logrus.Level
+)
+
+// Package returns the logger of a package. It can be called before Configure,
+// loggers pick up the configuration once it is applied.
+func Package(name string) *logrus.Entry {
+	mu.Lock()
+	defer mu.Unlock()
+
+	logger, ok := loggers[name]
+	if !ok {
+		logger = logrus.New()
+		logger.AddHook(contextHook{})
+		apply(name, logger)
+		loggers[name] = logger
+	}
+
+	return logger.WithField("package", name)
+}
+
+// Configure replaces the format, output and levels of all loggers
+func Configure(cfg Config) error {
+	var newFormatter logrus.Formatter
+	switch cfg.Format {
+	case FormatText, "":
+		newFormatter = &logrus.TextFormatter{FullTimestamp: true}
+	case FormatJSON:
+		newFormatter = &logrus.JSONFormatter{}
+	default:
+		return fmt.Errorf("unknown log format %q", cfg.Format)
+	}
+
+	newDefault := logrus.InfoLevel
+	if cfg.Level != "" {
+		var err error
+		newDefault, err = logrus.ParseLevel(cfg.Level)
+		if err != nil {
+			return fmt.Errorf("invalid log level: %w", err)
+		}
+	}
+
+	newLevels := map[string]logrus.Level{}
+	for _, item := range cfg.PackageLevels {
+		name, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return fmt.Errorf("invalid package log level %q, expected package=level", item)
+		}
+		level, err := logrus.ParseLevel(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("invalid log level of %s: %w", name, err)
+		}
+		newLevels[strings.TrimSpace(name)] = level
+	}
+
+	newOutput := cfg.Output
+	if newOutput == nil {
+		newOutput = os.Stderr
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	formatter = newFormatter
+	output = newOutput
+	defaultLevel = newDefault
+	packageLevels = newLevels
+	for name, logger := range loggers {
+		apply(name, logger)
+	}
+
+	return nil
+}
+
+// Needs the lock
+func apply(name string, logger *logrus.Logger) {
+	logger.SetFormatter(formatter)
+	logger.SetOutput(output)
+
+	level, ok := packageLevels[name]
+	if !ok {
+		level = defaultLevel
+	}
+	logger.SetLevel(level)
+}
+
+// ==============================================================
+// ================ Request fields ==============================
+// ==============================================================
+
+// Request describes the request a line belongs to. It is stored in the context
+// once and filled in while the request is handled, so the middlewares that run
+// first still see the route and user found later.
+type Request struct {
+	ID     string
+	Route  string
+	UserID string
+}
+
+type contextKey struct{}
+
+func NewContext(ctx context.Context, request *Request) context.Context {
+	return context.WithValue(ctx, contextKey{}, request)
+}
+
+// FromContext returns nil outside of a request
+func FromContext(ctx context.Context) *Request {
+	request, _ := ctx.Value(contextKey{}).(*Request)
+	return request
+}
+
+// Adds the request and trace fields to lines logged with WithContext
+type contextHook struct{}
+
+func (contextHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (contextHook) Fire(entry *logrus.Entry) error {
+	if entry.Context == nil {
+		return nil
+	}
+
+	if span := trace.SpanContextFromContext(entry.Context); span.IsValid() {
+		entry.Data["trace_id"] = span.TraceID().String()
+		entry.Data["span_id"] = span.SpanID().String()
+	}
+
+	request := FromContext(entry.Context)
+	if request == nil {
+		return nil
+	}
+
+	entry.Data["request_id"] = request.ID
+	if request.UserID != "" {
+		entry.Data["user_id"] = request.UserID
+	}
+	if request.Route != "" {
+		entry.Data["route"] = request.Route
+	}
+	return nil
+}
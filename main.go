@@ -3,14 +3,28 @@ package main
 import (
 	"context"
 	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/SomeSuperCoder/global-chat/application"
+	"github.com/SomeSuperCoder/global-chat/config"
 )
 
 func main() {
-	app := application.New()
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Println("failed to load config:", err)
+		return
+	}
+
+	app := application.New(cfg)
+
+	// Interrupts drain and stop the server gracefully
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	err := app.Start(context.Background())
+	err = app.Start(ctx)
 	if err != nil {
 		fmt.Println("failed to start app:", err)
 	}
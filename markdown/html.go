@@ -0,0 +1,88 @@
+package markdown
+
+import (
+	"html"
+	"net/url"
+	"strings"
+)
+
+var safeSchemes = map[string]bool{
+	"http":   true,
+	"https":  true,
+	"mailto": true,
+}
+
+// SafeURL reports whether a link target uses one of the allowed schemes and
+// returns it in normalized form
+func SafeURL(raw string) (string, bool) {
+	if strings.ContainsFunc(raw, func(r rune) bool { return r < 0x20 || r == 0x7f }) {
+		return "", false
+	}
+
+	parsed, err := url.Parse(raw)
+	if err != nil || !safeSchemes[strings.ToLower(parsed.Scheme)] {
+		return "", false
+	}
+	if parsed.Scheme != "mailto" && parsed.Host == "" {
+		return "", false
+	}
+
+	return parsed.String(), true
+}
+
+func ToHTML(tokens []Token) string {
+	var b strings.Builder
+	writeHTML(&b, tokens)
+	return b.String()
+}
+
+func writeHTML(b *strings.Builder, tokens []Token) {
+	for _, t := range tokens {
+		switch t.Type {
+		case TokenText:
+			b.WriteString(html.EscapeString(t.Text))
+		case TokenLineBreak:
+			b.WriteString("<br>")
+		case TokenBold:
+			b.WriteString("<strong>")
+			writeHTML(b, t.Children)
+			b.WriteString("</strong>")
+		case TokenItalic:
+			b.WriteString("<em>")
+			writeHTML(b, t.Children)
+			b.WriteString("</em>")
+		case TokenCode:
+			b.WriteString("<code>")
+			b.WriteString(html.EscapeString(t.Text))
+			b.WriteString("</code>")
+		case TokenCodeBlock:
+			b.WriteString("<pre><code")
+			if t.Language != "" {
+				b.WriteString(` class="language-`)
+				b.WriteString(html.EscapeString(t.Language))
+				b.WriteString(`"`)
+			}
+			b.WriteString(">")
+			b.WriteString(html.EscapeString(t.Text))
+			b.WriteString("</code></pre>")
+		case TokenLink:
+			// Tokens may come from storage, so check the scheme again
+			href, ok := SafeURL(t.URL)
+			if !ok {
+				writeHTML(b, t.Children)
+				continue
+			}
+			b.WriteString(`<a href="`)
+			b.WriteString(html.EscapeString(href))
+			b.WriteString(`" rel="nofollow noopener noreferrer" target="_blank">`)
+			writeHTML(b, t.Children)
+			b.WriteString("</a>")
+		case TokenMention:
+			b.WriteString(`<span class="mention" data-username="`)
+			b.WriteString(html.EscapeString(t.Text))
+			b.WriteString(`">@`)
+			b.WriteString(html.EscapeString(t.Text))
+			b.WriteString("</span>")
+		}
+	}
+}
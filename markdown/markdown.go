@@ -0,0 +1,326 @@
+package markdown
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type TokenType string
+
+const (
+	TokenText      TokenType = "text"
+	TokenBold      TokenType = "bold"
+	TokenItalic    TokenType = "italic"
+	TokenCode      TokenType = "code"
+	TokenCodeBlock TokenType = "code_block"
+	TokenLink      TokenType = "link"
+	TokenMention   TokenType = "mention"
+	TokenLineBreak TokenType = "line_break"
+)
+
+type Token struct {
+	Type     TokenType `bson:"type" json:"type"`
+	Text     string    `bson:"text,omitempty" json:"text,omitempty"`
+	URL      string    `bson:"url,omitempty" json:"url,omitempty"`
+	Language string    `bson:"language,omitempty" json:"language,omitempty"`
+	Children []Token   `bson:"children,omitempty" json:"children,omitempty"`
+}
+
+type Rendered struct {
+	HTML   string  `bson:"html" json:"html"`
+	Tokens []Token `bson:"tokens" json:"tokens"`
+}
+
+// Render parses the supported Markdown subset and returns both the sanitized
+// HTML and the token tree it was built from
+func Render(text string) Rendered {
+	tokens := Parse(text)
+
+	return Rendered{
+		HTML:   ToHTML(tokens),
+		Tokens: tokens,
+	}
+}
+
+func Parse(text string) []Token {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+
+	tokens := []Token{}
+	var paragraph []string
+
+	flush := func() {
+		if len(paragraph) == 0 {
+			return
+		}
+		tokens = append(tokens, parseInline(strings.Join(paragraph, "\n"), true)...)
+		paragraph = nil
+	}
+
+	lines := strings.Split(text, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if !strings.HasPrefix(line, "```") {
+			paragraph = append(paragraph, line)
+			continue
+		}
+
+		// Look for the closing fence, otherwise the line is plain text
+		end := -1
+		for j := i + 1; j < len(lines); j++ {
+			if strings.TrimSpace(lines[j]) == "```" {
+				end = j
+				break
+			}
+		}
+		if end == -1 {
+			paragraph = append(paragraph, line)
+			continue
+		}
+
+		flush()
+		tokens = append(tokens, Token{
+			Type:     TokenCodeBlock,
+			Text:     strings.Join(lines[i+1:end], "\n"),
+			Language: sanitizeLanguage(strings.TrimSpace(line[3:])),
+		})
+		i = end
+	}
+	flush()
+
+	return mergeText(tokens)
+}
+
+func parseInline(s string, allowLinks bool) []Token {
+	var tokens []Token
+	var text strings.Builder
+
+	emit := func(t Token) {
+		if text.Len() > 0 {
+			tokens = append(tokens, Token{Type: TokenText, Text: text.String()})
+			text.Reset()
+		}
+		tokens = append(tokens, t)
+	}
+
+	for i := 0; i < len(s); {
+		c := s[i]
+
+		switch {
+		case c == '\\' && i+1 < len(s) && isEscapable(s[i+1]):
+			text.WriteByte(s[i+1])
+			i += 2
+			continue
+
+		case c == '\n':
+			emit(Token{Type: TokenLineBreak})
+			i++
+			continue
+
+		case c == '`':
+			if end := strings.IndexByte(s[i+1:], '`'); end > 0 {
+				emit(Token{Type: TokenCode, Text: s[i+1 : i+1+end]})
+				i += end + 2
+				continue
+			}
+
+		case c == '*' && strings.HasPrefix(s[i:], "***"):
+			// "***x***" is both, otherwise the first star is literal and
+			// the rest may still be bold, like "*" followed by "**x**"
+			if end := strings.Index(s[i+3:], "***"); end > 0 {
+				bold := Token{Type: TokenBold, Children: parseInline(s[i+3:i+3+end], allowLinks)}
+				emit(Token{Type: TokenItalic, Children: []Token{bold}})
+				i += end + 6
+				continue
+			}
+
+		case c == '*' && strings.HasPrefix(s[i:], "**"):
+			if end := strings.Index(s[i+2:], "**"); end > 0 {
+				emit(Token{Type: TokenBold, Children: parseInline(s[i+2:i+2+end], allowLinks)})
+				i += end + 4
+				continue
+			}
+
+		case c == '*' || c == '_':
+			if end := findItalicEnd(s, i); end > 0 {
+				emit(Token{Type: TokenItalic, Children: parseInline(s[i+1:end], allowLinks)})
+				i = end + 1
+				continue
+			}
+
+		case c == '[' && allowLinks:
+			if label, target, n, ok := parseLink(s[i:]); ok {
+				if url, safe := SafeURL(target); safe {
+					emit(Token{Type: TokenLink, URL: url, Children: parseInline(label, false)})
+					i += n
+					continue
+				}
+			}
+
+		case c == '@' && !precededByWord(s, i):
+			if n := mentionLength(s[i+1:]); n > 0 {
+				emit(Token{Type: TokenMention, Text: s[i+1 : i+1+n]})
+				i += n + 1
+				continue
+			}
+
+		case c == 'h' && allowLinks && !precededByWord(s, i):
+			if n := autolinkLength(s[i:]); n > 0 {
+				if url, safe := SafeURL(s[i : i+n]); safe {
+					emit(Token{Type: TokenLink, URL: url, Children: []Token{{Type: TokenText, Text: s[i : i+n]}}})
+					i += n
+					continue
+				}
+			}
+		}
+
+		text.WriteByte(c)
+		i++
+	}
+
+	if text.Len() > 0 {
+		tokens = append(tokens, Token{Type: TokenText, Text: text.String()})
+	}
+
+	return tokens
+}
+
+func findItalicEnd(s string, start int) int {
+	marker := s[start]
+
+	// Underscores inside words (snake_case) are not emphasis
+	if marker == '_' && precededByWord(s, start) {
+		return -1
+	}
+	// The opening marker must be followed by non-whitespace
+	if start+1 >= len(s) || isSpace(s[start+1]) || s[start+1] == marker {
+		return -1
+	}
+
+	for i := start + 2; i < len(s); i++ {
+		if s[i] == '\n' {
+			return -1
+		}
+		if s[i] != marker {
+			continue
+		}
+		if marker == '*' && i+1 < len(s) && s[i+1] == '*' {
+			// Part of a bold marker, which may open after a space
+			i++
+			continue
+		}
+		if isSpace(s[i-1]) {
+			continue
+		}
+		if marker == '_' && i+1 < len(s) && isWordByte(s[i+1]) {
+			continue
+		}
+		return i
+	}
+
+	return -1
+}
+
+func parseLink(s string) (label, target string, n int, ok bool) {
+	closeLabel := strings.Index(s, "](")
+	if closeLabel <= 1 || strings.ContainsAny(s[1:closeLabel], "[\n") {
+		return "", "", 0, false
+	}
+
+	rest := s[closeLabel+2:]
+	closeTarget := strings.IndexByte(rest, ')')
+	if closeTarget <= 0 || strings.ContainsAny(rest[:closeTarget], " \n") {
+		return "", "", 0, false
+	}
+
+	return s[1:closeLabel], rest[:closeTarget], closeLabel + 2 + closeTarget + 1, true
+}
+
+func mentionLength(s string) int {
+	n := 0
+	for n < len(s) && (isWordByte(s[n]) || s[n] == '.' || s[n] == '-') {
+		n++
+	}
+	// Do not swallow trailing punctuation such as "@bob."
+	for n > 0 && (s[n-1] == '.' || s[n-1] == '-') {
+		n--
+	}
+	return n
+}
+
+func autolinkLength(s string) int {
+	if !strings.HasPrefix(s, "http://") && !strings.HasPrefix(s, "https://") {
+		return 0
+	}
+
+	n := 0
+	for n < len(s) {
+		r, size := utf8.DecodeRuneInString(s[n:])
+		if unicode.IsSpace(r) || r == '<' || r == '>' || r == '"' {
+			break
+		}
+		n += size
+	}
+	// Trailing punctuation most likely belongs to the sentence
+	for n > 0 && strings.IndexByte(".,;:!?)'", s[n-1]) >= 0 {
+		n--
+	}
+	return n
+}
+
+func sanitizeLanguage(lang string) string {
+	var b strings.Builder
+	for _, r := range lang {
+		if r < utf8.RuneSelf && (isWordByte(byte(r)) || r == '+' || r == '-' || r == '#') {
+			b.WriteRune(r)
+		}
+		if b.Len() >= 32 {
+			break
+		}
+	}
+	return b.String()
+}
+
+func mergeText(tokens []Token) []Token {
+	merged := tokens[:0]
+	for _, t := range tokens {
+		if len(t.Children) > 0 {
+			t.Children = mergeText(t.Children)
+		}
+		last := len(merged) - 1
+		if t.Type == TokenText && last >= 0 && merged[last].Type == TokenText {
+			merged[last].Text += t.Text
+			continue
+		}
+		merged = append(merged, t)
+	}
+	return merged
+}
+
+func precededByWord(s string, i int) bool {
+	return i > 0 && isWordByte(s[i-1])
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= utf8.RuneSelf
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n'
+}
+
+func isEscapable(c byte) bool {
+	return strings.IndexByte("\\`*_[]()@", c) >= 0
+}
+
+// Escape makes text render literally, like a username inside generated Markdown
+func Escape(text string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if isEscapable(text[i]) {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(text[i])
+	}
+	return b.String()
+}
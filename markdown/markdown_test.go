@@ -0,0 +1,103 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+const linkAttributes = `" rel="nofollow noopener noreferrer" target="_blank">`
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name, text, want string
+	}{
+		// Schemes
+		{"https link", "[x](https://a.com)", `<a href="https://a.com` + linkAttributes + `x</a>`},
+		{"upper case scheme", "[x](HTTPS://a.com)", `<a href="https://a.com` + linkAttributes + `x</a>`},
+		{"mailto link", "[x](mailto:a@b.c)", `<a href="mailto:a@b.c` + linkAttributes + `x</a>`},
+		{"javascript link", "[x](javascript:alert(1))", "[x](javascript:alert(1))"},
+		{"mixed case javascript", "[x](JaVaScRiPt:alert)", "[x](JaVaScRiPt:alert)"},
+		{"space before scheme", "[x]( javascript:a)", "[x]( javascript:a)"},
+		{"tab before scheme", "[x](\tjavascript:a)", "[x](\tjavascript:a)"},
+		{"tab inside scheme", "[x](java\tscript:a)", "[x](java\tscript:a)"},
+		{"data link", "[x](data:text/html,hi)", "[x](data:text/html,hi)"},
+		{"no host", "[x](https:evil)", "[x](https:evil)"},
+		{"empty host", "[x](https:///evil)", "[x](https:///evil)"},
+		{"protocol relative link", "[x](//evil.com)", "[x](//evil.com)"},
+		{"protocol relative text", "//evil.com", "//evil.com"},
+		{"autolink", "see https://a.com.", `see <a href="https://a.com` + linkAttributes + `https://a.com</a>.`},
+
+		// Quotes and angle brackets
+		{"quote in target", `[x](https://a.com/"onmouseover=x)`, `<a href="https://a.com/%22onmouseover=x` + linkAttributes + `x</a>`},
+		{"tag in target", "[x](https://a.com/<script>)", `<a href="https://a.com/%3Cscript%3E` + linkAttributes + `x</a>`},
+		{"tag in label", `[<b>"](https://a.com)`, `<a href="https://a.com` + linkAttributes + `&lt;b&gt;&#34;</a>`},
+		{"autolink stops at quote", `https://a.com/"x`, `<a href="https://a.com/` + linkAttributes + `https://a.com/</a>&#34;x`},
+		{"code span", "`\"<b>`", "<code>&#34;&lt;b&gt;</code>"},
+		{"fenced language", "```\"><script>\n<b>\n```", `<pre><code class="language-script">&lt;b&gt;</code></pre>`},
+		{"text", `<img src=x onerror="alert(1)">`, "&lt;img src=x onerror=&#34;alert(1)&#34;&gt;"},
+
+		// Emphasis
+		{"bold", "**x**", "<strong>x</strong>"},
+		{"italic", "*x*", "<em>x</em>"},
+		{"bold and italic", "***x***", "<em><strong>x</strong></em>"},
+		{"italic in bold", "**a *b* c**", "<strong>a <em>b</em> c</strong>"},
+		{"bold in italic", "*a **b** c*", "<em>a <strong>b</strong> c</em>"},
+		{"mixed markers", "_*x*_", "<em><em>x</em></em>"},
+		{"unclosed triple", "***x**", "*<strong>x</strong>"},
+		{"unclosed bold", "**x*", "*<em>x</em>"},
+		{"extra closing star", "**x***", "<strong>x</strong>*"},
+		{"unclosed italic", "*x", "*x"},
+		{"snake case", "snake_case_name", "snake_case_name"},
+	}
+
+	for _, test := range tests {
+		if got := ToHTML(Parse(test.text)); got != test.want {
+			t.Errorf("%s: %q renders as\n%s\nwant\n%s", test.name, test.text, got, test.want)
+		}
+	}
+}
+
+// Tokens may come from storage, so ToHTML cannot trust them
+func TestToHTMLStoredTokens(t *testing.T) {
+	tests := []struct {
+		name  string
+		token Token
+		want  string
+	}{
+		{"javascript link", Token{Type: TokenLink, URL: "javascript:alert(1)", Children: []Token{{Type: TokenText, Text: "x"}}}, "x"},
+		{"protocol relative link", Token{Type: TokenLink, URL: "//evil.com", Children: []Token{{Type: TokenText, Text: "x"}}}, "x"},
+		{"language", Token{Type: TokenCodeBlock, Language: `"><script>`}, `<pre><code class="language-&#34;&gt;&lt;script&gt;"></code></pre>`},
+		{"mention", Token{Type: TokenMention, Text: `a"<b>`}, `<span class="mention" data-username="a&#34;&lt;b&gt;">@a&#34;&lt;b&gt;</span>`},
+	}
+
+	for _, test := range tests {
+		if got := ToHTML([]Token{test.token}); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSafeURL(t *testing.T) {
+	for _, raw := range []string{
+		"javascript:alert(1)",
+		"JAVASCRIPT:alert(1)",
+		"vbscript:x",
+		"data:text/html,<script>",
+		"//evil.com",
+		"/relative",
+		"https://a.com/\x00",
+		"https://a.com/\n",
+		"",
+	} {
+		if url, ok := SafeURL(raw); ok {
+			t.Errorf("SafeURL(%q) = %q, want it rejected", raw, url)
+		}
+	}
+}
+
+func TestEscape(t *testing.T) {
+	text := "*not* _bold_ [x](https://a.com) @bob `code`"
+	if got := ToHTML(Parse(Escape(text))); strings.Contains(got, "<") {
+		t.Errorf("Escaped %q still renders markup: %s", text, got)
+	}
+}
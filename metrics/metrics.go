@@ -0,0 +1,168 @@
+// Package metrics collects the Prometheus metrics of the server. Everything is
+// registered on a registry of its own, so tests can create one per server and
+// read the values back with Gather or by scraping Handler.
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.mongodb.org/mongo-driver/v2/event"
+)
+
+// Ways to authenticate, used as the method label
+const (
+	AuthPassword = "password"
+	AuthSession  = "session"
+	AuthToken    = "token"
+	AuthWebhook  = "webhook"
+)
+
+// Where a message came from, used as the source label
+const (
+	SourceUser    = "user"
+	SourceCommand = "command"
+	SourceWebhook = "webhook"
+)
+
+// Route label of requests that matched no route, so scanners cannot create
+// a series per path
+const UnmatchedRoute = "unmatched"
+
+type Metrics struct {
+	Registry *prometheus.Registry
+
+	HTTPRequests         *prometheus.CounterVec
+	HTTPRequestDuration  *prometheus.HistogramVec
+	HTTPPanics           *prometheus.CounterVec
+	AuthAttempts         *prometheus.CounterVec
+	MessagesCreated      *prometheus.CounterVec
+	MongoCommandDuration *prometheus.HistogramVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+		HTTPRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chat_http_requests_total",
+			Help: "HTTP requests by route pattern and status code.",
+		}, []string{"route", "status"}),
+		HTTPRequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "chat_http_request_duration_seconds",
+			Help:    "HTTP request latency by route pattern and status code.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "status"}),
+		HTTPPanics: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chat_http_panics_total",
+			Help: "Panics recovered while handling a request, by route pattern.",
+		}, []string{"route"}),
+		AuthAttempts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chat_auth_attempts_total",
+			Help: "Authentication attempts by method and result.",
+		}, []string{"method", "result"}),
+		MessagesCreated: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chat_messages_created_total",
+			Help: "Messages created by source.",
+		}, []string{"source"}),
+		MongoCommandDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "chat_mongo_command_duration_seconds",
+			Help:    "MongoDB command latency by command name and result.",
+			Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"command", "result"}),
+	}
+
+	m.Registry.MustRegister(
+		m.HTTPRequests,
+		m.HTTPRequestDuration,
+		m.HTTPPanics,
+		m.AuthAttempts,
+		m.MessagesCreated,
+		m.MongoCommandDuration,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	return m
+}
+
+// Handler serves the metrics in the Prometheus text format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.Registry, promhttp.HandlerOpts{})
+}
+
+// All of the recording methods do nothing on a nil *Metrics, so handlers work
+// without metrics, like in tests
+
+func (m *Metrics) ObserveRequest(route string, status int, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	code := strconv.Itoa(status)
+	m.HTTPRequests.WithLabelValues(route, code).Inc()
+	m.HTTPRequestDuration.WithLabelValues(route, code).Observe(duration.Seconds())
+}
+
+func (m *Metrics) Panic(route string) {
+	if m == nil {
+		return
+	}
+	m.HTTPPanics.WithLabelValues(route).Inc()
+}
+
+func (m *Metrics) AuthAttempt(method string, success bool) {
+	if m == nil {
+		return
+	}
+	m.AuthAttempts.WithLabelValues(method, result(success)).Inc()
+}
+
+func (m *Metrics) MessageCreated(source string) {
+	if m == nil {
+		return
+	}
+	m.MessagesCreated.WithLabelValues(source).Inc()
+}
+
+// MongoMonitor times every command sent to MongoDB, pass it to
+// options.Client().SetMonitor
+func (m *Metrics) MongoMonitor() *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			m.observeMongo(e.CommandName, e.Duration, true)
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			m.observeMongo(e.CommandName, e.Duration, false)
+		},
+	}
+}
+
+func (m *Metrics) observeMongo(command string, duration time.Duration, success bool) {
+	m.MongoCommandDuration.WithLabelValues(command, result(success)).Observe(duration.Seconds())
+}
+
+func result(success bool) string {
+	if success {
+		return "success"
+	}
+	return "failure"
+}
+
+type contextKey struct{}
+
+// NewContext returns a context that carries the metrics, for handlers that
+// only get the request
+func NewContext(ctx context.Context, m *Metrics) context.Context {
+	return context.WithValue(ctx, contextKey{}, m)
+}
+
+// FromContext returns nil if the context has no metrics, which is fine to
+// record on
+func FromContext(ctx context.Context) *Metrics {
+	m, _ := ctx.Value(contextKey{}).(*Metrics)
+	return m
+}
@@ -4,10 +4,16 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"time"
 
+	"github.com/SomeSuperCoder/global-chat/logging"
+	"github.com/SomeSuperCoder/global-chat/metrics"
+	"github.com/SomeSuperCoder/global-chat/models"
 	"github.com/SomeSuperCoder/global-chat/repository"
+	"github.com/SomeSuperCoder/global-chat/tracing"
 	"github.com/SomeSuperCoder/global-chat/utils"
-	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const UserAuthKey = "userAuth"
@@ -21,15 +27,53 @@ func ExtractUserAuth(r *http.Request) *repository.UserAuth {
 	return userAuth
 }
 
-func AuthMiddleware(next http.HandlerFunc, db *mongo.Database) http.HandlerFunc {
+// Scope that no API token has, for routes that need a browser session
+const SessionOnly models.TokenScope = ""
+
+// AuthMiddleware accepts a session cookie with its CSRF token, or an API
+// token that has the given scope
+func AuthMiddleware(next http.HandlerFunc, sessions repository.SessionStore, scope models.TokenScope) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		userAuth, err := utils.Authorize(r, db)
+		method := metrics.AuthSession
+		if utils.HasBearerToken(r) {
+			method = metrics.AuthToken
+		}
+
+		ctx, span := tracer.Start(r.Context(), "auth", trace.WithAttributes(
+			attribute.String("auth.method", method),
+			attribute.String("auth.scope", string(scope)),
+		))
+		userAuth, err := utils.Authorize(r.WithContext(ctx), sessions)
+		tracing.End(span, err)
+
+		metrics.FromContext(r.Context()).AuthAttempt(method, err == nil)
+
 		if err != nil {
 			http.Error(w, fmt.Errorf("Failed to authorize: %w", err).Error(), http.StatusUnauthorized)
 			return
 		}
 
-		ctx := r.Context()
+		if userAuth.Token != nil && scope == SessionOnly {
+			http.Error(w, "API tokens cannot be used here", http.StatusForbidden)
+			return
+		}
+		if userAuth.Token != nil && !userAuth.Token.Allows(scope) {
+			http.Error(w, fmt.Sprintf("API token lacks the %s scope", scope), http.StatusForbidden)
+			return
+		}
+
+		// Sessions are dropped on ban, but a ban may also be issued mid-request
+		if models.ActiveBan(userAuth.Sanctions, time.Now()) != nil {
+			http.Error(w, "User is banned", http.StatusForbidden)
+			return
+		}
+
+		// For the log lines of the request
+		if request := logging.FromContext(r.Context()); request != nil {
+			request.UserID = userAuth.UserID.Hex()
+		}
+
+		ctx = r.Context()
 		ctx = context.WithValue(ctx, UserAuthKey, userAuth)
 
 		r = r.WithContext(ctx)
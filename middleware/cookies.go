@@ -0,0 +1,15 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/SomeSuperCoder/global-chat/cookies"
+)
+
+// CookieMiddleware makes the cookie policy available to the handlers and the
+// auth check through the context
+func CookieMiddleware(next http.Handler, policy *cookies.Policy) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(cookies.NewContext(r.Context(), policy)))
+	})
+}
@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const CSRFTokenHeader = "X-CSRF-Token"
+
+// Methods and request headers a cross-origin frontend may use
+var (
+	corsMethods = "GET, POST, PUT, PATCH, DELETE"
+	corsHeaders = "Authorization, Content-Type, " + CSRFTokenHeader + ", " + RequestIDHeader
+)
+
+type CORSConfig struct {
+	// Like "https://chat.example.com" or "https://*.example.com" for every
+	// subdomain. "*" allows any origin, but never with credentials.
+	AllowedOrigins []string
+	// Lets browsers send the session cookie
+	AllowCredentials bool
+	// Response headers the frontend may read
+	ExposedHeaders []string
+	// How long browsers may cache a preflight
+	MaxAge time.Duration
+}
+
+// CORSMiddleware lets the allowed origins call the API from a browser and
+// answers their preflights. Requests from other origins are served without
+// CORS headers, so browsers keep their responses from the page.
+//
+// Cross-origin requests with the session cookie still need the X-CSRF-Token
+// header. Its value is sent in the header of the same name on login, because
+// the page cannot read the cookie of another origin.
+func CORSMiddleware(next http.Handler, cfg CORSConfig) http.Handler {
+	exposed := strings.Join(cfg.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(cfg.MaxAge.Seconds()))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		header := w.Header()
+		header.Add("Vary", "Origin")
+		if preflight {
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
+		}
+
+		allowed, anyOrigin := matchOrigin(cfg.AllowedOrigins, origin)
+		if allowed {
+			if anyOrigin {
+				header.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				header.Set("Access-Control-Allow-Origin", origin)
+				if cfg.AllowCredentials {
+					header.Set("Access-Control-Allow-Credentials", "true")
+				}
+			}
+		}
+
+		if preflight {
+			if allowed {
+				header.Set("Access-Control-Allow-Methods", corsMethods)
+				header.Set("Access-Control-Allow-Headers", corsHeaders)
+				header.Set("Access-Control-Max-Age", maxAge)
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if allowed && exposed != "" {
+			header.Set("Access-Control-Expose-Headers", exposed)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Reports whether the origin is allowed and whether that is only through "*"
+func matchOrigin(patterns []string, origin string) (bool, bool) {
+	if origin == "" {
+		return false, false
+	}
+	origin = strings.ToLower(origin)
+
+	anyOrigin := false
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSuffix(pattern, "/"))
+		switch {
+		case pattern == "*":
+			anyOrigin = true
+		case pattern == origin:
+			return true, false
+		case matchWildcard(pattern, origin):
+			return true, false
+		}
+	}
+	return anyOrigin, anyOrigin
+}
+
+// "https://*.example.com" matches "https://a.example.com" and
+// "https://a.b.example.com", but not "https://example.com"
+func matchWildcard(pattern, origin string) bool {
+	scheme, host, ok := strings.Cut(pattern, "://*.")
+	if !ok {
+		return false
+	}
+	prefix := scheme + "://"
+	if !strings.HasPrefix(origin, prefix) {
+		return false
+	}
+
+	subdomain, ok := strings.CutSuffix(strings.TrimPrefix(origin, prefix), "."+host)
+	return ok && subdomain != "" && !strings.ContainsAny(subdomain, ":/")
+}
@@ -1,33 +1,103 @@
 package middleware
 
 import (
-	"fmt"
 	"net/http"
 	"time"
+
+	"github.com/SomeSuperCoder/global-chat/logging"
+	"github.com/SomeSuperCoder/global-chat/metrics"
+	"github.com/SomeSuperCoder/global-chat/utils"
+	"github.com/sirupsen/logrus"
 )
 
+const RequestIDHeader = "X-Request-ID"
+
+// Longer request IDs from clients are replaced
+const maxRequestIDLength = 128
+
+var log = logging.Package("middleware")
+
+// LoggerMiddleware gives every request an ID, taken from the X-Request-ID
+// header if the client sent a sane one, and logs it once it was handled
 func LoggerMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 
+		request := logging.FromContext(r.Context())
+		if request == nil {
+			request = &logging.Request{}
+			r = r.WithContext(logging.NewContext(r.Context(), request))
+		}
+		request.ID = r.Header.Get(RequestIDHeader)
+		if !validRequestID(request.ID) {
+			request.ID = utils.GenerateToken(12)
+		}
+		w.Header().Set(RequestIDHeader, request.ID)
+
 		wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
 
 		next.ServeHTTP(wrapped, r)
 
-		duration := time.Since(start)
+		resolveRoute(request, r)
 
-		fmt.Printf("%s %s took %v - %d %s\n", r.Method, r.URL.Path, duration, wrapped.statusCode, http.StatusText(wrapped.statusCode))
+		entry := log.WithContext(r.Context()).WithFields(logrus.Fields{
+			"method":      r.Method,
+			"path":        r.URL.Path,
+			"status":      wrapped.statusCode,
+			"duration_ms": float64(time.Since(start).Microseconds()) / 1000,
+			"bytes":       wrapped.bytes,
+		})
+		if wrapped.statusCode >= http.StatusInternalServerError {
+			entry.Warn("Request failed")
+		} else {
+			entry.Info("Request handled")
+		}
 	})
 }
 
+// Printable ASCII only, so the ID cannot break log lines or headers
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < '!' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// The mux sets the pattern on the request it was given, nested muxes report
+// theirs through RoutePattern
+func resolveRoute(request *logging.Request, r *http.Request) {
+	if request.Route == "" {
+		request.Route = r.Pattern
+	}
+	if request.Route == "" {
+		request.Route = metrics.UnmatchedRoute
+	}
+}
+
 // Interceptor sturct
 type responseWriter struct {
 	http.ResponseWriter
-	statusCode int
+	statusCode  int
+	bytes       int
+	wroteHeader bool
 }
 
 // Intercept the status code
 func (rw *responseWriter) WriteHeader(code int) {
 	rw.statusCode = code
+	rw.wroteHeader = true
 	rw.ResponseWriter.WriteHeader(code)
 }
+
+// Count the bytes of the body
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	rw.wroteHeader = true
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += n
+	return n, err
+}
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/SomeSuperCoder/global-chat/logging"
+	"github.com/SomeSuperCoder/global-chat/metrics"
+)
+
+// MetricsMiddleware records every request by route pattern and status, and
+// makes the metrics available to handlers through the context
+func MetricsMiddleware(next http.Handler, m *metrics.Metrics) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		// Shared with the logger, which may have stored it already
+		ctx := metrics.NewContext(r.Context(), m)
+		request := logging.FromContext(ctx)
+		if request == nil {
+			request = &logging.Request{}
+			ctx = logging.NewContext(ctx, request)
+		}
+		r = r.WithContext(ctx)
+
+		wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+
+		next.ServeHTTP(wrapped, r)
+
+		resolveRoute(request, r)
+		m.ObserveRequest(request.Route, wrapped.statusCode, time.Since(start))
+	})
+}
+
+// RoutePattern wraps a mux that is mounted with http.StripPrefix, so the full
+// pattern like "POST /messages/{id}" is recorded instead of "/messages/"
+func RoutePattern(prefix string, mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Deferred, so a request that panics still gets its route
+		defer setRoute(prefix, r)
+
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func setRoute(prefix string, r *http.Request) {
+	request := logging.FromContext(r.Context())
+	if request == nil {
+		return
+	}
+	if r.Pattern == "" {
+		request.Route = metrics.UnmatchedRoute
+		return
+	}
+
+	method, path, ok := strings.Cut(r.Pattern, " ")
+	if !ok {
+		request.Route = prefix + r.Pattern
+		return
+	}
+	request.Route = method + " " + prefix + path
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SomeSuperCoder/global-chat/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	messages := http.NewServeMux()
+	messages.HandleFunc("GET /{id}", func(w http.ResponseWriter, r *http.Request) {})
+	messages.HandleFunc("POST /", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {})
+	mux.Handle("/messages/", http.StripPrefix("/messages", RoutePattern("/messages", messages)))
+
+	m := metrics.New()
+	handler := MetricsMiddleware(mux, m)
+
+	for _, request := range []struct{ method, path string }{
+		{http.MethodGet, "/health"},
+		{http.MethodGet, "/messages/1"},
+		{http.MethodGet, "/messages/2"},
+		{http.MethodPost, "/messages/"},
+		// Scanners
+		{http.MethodGet, "/wp-login.php"},
+		{http.MethodGet, "/.env"},
+		{http.MethodGet, "/messages/1/x"},
+	} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(request.method, request.path, nil))
+	}
+
+	for _, want := range []struct {
+		route, status string
+		count         float64
+	}{
+		{"GET /health", "200", 1},
+		{"GET /messages/{id}", "200", 2},
+		{"POST /messages/", "201", 1},
+		{metrics.UnmatchedRoute, "404", 2},
+		// Only "POST /" would take the path
+		{metrics.UnmatchedRoute, "405", 1},
+	} {
+		got := testutil.ToFloat64(m.HTTPRequests.WithLabelValues(want.route, want.status))
+		if got != want.count {
+			t.Errorf("%s %s: counted %v requests, want %v", want.route, want.status, got, want.count)
+		}
+	}
+
+	// One series per route, none per path
+	if series := testutil.CollectAndCount(m.HTTPRequests); series != 5 {
+		t.Errorf("Got %d series, want 5", series)
+	}
+}
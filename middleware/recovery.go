@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/SomeSuperCoder/global-chat/logging"
+	"github.com/SomeSuperCoder/global-chat/metrics"
+)
+
+type PanicResponse struct {
+	Error     string `json:"error"`
+	RequestID string `json:"request_id,omitempty"`
+	// Only set in debug mode
+	Panic string `json:"panic,omitempty"`
+}
+
+// RecoveryMiddleware turns a panic in a handler into a 500 for that request
+// instead of dropping the connection. The panic is logged with its stack and
+// counted. With debug set the panic message is sent to the client, which
+// can leak internals and is meant for development only.
+//
+// Has to run inside the logger and metrics middlewares, so the failed request
+// is still logged and recorded with its 500.
+func RecoveryMiddleware(next http.Handler, m *metrics.Metrics, debugMode bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			// The handler asked for the connection to be dropped
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+
+			request := logging.FromContext(r.Context())
+			route := metrics.UnmatchedRoute
+			if request != nil {
+				resolveRoute(request, r)
+				route = request.Route
+			}
+
+			log.WithContext(r.Context()).
+				WithField("stack", string(debug.Stack())).
+				Errorf("Recovered from a panic: %v", recovered)
+			m.Panic(route)
+
+			// Part of the response is out already, nothing sane can follow
+			if wrapped.wroteHeader {
+				return
+			}
+
+			response := &PanicResponse{Error: "Internal server error"}
+			if request != nil {
+				response.RequestID = request.ID
+			}
+			if debugMode {
+				response.Panic = fmt.Sprint(recovered)
+			}
+
+			responseString, err := json.Marshal(response)
+			if err != nil {
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("X-Content-Type-Options", "nosniff")
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintln(w, string(responseString))
+		}()
+
+		next.ServeHTTP(wrapped, r)
+	})
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/SomeSuperCoder/global-chat/models"
+	"github.com/SomeSuperCoder/global-chat/rbac"
+)
+
+// These need to be wrapped with an auth middleware
+
+func RequireRole(next http.HandlerFunc, role models.Role) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userAuth := ExtractUserAuth(r)
+
+		if !userAuth.Role.AtLeast(role) {
+			http.Error(w, "Insufficient role", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
+
+func RequirePermission(next http.HandlerFunc, permission rbac.Permission) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userAuth := ExtractUserAuth(r)
+
+		if !rbac.Can(userAuth.Role, permission) {
+			http.Error(w, "Insufficient permissions", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SomeSuperCoder/global-chat/models"
+	"github.com/SomeSuperCoder/global-chat/rbac"
+	"github.com/SomeSuperCoder/global-chat/repository"
+)
+
+func serveAs(handler http.HandlerFunc, role models.Role) int {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r = r.WithContext(context.WithValue(r.Context(), UserAuthKey, &repository.UserAuth{Role: role}))
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w.Code
+}
+
+func TestRequireRole(t *testing.T) {
+	handler := RequireRole(func(w http.ResponseWriter, r *http.Request) {}, models.RoleModerator)
+
+	tests := []struct {
+		role models.Role
+		want int
+	}{
+		{models.RoleUser, http.StatusForbidden},
+		{models.RoleModerator, http.StatusOK},
+		{models.RoleAdmin, http.StatusOK},
+		// Unknown roles count as users
+		{models.Role("owner"), http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		if got := serveAs(handler, test.role); got != test.want {
+			t.Errorf("%s: got %d, want %d", test.role, got, test.want)
+		}
+	}
+}
+
+func TestRequirePermission(t *testing.T) {
+	handler := RequirePermission(func(w http.ResponseWriter, r *http.Request) {}, rbac.ManageUsers)
+
+	if got := serveAs(handler, models.RoleModerator); got != http.StatusForbidden {
+		t.Errorf("moderator: got %d, want 403", got)
+	}
+	if got := serveAs(handler, models.RoleAdmin); got != http.StatusOK {
+		t.Errorf("admin: got %d, want 200", got)
+	}
+}
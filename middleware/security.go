@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type SecurityConfig struct {
+	// Content-Security-Policy without frame-ancestors
+	ContentSecurityPolicy string
+	// Like "'none'" or "'self' https://chat.example.com"
+	FrameAncestors string
+	ReferrerPolicy string
+	// Strict-Transport-Security is left out if zero
+	HSTSMaxAge            time.Duration
+	HSTSIncludeSubdomains bool
+}
+
+// SecurityHeadersMiddleware sets the security headers on every response.
+// Handlers may still replace them for a single response.
+func SecurityHeadersMiddleware(next http.Handler, cfg SecurityConfig) http.Handler {
+	csp := cfg.ContentSecurityPolicy
+	if cfg.FrameAncestors != "" {
+		if csp != "" {
+			csp += "; "
+		}
+		csp += "frame-ancestors " + cfg.FrameAncestors
+	}
+
+	hsts := ""
+	if cfg.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.Itoa(int(cfg.HSTSMaxAge.Seconds()))
+		if cfg.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := w.Header()
+		if csp != "" {
+			header.Set("Content-Security-Policy", csp)
+		}
+		// For browsers without frame-ancestors
+		if cfg.FrameAncestors == "'none'" {
+			header.Set("X-Frame-Options", "DENY")
+		}
+		if cfg.ReferrerPolicy != "" {
+			header.Set("Referrer-Policy", cfg.ReferrerPolicy)
+		}
+		// Browsers ignore it over plain HTTP
+		if hsts != "" {
+			header.Set("Strict-Transport-Security", hsts)
+		}
+		header.Set("X-Content-Type-Options", "nosniff")
+
+		next.ServeHTTP(w, r)
+	})
+}
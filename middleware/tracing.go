@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/SomeSuperCoder/global-chat/logging"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/SomeSuperCoder/global-chat/middleware")
+
+// TracingMiddleware starts a server span for every request, continuing the
+// trace from the traceparent header if the caller sent one. Has to run inside
+// LoggerMiddleware, which provides the route once the request was routed.
+func TracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+				semconv.UserAgentOriginal(r.UserAgent()),
+			),
+		)
+		defer span.End()
+
+		wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		r = r.WithContext(ctx)
+
+		next.ServeHTTP(wrapped, r)
+
+		if request := logging.FromContext(ctx); request != nil {
+			resolveRoute(request, r)
+			// Patterns of the nested muxes already start with the method
+			name := request.Route
+			if !strings.Contains(name, " ") {
+				name = r.Method + " " + name
+			}
+			span.SetName(name)
+			span.SetAttributes(semconv.HTTPRoute(request.Route))
+			if request.UserID != "" {
+				span.SetAttributes(semconv.UserID(request.UserID))
+			}
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(wrapped.statusCode))
+		if wrapped.statusCode >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(wrapped.statusCode))
+		}
+	})
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type AuditAction string
+
+const (
+	AuditUserRegistered   AuditAction = "user.registered"
+	AuditLoginSucceeded   AuditAction = "auth.login_succeeded"
+	AuditLoginFailed      AuditAction = "auth.login_failed"
+	AuditLogout           AuditAction = "auth.logout"
+	AuditSessionsRevoked  AuditAction = "auth.sessions_revoked"
+	AuditRoleChanged      AuditAction = "user.role_changed"
+	AuditUserBanned       AuditAction = "user.banned"
+	AuditUserUnbanned     AuditAction = "user.unbanned"
+	AuditUserMuted        AuditAction = "user.muted"
+	AuditUserUnmuted      AuditAction = "user.unmuted"
+	AuditUserTimedOut     AuditAction = "user.timed_out"
+	AuditMessageEdited    AuditAction = "message.edited"
+	AuditMessageHidden    AuditAction = "message.hidden"
+	AuditMessageDeleted   AuditAction = "message.deleted"
+	AuditReportsDismissed AuditAction = "reports.dismissed"
+	AuditTokenCreated     AuditAction = "token.created"
+	AuditTokenRevoked     AuditAction = "token.revoked"
+	AuditBotCreated       AuditAction = "bot.created"
+	AuditWebhookCreated   AuditAction = "webhook.created"
+	AuditWebhookDeleted   AuditAction = "webhook.deleted"
+	AuditTopicChanged     AuditAction = "topic.changed"
+)
+
+// A zero actor means the action was taken by the system itself
+type AuditEvent struct {
+	ID        bson.ObjectID `bson:"_id,omitempty" json:"_id"`
+	Action    AuditAction   `bson:"action" json:"action"`
+	Actor     bson.ObjectID `bson:"actor,omitempty" json:"actor,omitzero"`
+	Username  string        `bson:"username,omitempty" json:"username,omitempty"`
+	Target    bson.ObjectID `bson:"target,omitempty" json:"target,omitzero"`
+	MessageID bson.ObjectID `bson:"message_id,omitempty" json:"message_id,omitzero"`
+	Reason    string        `bson:"reason,omitempty" json:"reason,omitempty"`
+	ExpiresAt *time.Time    `bson:"expires_at,omitempty" json:"expires_at,omitempty"`
+	IP        string        `bson:"ip" json:"ip"`
+	UserAgent string        `bson:"user_agent" json:"user_agent"`
+	CratedAt  time.Time     `bson:"created_at" json:"created_at"`
+}
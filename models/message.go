@@ -3,12 +3,21 @@ package models
 import (
 	"time"
 
+	"github.com/SomeSuperCoder/global-chat/markdown"
 	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
 type Message struct {
-	ID       bson.ObjectID `bson:"_id,omitempty" json:"_id"`
-	Author   bson.ObjectID `bson:"author" json:"author"`
-	Text     string        `bson:"text" json:"text"`
-	CratedAt time.Time     `bson:"created_at" json:"created_at"`
+	ID       bson.ObjectID     `bson:"_id,omitempty" json:"_id"`
+	Author   bson.ObjectID     `bson:"author" json:"author"`
+	Text     string            `bson:"text" json:"text"`
+	Rendered markdown.Rendered `bson:"rendered" json:"rendered"`
+	Hidden   bool              `bson:"hidden" json:"hidden"`
+	CratedAt time.Time         `bson:"created_at" json:"created_at"`
+}
+
+// Fields left nil are not changed
+type MessageUpdate struct {
+	Text     *string            `bson:"text,omitempty"`
+	Rendered *markdown.Rendered `bson:"rendered,omitempty"`
 }
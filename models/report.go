@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type ReportCategory string
+
+const (
+	ReportSpam       ReportCategory = "spam"
+	ReportHarassment ReportCategory = "harassment"
+	ReportHate       ReportCategory = "hate"
+	ReportNSFW       ReportCategory = "nsfw"
+	ReportOther      ReportCategory = "other"
+)
+
+type ReportResolution string
+
+const (
+	ResolutionDismissed        ReportResolution = "dismissed"
+	ResolutionMessageDeleted   ReportResolution = "message_deleted"
+	ResolutionAuthorSanctioned ReportResolution = "author_sanctioned"
+)
+
+type Report struct {
+	ID         bson.ObjectID    `bson:"_id,omitempty" json:"_id"`
+	MessageID  bson.ObjectID    `bson:"message_id" json:"message_id"`
+	Reporter   bson.ObjectID    `bson:"reporter" json:"reporter"`
+	Category   ReportCategory   `bson:"category" json:"category"`
+	Reason     string           `bson:"reason" json:"reason"`
+	CratedAt   time.Time        `bson:"created_at" json:"created_at"`
+	Resolution ReportResolution `bson:"resolution,omitempty" json:"resolution,omitempty"`
+	ResolvedBy bson.ObjectID    `bson:"resolved_by,omitempty" json:"resolved_by,omitzero"`
+	ResolvedAt *time.Time       `bson:"resolved_at" json:"resolved_at"`
+}
+
+// Open reports of a single message, as shown in the moderation queue
+type ReportGroup struct {
+	MessageID       bson.ObjectID    `bson:"_id" json:"message_id"`
+	Count           int64            `bson:"count" json:"count"`
+	Categories      []ReportCategory `bson:"categories" json:"categories"`
+	FirstReportedAt time.Time        `bson:"first_reported_at" json:"first_reported_at"`
+	LastReportedAt  time.Time        `bson:"last_reported_at" json:"last_reported_at"`
+	Message         *Message         `bson:"message" json:"message"`
+}
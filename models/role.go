@@ -0,0 +1,32 @@
+package models
+
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+var roleLevels = map[Role]int{
+	RoleUser:      1,
+	RoleModerator: 2,
+	RoleAdmin:     3,
+}
+
+func (r Role) Valid() bool {
+	_, ok := roleLevels[r]
+	return ok
+}
+
+// Users created before roles existed have no role stored and count as regular users
+func (r Role) Level() int {
+	if level, ok := roleLevels[r]; ok {
+		return level
+	}
+	return roleLevels[RoleUser]
+}
+
+func (r Role) AtLeast(other Role) bool {
+	return r.Level() >= other.Level()
+}
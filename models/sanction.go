@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type SanctionKind string
+
+const (
+	SanctionBan     SanctionKind = "ban"
+	SanctionMute    SanctionKind = "mute"
+	SanctionTimeout SanctionKind = "timeout"
+)
+
+type Sanction struct {
+	Kind      SanctionKind  `bson:"kind" json:"kind"`
+	Reason    string        `bson:"reason" json:"reason"`
+	IssuedBy  bson.ObjectID `bson:"issued_by" json:"issued_by"`
+	CratedAt  time.Time     `bson:"created_at" json:"created_at"`
+	ExpiresAt *time.Time    `bson:"expires_at" json:"expires_at"`
+	LiftedAt  *time.Time    `bson:"lifted_at" json:"lifted_at"`
+}
+
+func (s *Sanction) Active(now time.Time) bool {
+	if s.LiftedAt != nil {
+		return false
+	}
+	return s.ExpiresAt == nil || s.ExpiresAt.After(now)
+}
+
+// Returns the active sanction of the given kinds that lasts the longest
+func ActiveSanction(sanctions []Sanction, now time.Time, kinds ...SanctionKind) *Sanction {
+	var found *Sanction
+	for i := range sanctions {
+		s := &sanctions[i]
+		if !s.Active(now) || !containsKind(kinds, s.Kind) {
+			continue
+		}
+		if found == nil || s.ExpiresAt == nil || (found.ExpiresAt != nil && s.ExpiresAt.After(*found.ExpiresAt)) {
+			found = s
+		}
+	}
+	return found
+}
+
+// Mutes and timeouts both prevent posting
+func ActiveMute(sanctions []Sanction, now time.Time) *Sanction {
+	return ActiveSanction(sanctions, now, SanctionMute, SanctionTimeout)
+}
+
+func ActiveBan(sanctions []Sanction, now time.Time) *Sanction {
+	return ActiveSanction(sanctions, now, SanctionBan)
+}
+
+func containsKind(kinds []SanctionKind, kind SanctionKind) bool {
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
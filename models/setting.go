@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Setting keys
+const (
+	SettingTopic = "topic"
+)
+
+// Chat-wide value that can be changed at runtime, like the topic
+type Setting struct {
+	Key       string        `bson:"_id" json:"key"`
+	Value     string        `bson:"value" json:"value"`
+	UpdatedBy bson.ObjectID `bson:"updated_by" json:"updated_by"`
+	UpdatedAt time.Time     `bson:"updated_at" json:"updated_at"`
+}
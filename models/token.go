@@ -0,0 +1,47 @@
+package models
+
+import (
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type TokenScope string
+
+const (
+	ScopeReadMessages TokenScope = "messages:read"
+	ScopePostMessages TokenScope = "messages:post"
+	// Moderation and user management, still limited by the role of the owner
+	ScopeAdmin TokenScope = "admin"
+)
+
+func (s TokenScope) Valid() bool {
+	switch s {
+	case ScopeReadMessages, ScopePostMessages, ScopeAdmin:
+		return true
+	}
+	return false
+}
+
+// Personal API token. Only the SHA-256 of the token is stored, the token
+// itself is shown once when it is created.
+type APIToken struct {
+	ID          bson.ObjectID `bson:"_id,omitempty" json:"_id"`
+	UserID      bson.ObjectID `bson:"user_id" json:"user_id"`
+	Name        string        `bson:"name" json:"name"`
+	Prefix      string        `bson:"prefix" json:"prefix"` // Start of the token, to tell tokens apart
+	HashedToken string        `bson:"hashed_token" json:"-"`
+	Scopes      []TokenScope  `bson:"scopes" json:"scopes"`
+	CratedAt    time.Time     `bson:"created_at" json:"created_at"`
+	ExpiresAt   *time.Time    `bson:"expires_at" json:"expires_at"` // nil means it never expires
+}
+
+func (t *APIToken) Expired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
+
+// The admin scope includes all others
+func (t *APIToken) Allows(scope TokenScope) bool {
+	return slices.Contains(t.Scopes, scope) || slices.Contains(t.Scopes, ScopeAdmin)
+}
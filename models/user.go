@@ -15,7 +15,11 @@ type UserSession struct {
 type User struct {
 	ID             bson.ObjectID `bson:"_id,omitempty" json:"_id"`
 	Username       string        `bson:"username" json:"username"`
+	UsernameKey    string        `bson:"username_key" json:"-"` // See usernames.Key
 	HashedPassword string        `bson:"hashed_password" json:"hashed_password"`
+	Role           Role          `bson:"role" json:"role"`
+	IsBot          bool          `bson:"is_bot" json:"is_bot"` // Bots have no password and post through webhooks
 	Sessions       []UserSession `bson:"sessions" json:"sessions"`
+	Sanctions      []Sanction    `bson:"sanctions" json:"sanctions,omitempty"`
 	CratedAt       time.Time     `bson:"created_at" json:"created_at"`
 }
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Incoming webhook that posts as a bot. The URL token is stored hashed like
+// API tokens, the signing secret has to be kept as is to verify signatures.
+type IncomingWebhook struct {
+	ID          bson.ObjectID `bson:"_id,omitempty" json:"_id"`
+	BotID       bson.ObjectID `bson:"bot_id" json:"bot_id"`
+	Name        string        `bson:"name" json:"name"`
+	HashedToken string        `bson:"hashed_token" json:"-"`
+	Secret      string        `bson:"secret" json:"-"`
+	RateLimit   int           `bson:"rate_limit" json:"rate_limit"` // Messages per minute
+	CreatedBy   bson.ObjectID `bson:"created_by" json:"created_by"`
+	CratedAt    time.Time     `bson:"created_at" json:"created_at"`
+}
+
+type WebhookEvent string
+
+const (
+	EventMessageCreated WebhookEvent = "message.created"
+	EventMessageUpdated WebhookEvent = "message.updated"
+	EventMessageDeleted WebhookEvent = "message.deleted"
+)
+
+func (e WebhookEvent) Valid() bool {
+	switch e {
+	case EventMessageCreated, EventMessageUpdated, EventMessageDeleted:
+		return true
+	}
+	return false
+}
+
+// Receives the subscribed events as signed JSON POSTs
+type OutgoingWebhook struct {
+	ID        bson.ObjectID  `bson:"_id,omitempty" json:"_id"`
+	Name      string         `bson:"name" json:"name"`
+	URL       string         `bson:"url" json:"url"`
+	Secret    string         `bson:"secret" json:"-"`
+	Events    []WebhookEvent `bson:"events" json:"events"`
+	CreatedBy bson.ObjectID  `bson:"created_by" json:"created_by"`
+	CratedAt  time.Time      `bson:"created_at" json:"created_at"`
+}
+
+func (h *OutgoingWebhook) Subscribed(event WebhookEvent) bool {
+	return slices.Contains(h.Events, event)
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	// Gave up after the last attempt, only retried by hand
+	DeliveryDead DeliveryStatus = "dead"
+)
+
+func (s DeliveryStatus) Valid() bool {
+	switch s {
+	case DeliveryPending, DeliveryDelivered, DeliveryDead:
+		return true
+	}
+	return false
+}
+
+// One event queued for one outgoing webhook. The payload is the exact body
+// that is signed and sent on every attempt.
+type WebhookDelivery struct {
+	ID             bson.ObjectID  `bson:"_id,omitempty" json:"_id"`
+	WebhookID      bson.ObjectID  `bson:"webhook_id" json:"webhook_id"`
+	Event          WebhookEvent   `bson:"event" json:"event"`
+	Payload        string         `bson:"payload" json:"payload"`
+	Status         DeliveryStatus `bson:"status" json:"status"`
+	Attempts       int            `bson:"attempts" json:"attempts"`
+	NextAttemptAt  time.Time      `bson:"next_attempt_at" json:"next_attempt_at"`
+	LastStatusCode int            `bson:"last_status_code" json:"last_status_code,omitempty"`
+	LastError      string         `bson:"last_error" json:"last_error,omitempty"`
+	CratedAt       time.Time      `bson:"created_at" json:"created_at"`
+	DeliveredAt    *time.Time     `bson:"delivered_at" json:"delivered_at,omitempty"`
+}
@@ -0,0 +1,113 @@
+// Package openapi embeds the OpenAPI 3 document of the API. Routes look up
+// their operation when they are registered, so a route missing from the
+// document fails at startup, and request bodies are checked against the
+// schema of the operation before the handler runs.
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+//go:embed openapi.json
+var document []byte
+
+type Document struct {
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components struct {
+		Schemas map[string]*Schema `json:"schemas"`
+	} `json:"components"`
+}
+
+type Operation struct {
+	OperationID string       `json:"operationId"`
+	RequestBody *RequestBody `json:"requestBody"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema holds the part of the JSON Schema dialect that request bodies use
+type Schema struct {
+	Ref        string             `json:"$ref"`
+	Type       string             `json:"type"`
+	Nullable   bool               `json:"nullable"`
+	Properties map[string]*Schema `json:"properties"`
+	Required   []string           `json:"required"`
+	Items      *Schema            `json:"items"`
+	Enum       []any              `json:"enum"`
+	Pattern    string             `json:"pattern"`
+	MinLength  *int               `json:"minLength"`
+	MaxLength  *int               `json:"maxLength"`
+	MinItems   *int               `json:"minItems"`
+	MaxItems   *int               `json:"maxItems"`
+	Minimum    *float64           `json:"minimum"`
+	Maximum    *float64           `json:"maximum"`
+}
+
+// Load parses the embedded document
+func Load() (*Document, error) {
+	var doc Document
+	err := json.Unmarshal(document, &doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the OpenAPI document: %w", err)
+	}
+	return &doc, nil
+}
+
+// Handler serves the embedded document as is
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(document)
+	})
+}
+
+// Operation finds the operation of a route pattern like "POST /messages/{id}".
+// A pattern without a method matches any operation of the path.
+func (d *Document) Operation(pattern string) (*Operation, bool) {
+	method, path, ok := strings.Cut(pattern, " ")
+	if !ok {
+		path = pattern
+		method = ""
+	}
+
+	operations, ok := d.Paths[path]
+	if !ok {
+		return nil, false
+	}
+	if method == "" {
+		for _, operation := range operations {
+			return operation, true
+		}
+		return nil, false
+	}
+
+	operation, ok := operations[strings.ToLower(method)]
+	return operation, ok
+}
+
+// Only local references into the components are supported
+func (d *Document) resolve(schema *Schema) (*Schema, error) {
+	for schema.Ref != "" {
+		name, ok := strings.CutPrefix(schema.Ref, "#/components/schemas/")
+		if !ok {
+			return nil, fmt.Errorf("unsupported reference %q", schema.Ref)
+		}
+		resolved, ok := d.Components.Schemas[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown schema %q", name)
+		}
+		schema = resolved
+	}
+	return schema, nil
+}
@@ -0,0 +1,320 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/SomeSuperCoder/global-chat/policy"
+	"github.com/SomeSuperCoder/global-chat/utils"
+)
+
+// Larger bodies are refused before they are parsed
+const maxBodySize = 1 << 20
+
+// Codes of schema violations, next to the ones of the registration policy
+const (
+	CodeInvalidType  = "invalid_type"
+	CodeInvalidValue = "invalid_value"
+	CodeTooSmall     = "too_small"
+	CodeTooLarge     = "too_large"
+	CodeTooFew       = "too_few"
+	CodeTooMany      = "too_many"
+)
+
+// ValidateBody checks the request body against the schema of the operation
+// and answers with the violations instead of calling next. JSON bodies are
+// matched by the Content-Type header, or by the only media type of the
+// operation if the header names none of them, since the handlers decode the
+// body without looking at the header.
+func (d *Document) ValidateBody(operation *Operation, next http.Handler) http.Handler {
+	if operation == nil || operation.RequestBody == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mediaType, content, ok := operation.RequestBody.media(r.Header.Get("Content-Type"))
+		if !ok {
+			http.Error(w, "Unsupported content type", http.StatusUnsupportedMediaType)
+			return
+		}
+
+		// Read the body, the handler gets it back untouched
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+		if utils.CheckError(w, err, "Failed to read the body", http.StatusRequestEntityTooLarge) {
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		if len(bytes.TrimSpace(body)) == 0 {
+			if operation.RequestBody.Required {
+				http.Error(w, "Missing request body", http.StatusBadRequest)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Parse
+		var value any
+		switch mediaType {
+		case "application/json":
+			decoder := json.NewDecoder(bytes.NewReader(body))
+			decoder.UseNumber()
+			err = decoder.Decode(&value)
+		case "application/x-www-form-urlencoded":
+			value, err = d.parseForm(body, content.Schema)
+		default:
+			err = fmt.Errorf("cannot validate %s", mediaType)
+		}
+		if utils.CheckError(w, err, "Failed to parse the body", http.StatusBadRequest) {
+			return
+		}
+
+		// Validate
+		var fieldErrors []policy.FieldError
+		err = d.validate(content.Schema, value, "", &fieldErrors)
+		if utils.CheckError(w, err, "Invalid schema", http.StatusInternalServerError) {
+			return
+		}
+		if len(fieldErrors) > 0 {
+			writeValidationError(w, &policy.ValidationError{Errors: fieldErrors})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// The media type named by the header, or the only one the operation takes
+func (b *RequestBody) media(header string) (string, MediaType, bool) {
+	mediaType, _, _ := mime.ParseMediaType(header)
+	if content, ok := b.Content[mediaType]; ok {
+		return mediaType, content, true
+	}
+	if len(b.Content) == 1 {
+		for mediaType, content := range b.Content {
+			return mediaType, content, true
+		}
+	}
+	return "", MediaType{}, false
+}
+
+// Form fields are strings, they are converted to the types of the schema
+// so forms and JSON are checked the same way
+func (d *Document) parseForm(body []byte, schema *Schema) (map[string]any, error) {
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, err
+	}
+	schema, err = d.resolve(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	form := map[string]any{}
+	for name := range values {
+		value := values.Get(name)
+		form[name] = value
+
+		property, ok := schema.Properties[name]
+		if !ok {
+			continue
+		}
+		property, err = d.resolve(property)
+		if err != nil {
+			return nil, err
+		}
+		switch property.Type {
+		case "integer", "number":
+			if _, err := strconv.ParseFloat(value, 64); err == nil {
+				form[name] = json.Number(value)
+			}
+		case "boolean":
+			if parsed, err := strconv.ParseBool(value); err == nil {
+				form[name] = parsed
+			}
+		}
+	}
+	return form, nil
+}
+
+// Appends the violations of the value, errors are problems of the schema
+func (d *Document) validate(schema *Schema, value any, field string, fieldErrors *[]policy.FieldError) error {
+	schema, err := d.resolve(schema)
+	if err != nil {
+		return err
+	}
+
+	fail := func(code, format string, args ...any) {
+		*fieldErrors = append(*fieldErrors, policy.FieldError{
+			Field:   field,
+			Code:    code,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+	name := field
+	if name == "" {
+		name = "The body"
+	}
+
+	if value == nil {
+		if !schema.Nullable {
+			fail(CodeInvalidType, "%s must not be null", name)
+		}
+		return nil
+	}
+
+	if len(schema.Enum) > 0 && !slices.ContainsFunc(schema.Enum, func(allowed any) bool {
+		return fmt.Sprint(allowed) == fmt.Sprint(value)
+	}) {
+		fail(CodeInvalidValue, "%s must be one of %v", name, schema.Enum)
+		return nil
+	}
+
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			fail(CodeInvalidType, "%s must be an object", name)
+			return nil
+		}
+		for _, required := range schema.Required {
+			if _, ok := object[required]; !ok {
+				*fieldErrors = append(*fieldErrors, policy.FieldError{
+					Field:   join(field, required),
+					Code:    policy.CodeRequired,
+					Message: fmt.Sprintf("%s is required", required),
+				})
+			}
+		}
+		// Sorted, so the errors come in a stable order
+		for _, property := range sortedKeys(object) {
+			propertySchema, ok := schema.Properties[property]
+			if !ok {
+				continue
+			}
+			err := d.validate(propertySchema, object[property], join(field, property), fieldErrors)
+			if err != nil {
+				return err
+			}
+		}
+
+	case "array":
+		array, ok := value.([]any)
+		if !ok {
+			fail(CodeInvalidType, "%s must be an array", name)
+			return nil
+		}
+		if schema.MinItems != nil && len(array) < *schema.MinItems {
+			fail(CodeTooFew, "%s needs at least %d items", name, *schema.MinItems)
+		}
+		if schema.MaxItems != nil && len(array) > *schema.MaxItems {
+			fail(CodeTooMany, "%s takes at most %d items", name, *schema.MaxItems)
+		}
+		if schema.Items != nil {
+			for i, item := range array {
+				err := d.validate(schema.Items, item, fmt.Sprintf("%s[%d]", field, i), fieldErrors)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			fail(CodeInvalidType, "%s must be a string", name)
+			return nil
+		}
+		length := utf8.RuneCountInString(text)
+		if schema.MinLength != nil && length < *schema.MinLength {
+			fail(policy.CodeTooShort, "%s must be at least %d characters long", name, *schema.MinLength)
+		}
+		if schema.MaxLength != nil && length > *schema.MaxLength {
+			fail(policy.CodeTooLong, "%s must be at most %d characters long", name, *schema.MaxLength)
+		}
+		if schema.Pattern != "" {
+			pattern, err := regexp.Compile(schema.Pattern)
+			if err != nil {
+				return fmt.Errorf("invalid pattern of %s: %w", name, err)
+			}
+			if !pattern.MatchString(text) {
+				fail(CodeInvalidValue, "%s is malformed", name)
+			}
+		}
+
+	case "integer", "number":
+		number, ok := value.(json.Number)
+		if !ok {
+			fail(CodeInvalidType, "%s must be a number", name)
+			return nil
+		}
+		parsed, err := number.Float64()
+		if err != nil {
+			fail(CodeInvalidType, "%s must be a number", name)
+			return nil
+		}
+		if schema.Type == "integer" {
+			if _, err := number.Int64(); err != nil {
+				fail(CodeInvalidType, "%s must be an integer", name)
+				return nil
+			}
+		}
+		if schema.Minimum != nil && parsed < *schema.Minimum {
+			fail(CodeTooSmall, "%s must be at least %v", name, *schema.Minimum)
+		}
+		if schema.Maximum != nil && parsed > *schema.Maximum {
+			fail(CodeTooLarge, "%s must be at most %v", name, *schema.Maximum)
+		}
+
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fail(CodeInvalidType, "%s must be a boolean", name)
+		}
+
+	case "":
+		// Anything goes
+
+	default:
+		return errors.New("unsupported schema type " + schema.Type)
+	}
+
+	return nil
+}
+
+func join(field, property string) string {
+	if field == "" {
+		return property
+	}
+	return field + "." + property
+}
+
+func sortedKeys(object map[string]any) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func writeValidationError(w http.ResponseWriter, validationErr *policy.ValidationError) {
+	response, err := json.Marshal(validationErr)
+	if utils.CheckError(w, err, "Failed to from a proper response", http.StatusInternalServerError) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	fmt.Fprintln(w, string(response))
+}
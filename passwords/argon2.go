@@ -0,0 +1,128 @@
+package passwords
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Argon2id with the parameters recorded in the hash, e.g.
+// $argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>
+type Argon2id struct {
+	Memory      uint32 // In KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// The minimum recommended by OWASP
+func DefaultArgon2id() *Argon2id {
+	return &Argon2id{
+		Memory:      19 * 1024,
+		Iterations:  2,
+		Parallelism: 1,
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+}
+
+type argon2Hash struct {
+	Argon2id
+	salt []byte
+	key  []byte
+}
+
+func (a *Argon2id) Hash(password string) (string, error) {
+	salt := make([]byte, a.SaltLength)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, a.Iterations, a.Memory, a.Parallelism, a.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, a.Memory, a.Iterations, a.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (a *Argon2id) Verify(password, encoded string) (bool, error) {
+	h, err := parseArgon2(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	key := argon2.IDKey([]byte(password), h.salt, h.Iterations, h.Memory, h.Parallelism, h.KeyLength)
+	return subtle.ConstantTimeCompare(key, h.key) == 1, nil
+}
+
+func (a *Argon2id) Handles(encoded string) bool {
+	return strings.HasPrefix(encoded, "$argon2id$")
+}
+
+func (a *Argon2id) Weaker(encoded string) bool {
+	h, err := parseArgon2(encoded)
+	if err != nil {
+		return true
+	}
+
+	return h.Memory < a.Memory ||
+		h.Iterations < a.Iterations ||
+		h.Parallelism < a.Parallelism ||
+		h.SaltLength < a.SaltLength ||
+		h.KeyLength < a.KeyLength
+}
+
+func parseArgon2(encoded string) (*argon2Hash, error) {
+	parts := splitPHC(encoded)
+	if len(parts) != 5 || parts[0] != "argon2id" {
+		return nil, errors.New("invalid argon2id hash")
+	}
+
+	var version int
+	_, err := fmt.Sscanf(parts[1], "v=%d", &version)
+	if err != nil {
+		return nil, fmt.Errorf("invalid argon2id version: %w", err)
+	}
+	if version != argon2.Version {
+		return nil, fmt.Errorf("unsupported argon2id version %d", version)
+	}
+
+	var h argon2Hash
+	_, err = fmt.Sscanf(parts[2], "m=%d,t=%d,p=%d", &h.Memory, &h.Iterations, &h.Parallelism)
+	if err != nil {
+		return nil, fmt.Errorf("invalid argon2id parameters: %w", err)
+	}
+
+	h.salt, err = base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return nil, fmt.Errorf("invalid argon2id salt: %w", err)
+	}
+	h.key, err = base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, fmt.Errorf("invalid argon2id hash: %w", err)
+	}
+	h.SaltLength = uint32(len(h.salt))
+	h.KeyLength = uint32(len(h.key))
+
+	// argon2.IDKey panics on zero iterations or parallelism, and an empty
+	// key would match any password. The minimums are the ones of RFC 9106.
+	switch {
+	case h.Iterations < 1 || h.Parallelism < 1 || h.Memory < 8*uint32(h.Parallelism):
+		return nil, errors.New("invalid argon2id parameters: out of range")
+	case h.SaltLength < 8:
+		return nil, errors.New("invalid argon2id salt: too short")
+	case h.KeyLength < 4:
+		return nil, errors.New("invalid argon2id hash: too short")
+	}
+
+	return &h, nil
+}
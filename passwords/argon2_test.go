@@ -0,0 +1,108 @@
+package passwords
+
+import (
+	"strings"
+	"testing"
+)
+
+// Cheap parameters, the defaults take a while per hash
+func testArgon2id() *Argon2id {
+	return &Argon2id{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+}
+
+func TestArgon2idRoundTrip(t *testing.T) {
+	a := testArgon2id()
+	encoded, err := a.Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(encoded, "$argon2id$v=19$m=64,t=1,p=1$") || !a.Handles(encoded) {
+		t.Fatalf("Hash() = %q, want an argon2id PHC string", encoded)
+	}
+
+	if ok, err := a.Verify("correct horse", encoded); !ok || err != nil {
+		t.Errorf("Verify(right password) = %v, %v, want true, nil", ok, err)
+	}
+	if ok, err := a.Verify("wrong horse", encoded); ok || err != nil {
+		t.Errorf("Verify(wrong password) = %v, %v, want false, nil", ok, err)
+	}
+
+	// A fresh salt every time
+	again, err := a.Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again == encoded {
+		t.Error("Hashing the same password twice gave the same hash")
+	}
+
+	if a.Weaker(encoded) {
+		t.Error("A hash with the current parameters is weaker")
+	}
+}
+
+func TestArgon2idWeaker(t *testing.T) {
+	encoded, err := testArgon2id().Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stronger := []*Argon2id{
+		{Memory: 128, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32},
+		{Memory: 64, Iterations: 2, Parallelism: 1, SaltLength: 16, KeyLength: 32},
+		{Memory: 64, Iterations: 1, Parallelism: 2, SaltLength: 16, KeyLength: 32},
+		{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 32, KeyLength: 32},
+		{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 64},
+		DefaultArgon2id(),
+	}
+	for _, a := range stronger {
+		if !a.Weaker(encoded) {
+			t.Errorf("%+v does not consider %q weaker", *a, encoded)
+		}
+	}
+
+	weaker := &Argon2id{Memory: 32, Iterations: 1, Parallelism: 1, SaltLength: 8, KeyLength: 16}
+	if weaker.Weaker(encoded) {
+		t.Errorf("%+v considers the stronger %q weaker", *weaker, encoded)
+	}
+}
+
+func TestParseArgon2Malformed(t *testing.T) {
+	const salt, key = "c2FsdHNhbHRzYWx0c2FsdA", "a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U"
+	tests := []string{
+		"",
+		"argon2id",
+		"$argon2id$",
+		"$argon2i$v=19$m=64,t=1,p=1$" + salt + "$" + key,
+		"$argon2id$v=19$m=64,t=1,p=1$" + salt,
+		"$argon2id$v=19$m=64,t=1,p=1$" + salt + "$" + key + "$extra",
+		"$argon2id$v=x$m=64,t=1,p=1$" + salt + "$" + key,
+		"$argon2id$v=16$m=64,t=1,p=1$" + salt + "$" + key,
+		"$argon2id$v=19$m=64$" + salt + "$" + key,
+		"$argon2id$v=19$m=64,t=0,p=1$" + salt + "$" + key,
+		"$argon2id$v=19$m=64,t=1,p=0$" + salt + "$" + key,
+		"$argon2id$v=19$m=0,t=1,p=1$" + salt + "$" + key,
+		"$argon2id$v=19$m=64,t=-1,p=1$" + salt + "$" + key,
+		"$argon2id$v=19$m=64,t=1,p=256$" + salt + "$" + key,
+		"$argon2id$v=19$m=64,t=1,p=1$not base64!$" + key,
+		"$argon2id$v=19$m=64,t=1,p=1$" + salt + "$not base64!",
+		"$argon2id$v=19$m=64,t=1,p=1$$" + key,
+		"$argon2id$v=19$m=64,t=1,p=1$" + salt + "$",
+	}
+
+	a := testArgon2id()
+	for _, encoded := range tests {
+		if _, err := parseArgon2(encoded); err == nil {
+			t.Errorf("parseArgon2(%q) did not fail", encoded)
+		}
+
+		// Neither panics nor accepts the password
+		ok, err := a.Verify("correct horse", encoded)
+		if ok || err == nil {
+			t.Errorf("Verify(%q) = %v, %v, want false and an error", encoded, ok, err)
+		}
+		if !a.Weaker(encoded) {
+			t.Errorf("Weaker(%q) = false, want true", encoded)
+		}
+	}
+}
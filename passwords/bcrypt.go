@@ -0,0 +1,47 @@
+package passwords
+
+import (
+	"errors"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Bcrypt hashes use their own modular crypt format, e.g. $2a$12$<salt+hash>,
+// which already follows the $<algorithm>$<parameters>$ layout.
+// Passwords longer than 72 bytes are rejected with ErrPasswordTooLong.
+type Bcrypt struct {
+	Cost int
+}
+
+func (b *Bcrypt) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), b.Cost)
+	if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+		return "", ErrPasswordTooLong
+	}
+	return string(hash), err
+}
+
+func (b *Bcrypt) Verify(password, encoded string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) || errors.Is(err, bcrypt.ErrPasswordTooLong) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (b *Bcrypt) Handles(encoded string) bool {
+	parts := splitPHC(encoded)
+	if len(parts) != 3 {
+		return false
+	}
+	switch parts[0] {
+	case "2a", "2b", "2y":
+		return true
+	}
+	return false
+}
+
+func (b *Bcrypt) Weaker(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost < b.Cost
+}
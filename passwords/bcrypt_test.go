@@ -0,0 +1,86 @@
+package passwords
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestBcryptRoundTrip(t *testing.T) {
+	b := &Bcrypt{Cost: bcrypt.MinCost}
+	encoded, err := b.Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !b.Handles(encoded) {
+		t.Fatalf("Handles(%q) = false", encoded)
+	}
+
+	if ok, err := b.Verify("correct horse", encoded); !ok || err != nil {
+		t.Errorf("Verify(right password) = %v, %v, want true, nil", ok, err)
+	}
+	if ok, err := b.Verify("wrong horse", encoded); ok || err != nil {
+		t.Errorf("Verify(wrong password) = %v, %v, want false, nil", ok, err)
+	}
+
+	if b.Weaker(encoded) {
+		t.Error("A hash with the current cost is weaker")
+	}
+	if !(&Bcrypt{Cost: bcrypt.MinCost + 1}).Weaker(encoded) {
+		t.Error("A hash with a lower cost is not weaker")
+	}
+
+	_, err = b.Hash(strings.Repeat("a", 73))
+	if !errors.Is(err, ErrPasswordTooLong) {
+		t.Errorf("Hash(73 bytes) error = %v, want ErrPasswordTooLong", err)
+	}
+}
+
+func TestBcryptHandles(t *testing.T) {
+	tests := map[string]bool{
+		"$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy": true,
+		"$2b$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy": true,
+		"$2y$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy": true,
+		"$2x$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy": false,
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdA$a2V5":                      false,
+		"2a$10$N9qo8uLOickgx2ZMRZoMye":                                 false,
+	}
+
+	b := &Bcrypt{Cost: bcrypt.MinCost}
+	for encoded, want := range tests {
+		if got := b.Handles(encoded); got != want {
+			t.Errorf("Handles(%q) = %v, want %v", encoded, got, want)
+		}
+	}
+}
+
+// Users registered while bcrypt was the default keep logging in and get an
+// Argon2id hash afterwards
+func TestManagerUpgradesLegacyBcrypt(t *testing.T) {
+	legacy, err := (&Bcrypt{Cost: bcrypt.MinCost}).Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	current := DefaultArgon2id()
+	if !current.Weaker(legacy) {
+		t.Error("Argon2id does not consider a bcrypt hash weaker")
+	}
+
+	m := NewManager(current, &Bcrypt{Cost: bcrypt.DefaultCost})
+	ok, rehash, err := m.Verify("correct horse", legacy)
+	if !ok || !rehash || err != nil {
+		t.Errorf("Verify(right password) = %v, %v, %v, want true, true, nil", ok, rehash, err)
+	}
+	ok, rehash, err = m.Verify("wrong horse", legacy)
+	if ok || rehash || err != nil {
+		t.Errorf("Verify(wrong password) = %v, %v, %v, want false, false, nil", ok, rehash, err)
+	}
+
+	_, _, err = m.Verify("correct horse", "$md5$whatever")
+	if !errors.Is(err, ErrUnknownAlgorithm) {
+		t.Errorf("Verify(unknown hash) error = %v, want ErrUnknownAlgorithm", err)
+	}
+}
@@ -0,0 +1,75 @@
+// Package passwords hashes and verifies passwords. Hashes are stored in the
+// PHC string format, "$<algorithm>$<parameters>$<salt>$<hash>", so every
+// hash records how it was made and old hashes keep working after the
+// configured algorithm or its parameters change.
+package passwords
+
+import (
+	"errors"
+	"strings"
+)
+
+// Returned by Hash when the algorithm cannot handle a password this long
+var ErrPasswordTooLong = errors.New("password too long")
+
+// Returned by Verify for hashes no configured Hasher understands
+var ErrUnknownAlgorithm = errors.New("unknown password hash algorithm")
+
+type Hasher interface {
+	// Hash returns the encoded hash of a password with a fresh salt
+	Hash(password string) (string, error)
+	// Verify reports whether the password matches an encoded hash
+	Verify(password, encoded string) (bool, error)
+	// Handles reports whether the encoded hash was made by this algorithm
+	Handles(encoded string) bool
+	// Weaker reports whether an encoded hash of this algorithm was made
+	// with weaker parameters than the ones the Hasher uses now
+	Weaker(encoded string) bool
+}
+
+// Manager hashes new passwords with the current Hasher and verifies old
+// hashes with whichever Hasher made them
+type Manager struct {
+	current Hasher
+	known   []Hasher
+}
+
+func NewManager(current Hasher, others ...Hasher) *Manager {
+	return &Manager{
+		current: current,
+		known:   append([]Hasher{current}, others...),
+	}
+}
+
+func (m *Manager) Hash(password string) (string, error) {
+	return m.current.Hash(password)
+}
+
+// Verify checks the password. On success, rehash tells whether the hash
+// should be replaced, because it was made with another algorithm or weaker
+// parameters than the current ones.
+func (m *Manager) Verify(password, encoded string) (ok bool, rehash bool, err error) {
+	for _, hasher := range m.known {
+		if !hasher.Handles(encoded) {
+			continue
+		}
+
+		ok, err = hasher.Verify(password, encoded)
+		if err != nil || !ok {
+			return false, false, err
+		}
+
+		rehash = hasher != m.current || m.current.Weaker(encoded)
+		return true, rehash, nil
+	}
+
+	return false, false, ErrUnknownAlgorithm
+}
+
+// Splits "$id$a$b" into ["id", "a", "b"]
+func splitPHC(encoded string) []string {
+	if !strings.HasPrefix(encoded, "$") {
+		return nil
+	}
+	return strings.Split(encoded[1:], "$")
+}
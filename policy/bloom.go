@@ -0,0 +1,120 @@
+package policy
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// Identifies the file format written by BloomFilter.WriteTo
+const bloomMagic = "CHATBLM1"
+
+// BloomFilter tells whether a string is possibly in a set without storing the
+// set itself. False positives are possible, false negatives are not.
+type BloomFilter struct {
+	bits []uint64
+	m    uint64 // Number of bits
+	k    uint32 // Number of hash functions
+}
+
+// NewBloomFilter sizes a filter for n entries at the given false positive rate
+func NewBloomFilter(n int, falsePositiveRate float64) *BloomFilter {
+	if n < 1 {
+		n = 1
+	}
+	m := math.Ceil(-float64(n) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2))
+	k := math.Round(m / float64(n) * math.Ln2)
+
+	words := (uint64(m) + 63) / 64
+	return &BloomFilter{
+		bits: make([]uint64, words),
+		m:    words * 64,
+		k:    uint32(max(k, 1)),
+	}
+}
+
+func (f *BloomFilter) Add(s string) {
+	h1, h2 := bloomHash(s)
+	for i := range uint64(f.k) {
+		bit := (h1 + i*h2) % f.m
+		f.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+func (f *BloomFilter) Contains(s string) bool {
+	h1, h2 := bloomHash(s)
+	for i := range uint64(f.k) {
+		bit := (h1 + i*h2) % f.m
+		if f.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Double hashing, both halves come from one SHA-256
+func bloomHash(s string) (uint64, uint64) {
+	sum := sha256.Sum256([]byte(s))
+	return binary.LittleEndian.Uint64(sum[0:8]), binary.LittleEndian.Uint64(sum[8:16]) | 1
+}
+
+func (f *BloomFilter) WriteTo(w io.Writer) (int64, error) {
+	header := make([]byte, 0, len(bloomMagic)+12)
+	header = append(header, bloomMagic...)
+	header = binary.LittleEndian.AppendUint32(header, f.k)
+	header = binary.LittleEndian.AppendUint64(header, f.m)
+
+	bw := bufio.NewWriter(w)
+	n, err := bw.Write(header)
+	if err != nil {
+		return int64(n), err
+	}
+	err = binary.Write(bw, binary.LittleEndian, f.bits)
+	if err != nil {
+		return int64(n), err
+	}
+
+	return int64(n) + int64(len(f.bits))*8, bw.Flush()
+}
+
+func ReadBloomFilter(r io.Reader) (*BloomFilter, error) {
+	header := make([]byte, len(bloomMagic)+12)
+	_, err := io.ReadFull(r, header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bloom filter header: %w", err)
+	}
+	if string(header[:len(bloomMagic)]) != bloomMagic {
+		return nil, errors.New("not a bloom filter file")
+	}
+
+	f := &BloomFilter{
+		k: binary.LittleEndian.Uint32(header[len(bloomMagic):]),
+		m: binary.LittleEndian.Uint64(header[len(bloomMagic)+4:]),
+	}
+	if f.k == 0 || f.m == 0 || f.m%64 != 0 {
+		return nil, errors.New("corrupt bloom filter header")
+	}
+
+	f.bits = make([]uint64, f.m/64)
+	err = binary.Read(r, binary.LittleEndian, f.bits)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bloom filter: %w", err)
+	}
+
+	return f, nil
+}
+
+func LoadBloomFilter(path string) (*BloomFilter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadBloomFilter(bufio.NewReader(file))
+}
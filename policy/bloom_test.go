@@ -0,0 +1,64 @@
+package policy
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestBloomFilterFalsePositiveRate(t *testing.T) {
+	const n = 10000
+	for _, target := range []float64{0.01, 0.001} {
+		f := NewBloomFilter(n, target)
+		for i := range n {
+			f.Add(fmt.Sprintf("member-%d", i))
+		}
+
+		// No false negatives
+		for i := range n {
+			if !f.Contains(fmt.Sprintf("member-%d", i)) {
+				t.Fatalf("Filter for rate %v lost member-%d", target, i)
+			}
+		}
+
+		const probes = 200000
+		positives := 0
+		for i := range probes {
+			if f.Contains(fmt.Sprintf("other-%d", i)) {
+				positives++
+			}
+		}
+
+		// The filter is rounded up to whole words, so it may do a bit better
+		rate := float64(positives) / probes
+		if rate > target*1.5 {
+			t.Errorf("False positive rate = %v, want about %v", rate, target)
+		}
+	}
+}
+
+func TestBloomFilterRoundTrip(t *testing.T) {
+	f := NewBloomFilter(100, 0.01)
+	f.Add("hunter2")
+
+	var buf bytes.Buffer
+	_, err := f.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := ReadBloomFilter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !read.Contains("hunter2") || read.k != f.k || read.m != f.m {
+		t.Error("Filter changed after writing and reading it")
+	}
+
+	if _, err := ReadBloomFilter(bytes.NewReader([]byte("NOTBLOOM00000000000000000"))); err == nil {
+		t.Error("Read a file without the magic")
+	}
+	if _, err := ReadBloomFilter(bytes.NewReader([]byte(bloomMagic))); err == nil {
+		t.Error("Read a truncated header")
+	}
+}
@@ -0,0 +1,239 @@
+// Package policy validates usernames and passwords at registration
+package policy
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/SomeSuperCoder/global-chat/usernames"
+)
+
+type Charset string
+
+const (
+	// Latin letters, digits, "_", "." and "-"
+	CharsetASCII Charset = "ascii"
+	// Letters and digits of any script, "_", "." and "-"
+	CharsetUnicode Charset = "unicode"
+)
+
+func (c Charset) Valid() bool {
+	return c == CharsetASCII || c == CharsetUnicode
+}
+
+// Common passwords, built with cmd/breachgen from the password list of
+// zxcvbn-go (MIT licensed)
+//
+//go:embed breached.bloom
+var defaultBreached []byte
+
+func DefaultBreached() *BloomFilter {
+	filter, err := ReadBloomFilter(bytes.NewReader(defaultBreached))
+	if err != nil {
+		panic(err)
+	}
+	return filter
+}
+
+type Policy struct {
+	UsernameMinLength int // In runes
+	UsernameMaxLength int
+	UsernameCharset   Charset
+	ReservedUsernames []string
+
+	PasswordMinLength  int // In runes
+	PasswordMaxLength  int
+	PasswordMinEntropy float64 // In bits, see Entropy
+
+	// Passwords known from breaches, nil disables the check
+	Breached *BloomFilter
+}
+
+// Error codes of FieldError
+const (
+	CodeRequired          = "required"
+	CodeTooShort          = "too_short"
+	CodeTooLong           = "too_long"
+	CodeInvalidCharacters = "invalid_characters"
+	CodeReserved          = "reserved"
+	CodeTooWeak           = "too_weak"
+	CodeBreached          = "breached"
+	CodeContainsUsername  = "contains_username"
+)
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type ValidationError struct {
+	Errors []FieldError `json:"errors"`
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, fieldErr := range e.Errors {
+		messages[i] = fieldErr.Field + ": " + fieldErr.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Check returns a *ValidationError listing every violated rule, or nil
+func (p *Policy) Check(username, password string) error {
+	errs := append(p.CheckUsername(username), p.CheckPassword(password, username)...)
+	if len(errs) == 0 {
+		return nil
+	}
+	return &ValidationError{Errors: errs}
+}
+
+func (p *Policy) CheckUsername(username string) []FieldError {
+	fail := func(code, message string) []FieldError {
+		return []FieldError{{Field: "username", Code: code, Message: message}}
+	}
+
+	length := utf8.RuneCountInString(username)
+	switch {
+	case length == 0:
+		return fail(CodeRequired, "Username is required")
+	case length < p.UsernameMinLength:
+		return fail(CodeTooShort, fmt.Sprintf("Username must be at least %d characters long", p.UsernameMinLength))
+	case length > p.UsernameMaxLength:
+		return fail(CodeTooLong, fmt.Sprintf("Username must be at most %d characters long", p.UsernameMaxLength))
+	}
+
+	if !p.validUsernameCharacters(username) {
+		return fail(CodeInvalidCharacters, p.charsetDescription())
+	}
+
+	// Compared like usernames, so neither "ADMIN" nor "аdmin" gets through
+	key := usernames.Key(username)
+	for _, reserved := range p.ReservedUsernames {
+		if key == usernames.Key(reserved) {
+			return fail(CodeReserved, "This username is reserved")
+		}
+	}
+
+	return nil
+}
+
+func (p *Policy) validUsernameCharacters(username string) bool {
+	if !utf8.ValidString(username) {
+		return false
+	}
+
+	runes := []rune(username)
+	for i, r := range runes {
+		alphanumeric := p.isAlphanumeric(r)
+		separator := r == '_' || r == '.' || r == '-'
+		// Combining accents are part of the previous letter
+		mark := p.UsernameCharset == CharsetUnicode && unicode.IsMark(r) && i > 0
+
+		if !alphanumeric && !separator && !mark {
+			return false
+		}
+		// Separators only between letters and digits
+		if separator && (i == 0 || i == len(runes)-1) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *Policy) isAlphanumeric(r rune) bool {
+	if p.UsernameCharset == CharsetUnicode {
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	}
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'
+}
+
+func (p *Policy) charsetDescription() string {
+	letters := "Latin letters"
+	if p.UsernameCharset == CharsetUnicode {
+		letters = "letters"
+	}
+	return "Username may only contain " + letters + `, digits, "_", "." and "-", and must start and end with a letter or digit`
+}
+
+func (p *Policy) CheckPassword(password, username string) []FieldError {
+	var errs []FieldError
+	fail := func(code, message string) {
+		errs = append(errs, FieldError{Field: "password", Code: code, Message: message})
+	}
+
+	length := utf8.RuneCountInString(password)
+	switch {
+	case length == 0:
+		fail(CodeRequired, "Password is required")
+		return errs
+	case length < p.PasswordMinLength:
+		fail(CodeTooShort, fmt.Sprintf("Password must be at least %d characters long", p.PasswordMinLength))
+		return errs
+	case length > p.PasswordMaxLength:
+		fail(CodeTooLong, fmt.Sprintf("Password must be at most %d characters long", p.PasswordMaxLength))
+		return errs
+	}
+
+	if utf8.RuneCountInString(username) >= 3 && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		fail(CodeContainsUsername, "Password must not contain the username")
+	}
+
+	if p.Breached != nil && (p.Breached.Contains(password) || p.Breached.Contains(strings.ToLower(password))) {
+		fail(CodeBreached, "This password is too common or has appeared in a data breach")
+	} else if Entropy(password) < p.PasswordMinEntropy {
+		fail(CodeTooWeak, "Password is too predictable, use a longer password or mix letters, digits and symbols")
+	}
+
+	return errs
+}
+
+// Entropy estimates the strength of a password in bits, as the number of
+// characters times log2 of the alphabet they are drawn from. Characters that
+// repeat or continue a sequence of the previous one ("aaa", "abc", "321")
+// add nothing.
+func Entropy(password string) float64 {
+	var lower, upper, digit, symbol, other bool
+	effective := 0
+	prev := rune(-1)
+
+	for _, r := range password {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < utf8.RuneSelf:
+			symbol = true
+		default:
+			other = true
+		}
+
+		if r != prev && r != prev+1 && r != prev-1 {
+			effective++
+		}
+		prev = r
+	}
+
+	pool := 0
+	for _, class := range []struct {
+		present bool
+		size    int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if class.present {
+			pool += class.size
+		}
+	}
+	if pool == 0 {
+		return 0
+	}
+
+	return float64(effective) * math.Log2(float64(pool))
+}
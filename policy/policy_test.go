@@ -0,0 +1,125 @@
+package policy
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func testPolicy() *Policy {
+	return &Policy{
+		UsernameMinLength:  3,
+		UsernameMaxLength:  32,
+		UsernameCharset:    CharsetUnicode,
+		ReservedUsernames:  []string{"admin"},
+		PasswordMinLength:  8,
+		PasswordMaxLength:  64,
+		PasswordMinEntropy: 40,
+		Breached:           DefaultBreached(),
+	}
+}
+
+func codes(errs []FieldError) []string {
+	var result []string
+	for _, err := range errs {
+		result = append(result, err.Code)
+	}
+	return result
+}
+
+func TestCheckPassword(t *testing.T) {
+	p := testPolicy()
+	tests := []struct {
+		password string
+		want     []string
+	}{
+		// Strong
+		{"correct horse battery staple", nil},
+		{"Tr0ub4dor&3-vx", nil},
+		{"kX9#mQ2$vL7!", nil},
+
+		// From the bundled list, in any case
+		{"password", []string{CodeBreached}},
+		{"iloveyou", []string{CodeBreached}},
+		{"trustno1", []string{CodeBreached}},
+		{"Password1", []string{CodeBreached}},
+		{"FOOTBALL", []string{CodeBreached}},
+
+		// Not on the list, but too predictable
+		{"aaaaaaaaaaaa", []string{CodeTooWeak}},
+		{"abcdefghijkl", []string{CodeTooWeak}},
+		{"zqxjvkwp", []string{CodeTooWeak}},
+
+		{"", []string{CodeRequired}},
+		{"Ab1!", []string{CodeTooShort}},
+		{"kX9#mQ2$vL7!kX9#mQ2$vL7!kX9#mQ2$vL7!kX9#mQ2$vL7!kX9#mQ2$vL7!kX9#mQ2$vL7!", []string{CodeTooLong}},
+		{"my name is Tomas!", []string{CodeContainsUsername}},
+	}
+
+	for _, test := range tests {
+		got := codes(p.CheckPassword(test.password, "tomas"))
+		if !slices.Equal(got, test.want) {
+			t.Errorf("CheckPassword(%q) = %v, want %v", test.password, got, test.want)
+		}
+	}
+}
+
+func TestCheckPasswordWithoutList(t *testing.T) {
+	p := testPolicy()
+	p.Breached = nil
+
+	// Still caught by the entropy check, not as breached
+	got := codes(p.CheckPassword("password", "tomas"))
+	if !slices.Equal(got, []string{CodeTooWeak}) {
+		t.Errorf("CheckPassword(%q) = %v, want %v", "password", got, []string{CodeTooWeak})
+	}
+}
+
+func TestCheckUsername(t *testing.T) {
+	p := testPolicy()
+	tests := []struct {
+		username string
+		want     []string
+	}{
+		{"tomas", nil},
+		{"Томас", nil},
+		{"jean-luc.p", nil},
+		{"", []string{CodeRequired}},
+		{"ab", []string{CodeTooShort}},
+		{"-tomas", []string{CodeInvalidCharacters}},
+		{"tomas_", []string{CodeInvalidCharacters}},
+		{"to mas", []string{CodeInvalidCharacters}},
+		{"ADMIN", []string{CodeReserved}},
+		{"аdmin", []string{CodeReserved}},
+	}
+
+	for _, test := range tests {
+		got := codes(p.CheckUsername(test.username))
+		if !slices.Equal(got, test.want) {
+			t.Errorf("CheckUsername(%q) = %v, want %v", test.username, got, test.want)
+		}
+	}
+
+	p.UsernameCharset = CharsetASCII
+	if got := codes(p.CheckUsername("Томас")); !slices.Equal(got, []string{CodeInvalidCharacters}) {
+		t.Errorf("CheckUsername(%q) with the ASCII charset = %v, want %v", "Томас", got, []string{CodeInvalidCharacters})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	p := testPolicy()
+
+	if err := p.Check("tomas", "correct horse battery staple"); err != nil {
+		t.Errorf("Check() = %v, want nil", err)
+	}
+
+	// Every violated rule is reported at once
+	err := p.Check("ab", "password")
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Check() = %v, want a *ValidationError", err)
+	}
+	if got := codes(validationErr.Errors); !slices.Equal(got, []string{CodeTooShort, CodeBreached}) {
+		t.Errorf("Check() codes = %v, want %v", got, []string{CodeTooShort, CodeBreached})
+	}
+}
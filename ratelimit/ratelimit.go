@@ -0,0 +1,88 @@
+// Package ratelimit keeps one token bucket per key, in process. Limits are
+// not shared between instances.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Idle buckets are dropped once they are full again, checked every this many calls
+const sweepEvery = 1024
+
+type bucket struct {
+	tokens   float64
+	capacity float64
+	updated  time.Time
+}
+
+// Tokens per second
+func (b *bucket) rate() float64 {
+	return b.capacity / time.Minute.Seconds()
+}
+
+func (b *bucket) refill(now time.Time) float64 {
+	return min(b.capacity, b.tokens+now.Sub(b.updated).Seconds()*b.rate())
+}
+
+type Limiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	calls   int
+
+	// Replaceable for tests
+	Now func() time.Time
+}
+
+func New() *Limiter {
+	return &Limiter{
+		buckets: map[string]*bucket{},
+		Now:     time.Now,
+	}
+}
+
+// Allow takes a token from the bucket of key, which holds perMinute tokens
+// and refills at the same rate. When it is empty, the time until the next
+// token is returned.
+func (l *Limiter) Allow(key string, perMinute int) (bool, time.Duration) {
+	if perMinute <= 0 {
+		return false, time.Minute
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.Now()
+
+	l.calls++
+	if l.calls%sweepEvery == 0 {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(perMinute), updated: now}
+		l.buckets[key] = b
+	}
+
+	// The limit may have been changed since the last call
+	b.capacity = float64(perMinute)
+	b.tokens = b.refill(now)
+	b.updated = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / b.rate() * float64(time.Second))
+		return false, wait
+	}
+
+	b.tokens--
+	return true, 0
+}
+
+func (l *Limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if b.refill(now) >= b.capacity {
+			delete(l.buckets, key)
+		}
+	}
+}
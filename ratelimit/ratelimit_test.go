@@ -0,0 +1,98 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestLimiter() (*Limiter, *time.Time) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := New()
+	l.Now = func() time.Time { return now }
+	return l, &now
+}
+
+func TestAllowBurst(t *testing.T) {
+	l, _ := newTestLimiter()
+
+	// A new bucket is full, so the whole limit can be used at once
+	for i := range 3 {
+		if allowed, _ := l.Allow("hook", 3); !allowed {
+			t.Fatalf("Call %d was not allowed", i+1)
+		}
+	}
+
+	allowed, wait := l.Allow("hook", 3)
+	if allowed {
+		t.Fatal("Call over the limit was allowed")
+	}
+	if wait != 20*time.Second {
+		t.Errorf("Wait = %v, want %v", wait, 20*time.Second)
+	}
+}
+
+func TestAllowRefill(t *testing.T) {
+	l, now := newTestLimiter()
+
+	for range 6 {
+		l.Allow("hook", 6)
+	}
+	if allowed, _ := l.Allow("hook", 6); allowed {
+		t.Fatal("Empty bucket allowed a call")
+	}
+
+	// One token every 10 seconds
+	*now = now.Add(5 * time.Second)
+	allowed, wait := l.Allow("hook", 6)
+	if allowed {
+		t.Fatal("Half a token allowed a call")
+	}
+	if wait != 5*time.Second {
+		t.Errorf("Wait = %v, want %v", wait, 5*time.Second)
+	}
+
+	*now = now.Add(5 * time.Second)
+	if allowed, _ := l.Allow("hook", 6); !allowed {
+		t.Fatal("Refilled token was not allowed")
+	}
+	if allowed, _ := l.Allow("hook", 6); allowed {
+		t.Fatal("Only one token should have been refilled")
+	}
+
+	// Never more than the limit, however long the bucket was idle
+	*now = now.Add(time.Hour)
+	for i := range 6 {
+		if allowed, _ := l.Allow("hook", 6); !allowed {
+			t.Fatalf("Call %d after the refill was not allowed", i+1)
+		}
+	}
+	if allowed, _ := l.Allow("hook", 6); allowed {
+		t.Fatal("Bucket refilled over its capacity")
+	}
+}
+
+func TestAllowKeysAreIsolated(t *testing.T) {
+	l, _ := newTestLimiter()
+
+	l.Allow("a", 1)
+	if allowed, _ := l.Allow("a", 1); allowed {
+		t.Fatal("Empty bucket allowed a call")
+	}
+
+	if allowed, _ := l.Allow("b", 1); !allowed {
+		t.Error("Empty bucket of another key blocked a call")
+	}
+}
+
+func TestAllowSweepKeepsUsedBuckets(t *testing.T) {
+	l, _ := newTestLimiter()
+
+	l.Allow("busy", 1)
+	for range sweepEvery {
+		l.Allow("other", 1000)
+	}
+
+	if allowed, _ := l.Allow("busy", 1); allowed {
+		t.Error("Sweep dropped a bucket that was not full")
+	}
+}
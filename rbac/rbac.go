@@ -0,0 +1,84 @@
+package rbac
+
+import (
+	"github.com/SomeSuperCoder/global-chat/models"
+	"github.com/SomeSuperCoder/global-chat/repository"
+)
+
+type Permission string
+
+const (
+	EditOwnMessage   Permission = "message:edit:own"
+	DeleteOwnMessage Permission = "message:delete:own"
+	EditAnyMessage   Permission = "message:edit:any"
+	DeleteAnyMessage Permission = "message:delete:any"
+	ModerateUsers    Permission = "users:moderate"
+	ManageUsers      Permission = "users:manage"
+	ViewAuditLog     Permission = "audit:view"
+	ManageBots       Permission = "bots:manage"
+	ManageWebhooks   Permission = "webhooks:manage"
+	SetTopic         Permission = "topic:set"
+)
+
+// The single source of truth for what each role may do
+var rolePermissions = map[models.Role][]Permission{
+	models.RoleUser: {
+		EditOwnMessage,
+		DeleteOwnMessage,
+	},
+	models.RoleModerator: {
+		EditOwnMessage,
+		DeleteOwnMessage,
+		DeleteAnyMessage,
+		ModerateUsers,
+		SetTopic,
+	},
+	models.RoleAdmin: {
+		EditOwnMessage,
+		DeleteOwnMessage,
+		EditAnyMessage,
+		DeleteAnyMessage,
+		ModerateUsers,
+		ManageUsers,
+		ViewAuditLog,
+		ManageBots,
+		ManageWebhooks,
+		SetTopic,
+	},
+}
+
+func Can(role models.Role, permission Permission) bool {
+	if !role.Valid() {
+		role = models.RoleUser
+	}
+
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func CanEditMessage(userAuth *repository.UserAuth, message *models.Message) bool {
+	if message.Author == userAuth.UserID {
+		return Can(userAuth.Role, EditOwnMessage)
+	}
+	return Can(userAuth.Role, EditAnyMessage)
+}
+
+func CanDeleteMessage(userAuth *repository.UserAuth, message *models.Message) bool {
+	if message.Author == userAuth.UserID {
+		return Can(userAuth.Role, DeleteOwnMessage)
+	}
+	return Can(userAuth.Role, DeleteAnyMessage)
+}
+
+// Acting on another user requires the permission and a strictly higher role,
+// so moderators cannot act on each other and nobody can act on themselves
+func CanActOnUser(userAuth *repository.UserAuth, permission Permission, target *models.User) bool {
+	if target.ID == userAuth.UserID {
+		return false
+	}
+	return Can(userAuth.Role, permission) && userAuth.Role.Level() > target.Role.Level()
+}
@@ -0,0 +1,95 @@
+package rbac
+
+import (
+	"testing"
+
+	"github.com/SomeSuperCoder/global-chat/models"
+	"github.com/SomeSuperCoder/global-chat/repository"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestCan(t *testing.T) {
+	all := []Permission{
+		EditOwnMessage, DeleteOwnMessage, EditAnyMessage, DeleteAnyMessage, ModerateUsers,
+		ManageUsers, ViewAuditLog, ManageBots, ManageWebhooks, SetTopic,
+	}
+	allowed := map[models.Role][]Permission{
+		models.RoleUser:      {EditOwnMessage, DeleteOwnMessage},
+		models.RoleModerator: {EditOwnMessage, DeleteOwnMessage, DeleteAnyMessage, ModerateUsers, SetTopic},
+		models.RoleAdmin:     all,
+		// Unknown roles get the permissions of a user
+		models.Role("owner"): {EditOwnMessage, DeleteOwnMessage},
+	}
+
+	for role, permissions := range allowed {
+		for _, permission := range all {
+			want := false
+			for _, p := range permissions {
+				want = want || p == permission
+			}
+			if got := Can(role, permission); got != want {
+				t.Errorf("Can(%s, %s) = %v, want %v", role, permission, got, want)
+			}
+		}
+	}
+}
+
+func TestMessagePermissions(t *testing.T) {
+	author := bson.NewObjectID()
+	message := &models.Message{Author: author}
+
+	tests := []struct {
+		name               string
+		userAuth           *repository.UserAuth
+		canEdit, canDelete bool
+	}{
+		{"user on own", &repository.UserAuth{UserID: author, Role: models.RoleUser}, true, true},
+		{"user on other", &repository.UserAuth{UserID: bson.NewObjectID(), Role: models.RoleUser}, false, false},
+		{"moderator on other", &repository.UserAuth{UserID: bson.NewObjectID(), Role: models.RoleModerator}, false, true},
+		{"admin on other", &repository.UserAuth{UserID: bson.NewObjectID(), Role: models.RoleAdmin}, true, true},
+	}
+
+	for _, test := range tests {
+		if got := CanEditMessage(test.userAuth, message); got != test.canEdit {
+			t.Errorf("%s: CanEditMessage = %v, want %v", test.name, got, test.canEdit)
+		}
+		if got := CanDeleteMessage(test.userAuth, message); got != test.canDelete {
+			t.Errorf("%s: CanDeleteMessage = %v, want %v", test.name, got, test.canDelete)
+		}
+	}
+}
+
+func TestCanActOnUser(t *testing.T) {
+	self := bson.NewObjectID()
+	user := func(role models.Role) *models.User {
+		return &models.User{ID: bson.NewObjectID(), Role: role}
+	}
+
+	tests := []struct {
+		name   string
+		role   models.Role
+		target *models.User
+		want   bool
+	}{
+		{"moderator on user", models.RoleModerator, user(models.RoleUser), true},
+		{"admin on moderator", models.RoleAdmin, user(models.RoleModerator), true},
+		{"user on user", models.RoleUser, user(models.RoleUser), false},
+		{"moderator on moderator", models.RoleModerator, user(models.RoleModerator), false},
+		{"admin on admin", models.RoleAdmin, user(models.RoleAdmin), false},
+		{"moderator on admin", models.RoleModerator, user(models.RoleAdmin), false},
+		{"admin on self", models.RoleAdmin, &models.User{ID: self, Role: models.RoleUser}, false},
+	}
+
+	for _, test := range tests {
+		userAuth := &repository.UserAuth{UserID: self, Role: test.role}
+		if got := CanActOnUser(userAuth, ModerateUsers, test.target); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+
+	// The role alone is not enough without the permission
+	userAuth := &repository.UserAuth{UserID: self, Role: models.RoleModerator}
+	if CanActOnUser(userAuth, ManageUsers, user(models.RoleUser)) {
+		t.Error("A moderator could manage a user")
+	}
+}
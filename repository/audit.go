@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/SomeSuperCoder/global-chat/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Audit events are append-only, there is intentionally no update or delete
+type AuditRepo struct {
+	Database *mongo.Database
+}
+
+// Zero values are ignored
+type AuditFilter struct {
+	Actor     bson.ObjectID
+	Target    bson.ObjectID
+	MessageID bson.ObjectID
+	Actions   []models.AuditAction
+	IP        string
+	Since     time.Time
+	Until     time.Time
+}
+
+func (r *AuditRepo) Record(ctx context.Context, event models.AuditEvent) error {
+	_, err := r.Database.Collection("audit_events").InsertOne(ctx, event)
+	return err
+}
+
+func (r *AuditRepo) FindPaged(ctx context.Context, filter AuditFilter, page, limit int64) ([]models.AuditEvent, int64, error) {
+	var events = []models.AuditEvent{}
+
+	// Build the filter
+	query := bson.M{}
+	if !filter.Actor.IsZero() {
+		query["actor"] = filter.Actor
+	}
+	if !filter.Target.IsZero() {
+		query["target"] = filter.Target
+	}
+	if !filter.MessageID.IsZero() {
+		query["message_id"] = filter.MessageID
+	}
+	if len(filter.Actions) > 0 {
+		query["action"] = bson.M{"$in": filter.Actions}
+	}
+	if filter.IP != "" {
+		query["ip"] = filter.IP
+	}
+	if !filter.Since.IsZero() || !filter.Until.IsZero() {
+		createdAt := bson.M{}
+		if !filter.Since.IsZero() {
+			createdAt["$gte"] = filter.Since
+		}
+		if !filter.Until.IsZero() {
+			createdAt["$lt"] = filter.Until
+		}
+		query["created_at"] = createdAt
+	}
+
+	// Set pagination options
+	skip := (page - 1) * limit
+	opts := options.Find()
+	opts.SetLimit(limit)
+	opts.SetSkip(skip)
+	opts.SetSort(bson.M{"created_at": -1})
+
+	cursor, err := r.Database.Collection("audit_events").Find(ctx, query, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	err = cursor.All(ctx, &events)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	count, err := r.Database.Collection("audit_events").CountDocuments(ctx, query)
+	return events, count, err
+}
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/SomeSuperCoder/global-chat/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type DeliveryRepo struct {
+	Database *mongo.Database
+}
+
+func (r *DeliveryRepo) EnqueueDeliveries(ctx context.Context, deliveries []models.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	_, err := r.Database.Collection("webhook_deliveries").InsertMany(ctx, deliveries)
+	return err
+}
+
+// The update is atomic, so two workers never claim the same delivery
+func (r *DeliveryRepo) ClaimDelivery(ctx context.Context, now, leaseUntil time.Time) (*models.WebhookDelivery, error) {
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.M{"next_attempt_at": 1}).
+		SetReturnDocument(options.After)
+
+	var delivery models.WebhookDelivery
+	err := r.Database.Collection("webhook_deliveries").FindOneAndUpdate(ctx, bson.M{
+		"status":          models.DeliveryPending,
+		"next_attempt_at": bson.M{"$lte": now},
+	}, bson.M{
+		"$set": bson.M{"next_attempt_at": leaseUntil},
+	}, opts).Decode(&delivery)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	return &delivery, nil
+}
+
+func (r *DeliveryRepo) UpdateDelivery(ctx context.Context, delivery models.WebhookDelivery) error {
+	_, err := r.Database.Collection("webhook_deliveries").UpdateByID(ctx, delivery.ID, bson.M{
+		"$set": bson.M{
+			"status":           delivery.Status,
+			"attempts":         delivery.Attempts,
+			"next_attempt_at":  delivery.NextAttemptAt,
+			"last_status_code": delivery.LastStatusCode,
+			"last_error":       delivery.LastError,
+			"delivered_at":     delivery.DeliveredAt,
+		},
+	})
+	return err
+}
+
+func (r *DeliveryRepo) FindDeliveries(ctx context.Context, hookID bson.ObjectID, status models.DeliveryStatus, page, limit int64) ([]models.WebhookDelivery, int64, error) {
+	var deliveries = []models.WebhookDelivery{}
+
+	filter := bson.M{"webhook_id": hookID}
+	if status != "" {
+		filter["status"] = status
+	}
+
+	// Set pagination options
+	skip := (page - 1) * limit
+	opts := options.Find()
+	opts.SetLimit(limit)
+	opts.SetSkip(skip)
+	opts.SetSort(bson.M{"created_at": -1})
+
+	cursor, err := r.Database.Collection("webhook_deliveries").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	err = cursor.All(ctx, &deliveries)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	count, err := r.Database.Collection("webhook_deliveries").CountDocuments(ctx, filter)
+	return deliveries, count, err
+}
+
+func (r *DeliveryRepo) RetryDelivery(ctx context.Context, deliveryID bson.ObjectID, now time.Time) (bool, error) {
+	res, err := r.Database.Collection("webhook_deliveries").UpdateOne(ctx, bson.M{
+		"_id":    deliveryID,
+		"status": models.DeliveryDead,
+	}, bson.M{
+		"$set": bson.M{
+			"status":          models.DeliveryPending,
+			"attempts":        0,
+			"next_attempt_at": now,
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return res.ModifiedCount > 0, nil
+}
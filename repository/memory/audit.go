@@ -0,0 +1,65 @@
+package memory
+
+import (
+	"context"
+	"slices"
+
+	"github.com/SomeSuperCoder/global-chat/models"
+	"github.com/SomeSuperCoder/global-chat/repository"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Audit events are append-only, there is intentionally no update or delete
+type AuditRepo struct {
+	DB *DB
+}
+
+func (r *AuditRepo) Record(ctx context.Context, event models.AuditEvent) error {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	if event.ID.IsZero() {
+		event.ID = bson.NewObjectID()
+	}
+	r.DB.audit = append(r.DB.audit, event)
+
+	return nil
+}
+
+func (r *AuditRepo) FindPaged(ctx context.Context, filter repository.AuditFilter, page, limit int64) ([]models.AuditEvent, int64, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	matching := []models.AuditEvent{}
+	for _, event := range r.DB.audit {
+		if matchesAuditFilter(event, filter) {
+			matching = append(matching, event)
+		}
+	}
+	slices.SortStableFunc(matching, func(a, b models.AuditEvent) int {
+		return b.CratedAt.Compare(a.CratedAt)
+	})
+
+	events := slices.Clone(paginate(matching, page, limit))
+	return events, int64(len(matching)), nil
+}
+
+func matchesAuditFilter(event models.AuditEvent, filter repository.AuditFilter) bool {
+	switch {
+	case !filter.Actor.IsZero() && event.Actor != filter.Actor:
+		return false
+	case !filter.Target.IsZero() && event.Target != filter.Target:
+		return false
+	case !filter.MessageID.IsZero() && event.MessageID != filter.MessageID:
+		return false
+	case len(filter.Actions) > 0 && !slices.Contains(filter.Actions, event.Action):
+		return false
+	case filter.IP != "" && event.IP != filter.IP:
+		return false
+	case !filter.Since.IsZero() && event.CratedAt.Before(filter.Since):
+		return false
+	case !filter.Until.IsZero() && !event.CratedAt.Before(filter.Until):
+		return false
+	}
+	return true
+}
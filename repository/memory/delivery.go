@@ -0,0 +1,105 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/SomeSuperCoder/global-chat/models"
+	"github.com/SomeSuperCoder/global-chat/repository"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type DeliveryRepo struct {
+	DB *DB
+}
+
+func (r *DeliveryRepo) EnqueueDeliveries(ctx context.Context, deliveries []models.WebhookDelivery) error {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	for _, delivery := range deliveries {
+		stored := cloneDelivery(&delivery)
+		if stored.ID.IsZero() {
+			stored.ID = bson.NewObjectID()
+		}
+		r.DB.deliveries = append(r.DB.deliveries, stored)
+	}
+
+	return nil
+}
+
+func (r *DeliveryRepo) ClaimDelivery(ctx context.Context, now, leaseUntil time.Time) (*models.WebhookDelivery, error) {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	var due *models.WebhookDelivery
+	for _, delivery := range r.DB.deliveries {
+		if delivery.Status != models.DeliveryPending || delivery.NextAttemptAt.After(now) {
+			continue
+		}
+		if due == nil || delivery.NextAttemptAt.Before(due.NextAttemptAt) {
+			due = delivery
+		}
+	}
+	if due == nil {
+		return nil, repository.ErrNotFound
+	}
+
+	due.NextAttemptAt = leaseUntil
+	return cloneDelivery(due), nil
+}
+
+func (r *DeliveryRepo) UpdateDelivery(ctx context.Context, delivery models.WebhookDelivery) error {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	for _, stored := range r.DB.deliveries {
+		if stored.ID == delivery.ID {
+			updated := cloneDelivery(&delivery)
+			stored.Status = updated.Status
+			stored.Attempts = updated.Attempts
+			stored.NextAttemptAt = updated.NextAttemptAt
+			stored.LastStatusCode = updated.LastStatusCode
+			stored.LastError = updated.LastError
+			stored.DeliveredAt = updated.DeliveredAt
+			break
+		}
+	}
+
+	return nil
+}
+
+func (r *DeliveryRepo) FindDeliveries(ctx context.Context, hookID bson.ObjectID, status models.DeliveryStatus, page, limit int64) ([]models.WebhookDelivery, int64, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	matching := []models.WebhookDelivery{}
+	for _, delivery := range r.DB.deliveries {
+		if delivery.WebhookID == hookID && (status == "" || delivery.Status == status) {
+			matching = append(matching, *cloneDelivery(delivery))
+		}
+	}
+	slices.SortStableFunc(matching, func(a, b models.WebhookDelivery) int {
+		return b.CratedAt.Compare(a.CratedAt)
+	})
+
+	deliveries := slices.Clone(paginate(matching, page, limit))
+	return deliveries, int64(len(matching)), nil
+}
+
+func (r *DeliveryRepo) RetryDelivery(ctx context.Context, deliveryID bson.ObjectID, now time.Time) (bool, error) {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	for _, delivery := range r.DB.deliveries {
+		if delivery.ID == deliveryID && delivery.Status == models.DeliveryDead {
+			delivery.Status = models.DeliveryPending
+			delivery.Attempts = 0
+			delivery.NextAttemptAt = now
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
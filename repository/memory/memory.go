@@ -0,0 +1,103 @@
+// Package memory is a thread-safe in-memory implementation of the repository
+// interfaces. It mirrors the semantics of the MongoDB repositories and is
+// meant for tests and local experiments, nothing is persisted.
+package memory
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/SomeSuperCoder/global-chat/models"
+	"github.com/SomeSuperCoder/global-chat/repository"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type DB struct {
+	mu         sync.RWMutex
+	users      []*models.User
+	tokens     []*models.APIToken
+	webhooks   []*models.IncomingWebhook
+	outgoing   []*models.OutgoingWebhook
+	deliveries []*models.WebhookDelivery
+	messages   map[bson.ObjectID]*models.Message
+	settings   map[string]models.Setting
+	reports    []*models.Report
+	audit      []models.AuditEvent
+}
+
+func New() *DB {
+	return &DB{
+		messages: map[bson.ObjectID]*models.Message{},
+		settings: map[string]models.Setting{},
+	}
+}
+
+func NewStores() *repository.Stores {
+	db := New()
+	users := &UserRepo{DB: db}
+
+	return &repository.Stores{
+		Users:      users,
+		Sessions:   users,
+		Tokens:     &TokenRepo{DB: db},
+		Webhooks:   &WebhookRepo{DB: db},
+		Deliveries: &DeliveryRepo{DB: db},
+		Messages:   &MessageRepo{DB: db},
+		Settings:   &SettingsRepo{DB: db},
+		Reports:    &ReportRepo{DB: db},
+		Audit:      &AuditRepo{DB: db},
+	}
+}
+
+// Stored values are never handed out directly, so callers cannot mutate them
+// without going through the repository
+
+func cloneUser(user *models.User) *models.User {
+	clone := *user
+	clone.Sessions = slices.Clone(user.Sessions)
+	clone.Sanctions = slices.Clone(user.Sanctions)
+	return &clone
+}
+
+func cloneToken(token *models.APIToken) *models.APIToken {
+	clone := *token
+	clone.Scopes = slices.Clone(token.Scopes)
+	return &clone
+}
+
+func cloneOutgoingWebhook(hook *models.OutgoingWebhook) *models.OutgoingWebhook {
+	clone := *hook
+	clone.Events = slices.Clone(hook.Events)
+	return &clone
+}
+
+func cloneDelivery(delivery *models.WebhookDelivery) *models.WebhookDelivery {
+	clone := *delivery
+	if delivery.DeliveredAt != nil {
+		deliveredAt := *delivery.DeliveredAt
+		clone.DeliveredAt = &deliveredAt
+	}
+	return &clone
+}
+
+func cloneMessage(message *models.Message) *models.Message {
+	clone := *message
+	clone.Rendered.Tokens = slices.Clone(message.Rendered.Tokens)
+	return &clone
+}
+
+func paginate[T any](items []T, page, limit int64) []T {
+	skip := (page - 1) * limit
+	if skip < 0 {
+		skip = 0
+	}
+	if skip >= int64(len(items)) {
+		return []T{}
+	}
+
+	items = items[skip:]
+	if limit > 0 && limit < int64(len(items)) {
+		items = items[:limit]
+	}
+	return items
+}
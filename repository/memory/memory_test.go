@@ -0,0 +1,14 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/SomeSuperCoder/global-chat/repository"
+	"github.com/SomeSuperCoder/global-chat/repository/storetest"
+)
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) *repository.Stores {
+		return NewStores()
+	})
+}
@@ -0,0 +1,98 @@
+package memory
+
+import (
+	"context"
+	"slices"
+
+	"github.com/SomeSuperCoder/global-chat/models"
+	"github.com/SomeSuperCoder/global-chat/repository"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type MessageRepo struct {
+	DB *DB
+}
+
+func (r *MessageRepo) FindPaged(ctx context.Context, page, limit int64) ([]models.Message, int64, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	// Messages hidden by moderation are not listed
+	visible := []*models.Message{}
+	for _, message := range r.DB.messages {
+		if !message.Hidden {
+			visible = append(visible, message)
+		}
+	}
+	slices.SortFunc(visible, func(a, b *models.Message) int {
+		return b.CratedAt.Compare(a.CratedAt)
+	})
+
+	messages := []models.Message{}
+	for _, message := range paginate(visible, page, limit) {
+		messages = append(messages, *cloneMessage(message))
+	}
+
+	return messages, int64(len(visible)), nil
+}
+
+func (r *MessageRepo) GetMessageByID(ctx context.Context, messageID bson.ObjectID) (*models.Message, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	message, ok := r.DB.messages[messageID]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+
+	return cloneMessage(message), nil
+}
+
+func (r *MessageRepo) CreateMessage(ctx context.Context, message models.Message) error {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	stored := cloneMessage(&message)
+	if stored.ID.IsZero() {
+		stored.ID = bson.NewObjectID()
+	}
+	r.DB.messages[stored.ID] = stored
+
+	return nil
+}
+
+func (r *MessageRepo) DeleteMessage(ctx context.Context, messageID bson.ObjectID) error {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	delete(r.DB.messages, messageID)
+	return nil
+}
+
+func (r *MessageRepo) UpdateMessage(ctx context.Context, messageID bson.ObjectID, update models.MessageUpdate) error {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	message, ok := r.DB.messages[messageID]
+	if !ok {
+		return nil
+	}
+	if update.Text != nil {
+		message.Text = *update.Text
+	}
+	if update.Rendered != nil {
+		message.Rendered = *update.Rendered
+	}
+
+	return nil
+}
+
+func (r *MessageRepo) SetHidden(ctx context.Context, messageID bson.ObjectID, hidden bool) error {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	if message, ok := r.DB.messages[messageID]; ok {
+		message.Hidden = hidden
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/SomeSuperCoder/global-chat/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type ReportRepo struct {
+	DB *DB
+}
+
+func (r *ReportRepo) AddReport(ctx context.Context, report models.Report) (bool, error) {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	for _, existing := range r.DB.reports {
+		if existing.ResolvedAt == nil && existing.MessageID == report.MessageID && existing.Reporter == report.Reporter {
+			return false, nil
+		}
+	}
+
+	stored := report
+	if stored.ID.IsZero() {
+		stored.ID = bson.NewObjectID()
+	}
+	r.DB.reports = append(r.DB.reports, &stored)
+
+	return true, nil
+}
+
+func (r *ReportRepo) CountOpen(ctx context.Context, messageID bson.ObjectID) (int64, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	var count int64
+	for _, report := range r.DB.reports {
+		if report.ResolvedAt == nil && report.MessageID == messageID {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (r *ReportRepo) FindOpenGrouped(ctx context.Context, page, limit int64) ([]models.ReportGroup, int64, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	// Group open reports by message
+	byMessage := map[bson.ObjectID]*models.ReportGroup{}
+	groups := []*models.ReportGroup{}
+	for _, report := range r.DB.reports {
+		if report.ResolvedAt != nil {
+			continue
+		}
+
+		group, ok := byMessage[report.MessageID]
+		if !ok {
+			group = &models.ReportGroup{
+				MessageID:       report.MessageID,
+				Categories:      []models.ReportCategory{},
+				FirstReportedAt: report.CratedAt,
+				LastReportedAt:  report.CratedAt,
+			}
+			byMessage[report.MessageID] = group
+			groups = append(groups, group)
+		}
+
+		group.Count++
+		if !slices.Contains(group.Categories, report.Category) {
+			group.Categories = append(group.Categories, report.Category)
+		}
+		if report.CratedAt.Before(group.FirstReportedAt) {
+			group.FirstReportedAt = report.CratedAt
+		}
+		if report.CratedAt.After(group.LastReportedAt) {
+			group.LastReportedAt = report.CratedAt
+		}
+	}
+
+	slices.SortFunc(groups, func(a, b *models.ReportGroup) int {
+		if a.Count != b.Count {
+			return int(b.Count - a.Count)
+		}
+		return b.LastReportedAt.Compare(a.LastReportedAt)
+	})
+
+	result := []models.ReportGroup{}
+	for _, group := range paginate(groups, page, limit) {
+		if message, ok := r.DB.messages[group.MessageID]; ok {
+			group.Message = cloneMessage(message)
+		}
+		result = append(result, *group)
+	}
+
+	return result, int64(len(groups)), nil
+}
+
+func (r *ReportRepo) ResolveAll(ctx context.Context, messageID bson.ObjectID, resolution models.ReportResolution, resolvedBy bson.ObjectID, resolvedAt time.Time) (int64, error) {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	var resolved int64
+	for _, report := range r.DB.reports {
+		if report.ResolvedAt != nil || report.MessageID != messageID {
+			continue
+		}
+
+		at := resolvedAt
+		report.Resolution = resolution
+		report.ResolvedBy = resolvedBy
+		report.ResolvedAt = &at
+		resolved++
+	}
+
+	return resolved, nil
+}
@@ -0,0 +1,33 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/SomeSuperCoder/global-chat/models"
+	"github.com/SomeSuperCoder/global-chat/repository"
+)
+
+type SettingsRepo struct {
+	DB *DB
+}
+
+func (r *SettingsRepo) GetSetting(ctx context.Context, key string) (*models.Setting, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	setting, ok := r.DB.settings[key]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+
+	return &setting, nil
+}
+
+func (r *SettingsRepo) PutSetting(ctx context.Context, setting models.Setting) error {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	r.DB.settings[setting.Key] = setting
+
+	return nil
+}
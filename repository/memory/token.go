@@ -0,0 +1,57 @@
+package memory
+
+import (
+	"context"
+	"slices"
+
+	"github.com/SomeSuperCoder/global-chat/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type TokenRepo struct {
+	DB *DB
+}
+
+func (r *TokenRepo) CreateToken(ctx context.Context, token models.APIToken) error {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	stored := cloneToken(&token)
+	if stored.ID.IsZero() {
+		stored.ID = bson.NewObjectID()
+	}
+	r.DB.tokens = append(r.DB.tokens, stored)
+
+	return nil
+}
+
+func (r *TokenRepo) FindTokens(ctx context.Context, userID bson.ObjectID) ([]models.APIToken, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	tokens := []models.APIToken{}
+	for _, token := range r.DB.tokens {
+		if token.UserID == userID {
+			tokens = append(tokens, *cloneToken(token))
+		}
+	}
+
+	// Newest first
+	slices.SortStableFunc(tokens, func(a, b models.APIToken) int {
+		return b.CratedAt.Compare(a.CratedAt)
+	})
+
+	return tokens, nil
+}
+
+func (r *TokenRepo) DeleteToken(ctx context.Context, userID bson.ObjectID, tokenID bson.ObjectID) (bool, error) {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	before := len(r.DB.tokens)
+	r.DB.tokens = slices.DeleteFunc(r.DB.tokens, func(t *models.APIToken) bool {
+		return t.ID == tokenID && t.UserID == userID
+	})
+
+	return len(r.DB.tokens) < before, nil
+}
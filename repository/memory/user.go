@@ -0,0 +1,189 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/SomeSuperCoder/global-chat/models"
+	"github.com/SomeSuperCoder/global-chat/repository"
+	"github.com/SomeSuperCoder/global-chat/usernames"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type UserRepo struct {
+	DB *DB
+}
+
+func (r *UserRepo) CreateUser(ctx context.Context, user *models.User) error {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	key := usernames.Key(user.Username)
+	if r.DB.find(func(u *models.User) bool { return u.UsernameKey == key }) != nil {
+		return repository.ErrAlreadyExists
+	}
+
+	if user.ID.IsZero() {
+		user.ID = bson.NewObjectID()
+	}
+	user.UsernameKey = key
+	r.DB.users = append(r.DB.users, cloneUser(user))
+
+	return nil
+}
+
+func (r *UserRepo) GetUserByID(ctx context.Context, userID bson.ObjectID) (*models.User, error) {
+	return r.getUserCommon(func(u *models.User) bool { return u.ID == userID })
+}
+
+func (r *UserRepo) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	key := usernames.Key(username)
+	return r.getUserCommon(func(u *models.User) bool { return u.UsernameKey == key })
+}
+
+func (r *UserRepo) getUserCommon(match func(*models.User) bool) (*models.User, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	user := r.DB.find(match)
+	if user == nil {
+		return nil, repository.ErrNotFound
+	}
+
+	// Sessions are never returned, like the projection of the Mongo repo
+	clone := cloneUser(user)
+	clone.Sessions = nil
+	return clone, nil
+}
+
+func (r *UserRepo) DoesExist(ctx context.Context, username string) (bool, error) {
+	_, err := r.GetUserByUsername(ctx, username)
+	if errors.Is(err, repository.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *UserRepo) SetRole(ctx context.Context, userID bson.ObjectID, role models.Role) error {
+	r.DB.updateUser(func(u *models.User) bool { return u.ID == userID }, func(u *models.User) {
+		u.Role = role
+	})
+	return nil
+}
+
+func (r *UserRepo) SetPasswordHash(ctx context.Context, userID bson.ObjectID, hashedPassword string) error {
+	r.DB.updateUser(func(u *models.User) bool { return u.ID == userID }, func(u *models.User) {
+		u.HashedPassword = hashedPassword
+	})
+	return nil
+}
+
+func (r *UserRepo) AddSanction(ctx context.Context, userID bson.ObjectID, sanction models.Sanction) error {
+	r.DB.updateUser(func(u *models.User) bool { return u.ID == userID }, func(u *models.User) {
+		u.Sanctions = append(u.Sanctions, sanction)
+		if sanction.Kind == models.SanctionBan {
+			u.Sessions = []models.UserSession{}
+		}
+	})
+	return nil
+}
+
+func (r *UserRepo) LiftSanctions(ctx context.Context, userID bson.ObjectID, kinds []models.SanctionKind, liftedAt time.Time) error {
+	r.DB.updateUser(func(u *models.User) bool { return u.ID == userID }, func(u *models.User) {
+		for i := range u.Sanctions {
+			s := &u.Sanctions[i]
+			if s.LiftedAt == nil && slices.Contains(kinds, s.Kind) {
+				lifted := liftedAt
+				s.LiftedAt = &lifted
+			}
+		}
+	})
+	return nil
+}
+
+func (r *UserRepo) AddLoginSession(ctx context.Context, username string, session models.UserSession) error {
+	r.DB.updateUser(func(u *models.User) bool { return u.Username == username }, func(u *models.User) {
+		u.Sessions = append(u.Sessions, session)
+	})
+	return nil
+}
+
+func (r *UserRepo) FinalizeSession(ctx context.Context, username string, sessionToken string) error {
+	r.DB.updateUser(func(u *models.User) bool { return u.Username == username }, func(u *models.User) {
+		u.Sessions = slices.DeleteFunc(u.Sessions, func(s models.UserSession) bool {
+			return s.SessionToken == sessionToken
+		})
+	})
+	return nil
+}
+
+func (r *UserRepo) AuthCheck(ctx context.Context, sessionToken string, csrfToken string) (*repository.UserAuth, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	user := r.DB.find(func(u *models.User) bool {
+		return slices.ContainsFunc(u.Sessions, func(s models.UserSession) bool {
+			return s.SessionToken == sessionToken && s.CSRFToken == csrfToken
+		})
+	})
+	if user == nil {
+		return nil, repository.ErrNotFound
+	}
+
+	userAuth := &repository.UserAuth{
+		Username:  user.Username,
+		UserID:    user.ID,
+		Role:      user.Role,
+		Sanctions: slices.Clone(user.Sanctions),
+	}
+
+	return userAuth, nil
+}
+
+func (r *UserRepo) TokenAuthCheck(ctx context.Context, hashedToken string) (*repository.UserAuth, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	i := slices.IndexFunc(r.DB.tokens, func(t *models.APIToken) bool { return t.HashedToken == hashedToken })
+	if i == -1 {
+		return nil, repository.ErrNotFound
+	}
+	token := r.DB.tokens[i]
+
+	user := r.DB.find(func(u *models.User) bool { return u.ID == token.UserID })
+	if user == nil {
+		return nil, repository.ErrNotFound
+	}
+
+	userAuth := &repository.UserAuth{
+		Username:  user.Username,
+		UserID:    user.ID,
+		Role:      user.Role,
+		Sanctions: slices.Clone(user.Sanctions),
+		Token:     cloneToken(token),
+	}
+
+	return userAuth, nil
+}
+
+// Callers must hold the lock
+func (db *DB) find(match func(*models.User) bool) *models.User {
+	for _, user := range db.users {
+		if match(user) {
+			return user
+		}
+	}
+	return nil
+}
+
+// Updates only the first match, like UpdateOne
+func (db *DB) updateUser(match func(*models.User) bool, update func(*models.User)) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if user := db.find(match); user != nil {
+		update(user)
+	}
+}
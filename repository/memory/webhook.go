@@ -0,0 +1,127 @@
+package memory
+
+import (
+	"context"
+	"slices"
+
+	"github.com/SomeSuperCoder/global-chat/models"
+	"github.com/SomeSuperCoder/global-chat/repository"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type WebhookRepo struct {
+	DB *DB
+}
+
+func (r *WebhookRepo) CreateIncomingWebhook(ctx context.Context, hook models.IncomingWebhook) error {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	if hook.ID.IsZero() {
+		hook.ID = bson.NewObjectID()
+	}
+	r.DB.webhooks = append(r.DB.webhooks, &hook)
+
+	return nil
+}
+
+func (r *WebhookRepo) GetIncomingWebhook(ctx context.Context, hashedToken string) (*models.IncomingWebhook, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	for _, hook := range r.DB.webhooks {
+		if hook.HashedToken == hashedToken {
+			clone := *hook
+			return &clone, nil
+		}
+	}
+
+	return nil, repository.ErrNotFound
+}
+
+func (r *WebhookRepo) FindIncomingWebhooks(ctx context.Context) ([]models.IncomingWebhook, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	hooks := make([]models.IncomingWebhook, 0, len(r.DB.webhooks))
+	for _, hook := range r.DB.webhooks {
+		hooks = append(hooks, *hook)
+	}
+
+	// Newest first
+	slices.SortStableFunc(hooks, func(a, b models.IncomingWebhook) int {
+		return b.CratedAt.Compare(a.CratedAt)
+	})
+
+	return hooks, nil
+}
+
+func (r *WebhookRepo) DeleteIncomingWebhook(ctx context.Context, hookID bson.ObjectID) (bool, error) {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	before := len(r.DB.webhooks)
+	r.DB.webhooks = slices.DeleteFunc(r.DB.webhooks, func(h *models.IncomingWebhook) bool {
+		return h.ID == hookID
+	})
+
+	return len(r.DB.webhooks) < before, nil
+}
+
+func (r *WebhookRepo) CreateOutgoingWebhook(ctx context.Context, hook models.OutgoingWebhook) error {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	stored := cloneOutgoingWebhook(&hook)
+	if stored.ID.IsZero() {
+		stored.ID = bson.NewObjectID()
+	}
+	r.DB.outgoing = append(r.DB.outgoing, stored)
+
+	return nil
+}
+
+func (r *WebhookRepo) GetOutgoingWebhook(ctx context.Context, hookID bson.ObjectID) (*models.OutgoingWebhook, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	for _, hook := range r.DB.outgoing {
+		if hook.ID == hookID {
+			return cloneOutgoingWebhook(hook), nil
+		}
+	}
+
+	return nil, repository.ErrNotFound
+}
+
+func (r *WebhookRepo) FindOutgoingWebhooks(ctx context.Context) ([]models.OutgoingWebhook, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	hooks := make([]models.OutgoingWebhook, 0, len(r.DB.outgoing))
+	for _, hook := range r.DB.outgoing {
+		hooks = append(hooks, *cloneOutgoingWebhook(hook))
+	}
+
+	// Newest first
+	slices.SortStableFunc(hooks, func(a, b models.OutgoingWebhook) int {
+		return b.CratedAt.Compare(a.CratedAt)
+	})
+
+	return hooks, nil
+}
+
+func (r *WebhookRepo) DeleteOutgoingWebhook(ctx context.Context, hookID bson.ObjectID) (bool, error) {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	before := len(r.DB.outgoing)
+	r.DB.outgoing = slices.DeleteFunc(r.DB.outgoing, func(h *models.OutgoingWebhook) bool {
+		return h.ID == hookID
+	})
+	r.DB.deliveries = slices.DeleteFunc(r.DB.deliveries, func(d *models.WebhookDelivery) bool {
+		return d.WebhookID == hookID
+	})
+
+	return len(r.DB.outgoing) < before, nil
+}
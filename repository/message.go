@@ -23,8 +23,11 @@ func (r *MessageRepo) FindPaged(ctx context.Context, page, limit int64) ([]model
 	opts.SetSkip(skip)
 	opts.SetSort(bson.M{"created_at": -1})
 
+	// Messages hidden by moderation are not listed
+	filter := bson.M{"hidden": bson.M{"$ne": true}}
+
 	// Init a cursor
-	cursor, err := r.Database.Collection("messages").Find(ctx, bson.M{}, opts)
+	cursor, err := r.Database.Collection("messages").Find(ctx, filter, opts)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -37,11 +40,21 @@ func (r *MessageRepo) FindPaged(ctx context.Context, page, limit int64) ([]model
 	}
 
 	// Get total message count
-	count, err := r.Database.Collection("messages").CountDocuments(ctx, bson.M{})
+	count, err := r.Database.Collection("messages").CountDocuments(ctx, filter)
 
 	return messages, count, err // TODO: check if this works)))
 }
 
+func (r *MessageRepo) GetMessageByID(ctx context.Context, messageID bson.ObjectID) (*models.Message, error) {
+	var message models.Message
+	err := r.Database.Collection("messages").FindOne(ctx, bson.M{"_id": messageID}).Decode(&message)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	return &message, nil
+}
+
 func (r *MessageRepo) CreateMessage(ctx context.Context, message models.Message) error {
 	_, err := r.Database.Collection("messages").InsertOne(ctx, message)
 	return err
@@ -52,9 +65,16 @@ func (r *MessageRepo) DeleteMessage(ctx context.Context, messageID bson.ObjectID
 	return err
 }
 
-func (r *MessageRepo) UpdateMessage(ctx context.Context, messageID bson.ObjectID, update any) error {
+func (r *MessageRepo) UpdateMessage(ctx context.Context, messageID bson.ObjectID, update models.MessageUpdate) error {
 	_, err := r.Database.Collection("messages").UpdateByID(ctx, messageID, bson.M{
 		"$set": update,
 	})
 	return err
 }
+
+func (r *MessageRepo) SetHidden(ctx context.Context, messageID bson.ObjectID, hidden bool) error {
+	_, err := r.Database.Collection("messages").UpdateByID(ctx, messageID, bson.M{
+		"$set": bson.M{"hidden": hidden},
+	})
+	return err
+}
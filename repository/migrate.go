@@ -0,0 +1,373 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/SomeSuperCoder/global-chat/models"
+	"github.com/SomeSuperCoder/global-chat/usernames"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Applied migrations are recorded in this collection, keyed by version
+const migrationsCollection = "schema_migrations"
+
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(ctx context.Context, db *mongo.Database) error
+}
+
+type AppliedMigration struct {
+	Version     int       `bson:"_id"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"applied_at"`
+}
+
+// Migrations are applied in order and never change once released, add a new
+// version instead. Every step is idempotent, so an instance that crashes
+// before recording a migration can simply run it again.
+var Migrations = []Migration{
+	{
+		Version:     1,
+		Description: "unique index on users.username",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return createIndexes(ctx, db.Collection("users"),
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "username", Value: 1}},
+					Options: options.Index().SetName("username_unique").SetUnique(true),
+				},
+			)
+		},
+	},
+	{
+		Version:     2,
+		Description: "session lookup index for AuthCheck",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return createIndexes(ctx, db.Collection("users"),
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "sessions.session_token", Value: 1}},
+					Options: options.Index().SetName("session_token"),
+				},
+			)
+		},
+	},
+	{
+		Version:     3,
+		Description: "message ordering index",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return createIndexes(ctx, db.Collection("messages"),
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "created_at", Value: -1}},
+					Options: options.Index().SetName("created_at_desc"),
+				},
+			)
+		},
+	},
+	{
+		Version:     4,
+		Description: "report queue and audit log indexes",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			err := createIndexes(ctx, db.Collection("reports"),
+				// One open report per reporter and message
+				mongo.IndexModel{
+					Keys: bson.D{{Key: "message_id", Value: 1}, {Key: "reporter", Value: 1}},
+					Options: options.Index().SetName("open_report_unique").SetUnique(true).
+						SetPartialFilterExpression(bson.M{"resolved_at": bson.M{"$type": "null"}}),
+				},
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "resolved_at", Value: 1}, {Key: "message_id", Value: 1}},
+					Options: options.Index().SetName("resolved_at_message_id"),
+				},
+			)
+			if err != nil {
+				return err
+			}
+
+			return createIndexes(ctx, db.Collection("audit_events"),
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "created_at", Value: -1}},
+					Options: options.Index().SetName("created_at_desc"),
+				},
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "actor", Value: 1}, {Key: "created_at", Value: -1}},
+					Options: options.Index().SetName("actor_created_at"),
+				},
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "target", Value: 1}, {Key: "created_at", Value: -1}},
+					Options: options.Index().SetName("target_created_at"),
+				},
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "action", Value: 1}, {Key: "created_at", Value: -1}},
+					Options: options.Index().SetName("action_created_at"),
+				},
+			)
+		},
+	},
+	{
+		Version:     5,
+		Description: "collection validators for users and messages",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			roles := bson.A{}
+			for _, role := range []models.Role{models.RoleUser, models.RoleModerator, models.RoleAdmin} {
+				roles = append(roles, role)
+			}
+
+			err := setValidator(ctx, db, "users", bson.M{
+				"bsonType": "object",
+				"required": bson.A{"username", "hashed_password", "created_at"},
+				"properties": bson.M{
+					"username":        bson.M{"bsonType": "string", "minLength": 1},
+					"hashed_password": bson.M{"bsonType": "string"},
+					"role":            bson.M{"enum": roles},
+					"sessions":        bson.M{"bsonType": bson.A{"array", "null"}},
+					"sanctions":       bson.M{"bsonType": bson.A{"array", "null"}},
+					"created_at":      bson.M{"bsonType": "date"},
+				},
+			})
+			if err != nil {
+				return err
+			}
+
+			return setValidator(ctx, db, "messages", bson.M{
+				"bsonType": "object",
+				"required": bson.A{"author", "text", "created_at"},
+				"properties": bson.M{
+					"author":     bson.M{"bsonType": "objectId"},
+					"text":       bson.M{"bsonType": "string"},
+					"rendered":   bson.M{"bsonType": "object"},
+					"hidden":     bson.M{"bsonType": "bool"},
+					"created_at": bson.M{"bsonType": "date"},
+				},
+			})
+		},
+	},
+	{
+		Version:     6,
+		Description: "unique index on the canonical username",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			err := backfillUsernameKeys(ctx, db.Collection("users"))
+			if err != nil {
+				return err
+			}
+
+			return createIndexes(ctx, db.Collection("users"),
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "username_key", Value: 1}},
+					Options: options.Index().SetName("username_key_unique").SetUnique(true),
+				},
+			)
+		},
+	},
+	{
+		Version:     7,
+		Description: "API token indexes",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return createIndexes(ctx, db.Collection("api_tokens"),
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "hashed_token", Value: 1}},
+					Options: options.Index().SetName("hashed_token_unique").SetUnique(true),
+				},
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}},
+					Options: options.Index().SetName("user_id_created_at"),
+				},
+			)
+		},
+	},
+	{
+		Version:     8,
+		Description: "incoming webhook token index",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return createIndexes(ctx, db.Collection("incoming_webhooks"),
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "hashed_token", Value: 1}},
+					Options: options.Index().SetName("hashed_token_unique").SetUnique(true),
+				},
+			)
+		},
+	},
+	{
+		Version:     9,
+		Description: "webhook delivery queue indexes",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return createIndexes(ctx, db.Collection("webhook_deliveries"),
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}},
+					Options: options.Index().SetName("status_next_attempt_at"),
+				},
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "webhook_id", Value: 1}, {Key: "created_at", Value: -1}},
+					Options: options.Index().SetName("webhook_id_created_at"),
+				},
+			)
+		},
+	},
+	{
+		Version:     10,
+		Description: "username keys from the Unicode confusables skeleton",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			// Dropped while the keys change, so names that only collide under
+			// the new key can be renamed first
+			users := db.Collection("users")
+			err := users.Indexes().DropOne(ctx, "username_key_unique")
+			if err != nil {
+				return err
+			}
+
+			err = backfillUsernameKeys(ctx, users)
+			if err != nil {
+				return err
+			}
+
+			return createIndexes(ctx, users,
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "username_key", Value: 1}},
+					Options: options.Index().SetName("username_key_unique").SetUnique(true),
+				},
+			)
+		},
+	},
+}
+
+// Migrate applies every pending migration and records it in schema_migrations.
+// In dry-run mode nothing is changed, the migrations that would be applied
+// are returned instead.
+func Migrate(ctx context.Context, db *mongo.Database, dryRun bool) ([]Migration, error) {
+	pending, err := PendingMigrations(ctx, db)
+	if err != nil || dryRun {
+		return pending, err
+	}
+
+	for _, m := range pending {
+		err = m.Up(ctx, db)
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply migration %d (%s): %w", m.Version, m.Description, err)
+		}
+
+		_, err = db.Collection(migrationsCollection).InsertOne(ctx, AppliedMigration{
+			Version:     m.Version,
+			Description: m.Description,
+			AppliedAt:   time.Now(),
+		})
+		// Another instance may have applied the same migration concurrently
+		if err != nil && !mongo.IsDuplicateKeyError(err) {
+			return nil, fmt.Errorf("failed to record migration %d: %w", m.Version, err)
+		}
+	}
+
+	return pending, nil
+}
+
+func PendingMigrations(ctx context.Context, db *mongo.Database) ([]Migration, error) {
+	cursor, err := db.Collection(migrationsCollection).Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []AppliedMigration
+	err = cursor.All(ctx, &applied)
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(map[int]bool, len(applied))
+	for _, m := range applied {
+		done[m.Version] = true
+	}
+
+	var pending []Migration
+	for _, m := range Migrations {
+		if !done[m.Version] {
+			pending = append(pending, m)
+		}
+	}
+
+	return pending, nil
+}
+
+func createIndexes(ctx context.Context, collection *mongo.Collection, indexes ...mongo.IndexModel) error {
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// Documents that already exist are only validated when they are updated
+func setValidator(ctx context.Context, db *mongo.Database, collection string, schema bson.M) error {
+	validator := bson.M{"$jsonSchema": schema}
+
+	names, err := db.ListCollectionNames(ctx, bson.M{"name": collection})
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		opts := options.CreateCollection().SetValidator(validator).SetValidationLevel("moderate")
+		return db.CreateCollection(ctx, collection, opts)
+	}
+
+	return db.RunCommand(ctx, bson.D{
+		{Key: "collMod", Value: collection},
+		{Key: "validator", Value: validator},
+		{Key: "validationLevel", Value: "moderate"},
+	}).Err()
+}
+
+// The key cannot be computed by MongoDB itself. Users whose names end up
+// with the same key, because they were registered before the key existed or
+// before it changed, cannot all keep them: the oldest one does, the others
+// are renamed and every rename is logged.
+func backfillUsernameKeys(ctx context.Context, users *mongo.Collection) error {
+	opts := options.Find().SetProjection(bson.M{"username": 1, "username_key": 1, "created_at": 1})
+	cursor, err := users.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return err
+	}
+
+	var found []models.User
+	err = cursor.All(ctx, &found)
+	if err != nil {
+		return err
+	}
+
+	accounts := make([]usernames.Account, 0, len(found))
+	for _, user := range found {
+		accounts = append(accounts, usernames.Account{
+			ID:        user.ID.Hex(),
+			Username:  user.Username,
+			CreatedAt: user.CratedAt,
+		})
+	}
+
+	renamed := map[string]string{}
+	for _, rename := range usernames.Deduplicate(accounts) {
+		logRename(rename)
+		renamed[rename.ID] = rename.To
+	}
+
+	for _, user := range found {
+		username, ok := renamed[user.ID.Hex()]
+		if !ok {
+			username = user.Username
+		}
+		key := usernames.Key(username)
+		if key == user.UsernameKey && !ok {
+			continue
+		}
+
+		_, err = users.UpdateByID(ctx, user.ID, bson.M{
+			"$set": bson.M{"username": username, "username_key": key},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func logRename(rename usernames.Rename) {
+	log.Warnf("Renamed %s. The new name works for logging in, "+
+		"change it in the users collection if it should be something else.", rename)
+}
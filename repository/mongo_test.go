@@ -0,0 +1,41 @@
+package repository_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/SomeSuperCoder/global-chat/repository"
+	"github.com/SomeSuperCoder/global-chat/repository/storetest"
+	"github.com/SomeSuperCoder/global-chat/utils"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Needs a server, like CHAT_TEST_MONGO_URI=mongodb://localhost:27017
+func TestConformance(t *testing.T) {
+	uri := os.Getenv("CHAT_TEST_MONGO_URI")
+	if uri == "" {
+		t.Skip("CHAT_TEST_MONGO_URI is not set")
+	}
+
+	ctx := context.Background()
+	client, err := mongo.Connect(options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Disconnect(ctx) })
+
+	storetest.Run(t, func(t *testing.T) *repository.Stores {
+		// Every call gets a database of its own
+		db := client.Database(fmt.Sprintf("storetest_%x", utils.GenerateToken(6)))
+		t.Cleanup(func() { db.Drop(ctx) })
+
+		_, err := repository.Migrate(ctx, db, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return repository.NewMongoStores(db)
+	})
+}
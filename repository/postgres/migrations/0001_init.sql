@@ -0,0 +1,89 @@
+-- IDs are stored as the hex form of a MongoDB ObjectID, so they look the
+-- same in the API no matter which backend is used
+
+CREATE TABLE users (
+    id              CHAR(24)    PRIMARY KEY,
+    username        TEXT        NOT NULL,
+    hashed_password TEXT        NOT NULL,
+    role            TEXT        NOT NULL DEFAULT 'user',
+    created_at      TIMESTAMPTZ NOT NULL
+);
+
+CREATE UNIQUE INDEX users_username_idx ON users (username);
+
+CREATE TABLE sessions (
+    session_token TEXT        PRIMARY KEY,
+    csrf_token    TEXT        NOT NULL,
+    user_id       CHAR(24)    NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at    TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX sessions_user_id_idx ON sessions (user_id);
+
+CREATE TABLE sanctions (
+    id         BIGSERIAL   PRIMARY KEY,
+    user_id    CHAR(24)    NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    kind       TEXT        NOT NULL,
+    reason     TEXT        NOT NULL,
+    issued_by  CHAR(24)    NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ,
+    lifted_at  TIMESTAMPTZ
+);
+
+CREATE INDEX sanctions_user_id_idx ON sanctions (user_id, id);
+
+CREATE TABLE messages (
+    id         CHAR(24)    PRIMARY KEY,
+    author     CHAR(24)    NOT NULL,
+    text       TEXT        NOT NULL,
+    rendered   JSONB       NOT NULL,
+    hidden     BOOLEAN     NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX messages_visible_created_at_idx ON messages (created_at DESC) WHERE NOT hidden;
+
+CREATE TABLE reports (
+    id          CHAR(24)    PRIMARY KEY,
+    message_id  CHAR(24)    NOT NULL,
+    reporter    CHAR(24)    NOT NULL,
+    category    TEXT        NOT NULL,
+    reason      TEXT        NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL,
+    resolution  TEXT,
+    resolved_by CHAR(24),
+    resolved_at TIMESTAMPTZ
+);
+
+-- One open report per reporter and message
+CREATE UNIQUE INDEX reports_open_reporter_idx ON reports (message_id, reporter) WHERE resolved_at IS NULL;
+
+CREATE TABLE audit_events (
+    id         CHAR(24)    PRIMARY KEY,
+    action     TEXT        NOT NULL,
+    actor      CHAR(24),
+    username   TEXT,
+    target     CHAR(24),
+    message_id CHAR(24),
+    reason     TEXT,
+    expires_at TIMESTAMPTZ,
+    ip         TEXT        NOT NULL,
+    user_agent TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX audit_events_created_at_idx ON audit_events (created_at DESC);
+CREATE INDEX audit_events_actor_idx ON audit_events (actor, created_at DESC);
+CREATE INDEX audit_events_target_idx ON audit_events (target, created_at DESC);
+CREATE INDEX audit_events_action_idx ON audit_events (action, created_at DESC);
+
+CREATE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_append_only
+    BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
@@ -0,0 +1,15 @@
+ALTER TABLE users ADD COLUMN is_bot BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- The secret is kept in plain text, it is needed to verify signatures
+CREATE TABLE incoming_webhooks (
+    id           CHAR(24)    PRIMARY KEY,
+    bot_id       CHAR(24)    NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name         TEXT        NOT NULL,
+    hashed_token TEXT        NOT NULL,
+    secret       TEXT        NOT NULL,
+    rate_limit   INTEGER     NOT NULL,
+    created_by   CHAR(24)    NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL
+);
+
+CREATE UNIQUE INDEX incoming_webhooks_hashed_token_idx ON incoming_webhooks (hashed_token);
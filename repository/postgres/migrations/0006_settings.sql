@@ -0,0 +1,6 @@
+CREATE TABLE settings (
+    name       TEXT        PRIMARY KEY,
+    value      TEXT        NOT NULL,
+    updated_by CHAR(24),
+    updated_at TIMESTAMPTZ NOT NULL
+);
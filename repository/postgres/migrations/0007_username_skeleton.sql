@@ -0,0 +1,4 @@
+-- usernames.Key now uses the Unicode confusables skeleton. The keys are
+-- computed again by the application after migrating, which also renames the
+-- users whose names collide under the new key.
+UPDATE users SET username_key = NULL;
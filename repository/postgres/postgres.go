@@ -0,0 +1,90 @@
+// Package postgres implements the repository interfaces on top of PostgreSQL
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"github.com/SomeSuperCoder/global-chat/repository"
+	"github.com/SomeSuperCoder/global-chat/repository/sqlstore"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Arbitrary key for pg_advisory_xact_lock, so that only one instance migrates at a time
+const migrationLockKey = 727_001
+
+// SQLSTATE of unique_violation
+const uniqueViolation = "23505"
+
+func Open(ctx context.Context, dsn string) (*sqlstore.DB, error) {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+	err = db.PingContext(timeoutCtx)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return sqlstore.New(db, dialect()), nil
+}
+
+func dialect() *sqlstore.Dialect {
+	migrations, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		panic(err)
+	}
+
+	return &sqlstore.Dialect{
+		Name:                 "postgres",
+		NumberedPlaceholders: true,
+		// LIMIT NULL is the same as no limit
+		NoLimit: nil,
+		GroupConcat: func(expr string) string {
+			return fmt.Sprintf("string_agg(DISTINCT %s, ',')", expr)
+		},
+		TimeValue: func(t time.Time) any { return t },
+		MigrationsTable: `
+			CREATE TABLE IF NOT EXISTS schema_migrations (
+				version    INTEGER     PRIMARY KEY,
+				name       TEXT        NOT NULL,
+				applied_at TIMESTAMPTZ NOT NULL
+			)`,
+		MigrationsTableExists: `SELECT to_regclass('schema_migrations') IS NOT NULL`,
+		Migrations:            migrations,
+		LockMigrations: func(ctx context.Context, tx sqlstore.Execer) error {
+			_, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationLockKey)
+			return err
+		},
+		IsUniqueViolation: func(err error) bool {
+			var pgErr *pgconn.PgError
+			return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation
+		},
+	}
+}
+
+func Migrate(ctx context.Context, db *sqlstore.DB) error {
+	return sqlstore.Migrate(ctx, db)
+}
+
+func PendingMigrations(ctx context.Context, db *sqlstore.DB) ([]string, error) {
+	return sqlstore.PendingMigrations(ctx, db)
+}
+
+func NewStores(db *sqlstore.DB) *repository.Stores {
+	return sqlstore.NewStores(db)
+}
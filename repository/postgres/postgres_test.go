@@ -0,0 +1,61 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/SomeSuperCoder/global-chat/repository"
+	"github.com/SomeSuperCoder/global-chat/repository/storetest"
+	"github.com/SomeSuperCoder/global-chat/utils"
+)
+
+// Needs a server, like
+// CHAT_TEST_POSTGRES_DSN=postgres://localhost:5432/chat_test?sslmode=disable
+func TestConformance(t *testing.T) {
+	dsn := os.Getenv("CHAT_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("CHAT_TEST_POSTGRES_DSN is not set")
+	}
+
+	ctx := context.Background()
+	admin, err := Open(ctx, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.SQL.Close() })
+
+	storetest.Run(t, func(t *testing.T) *repository.Stores {
+		// Every call gets a schema of its own
+		schema := fmt.Sprintf("storetest_%x", utils.GenerateToken(6))
+		_, err := admin.SQL.ExecContext(ctx, fmt.Sprintf(`CREATE SCHEMA "%s"`, schema))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			admin.SQL.ExecContext(ctx, fmt.Sprintf(`DROP SCHEMA "%s" CASCADE`, schema))
+		})
+
+		schemaDSN, err := url.Parse(dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		query := schemaDSN.Query()
+		query.Set("search_path", schema)
+		schemaDSN.RawQuery = query.Encode()
+
+		db, err := Open(ctx, schemaDSN.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.SQL.Close() })
+
+		err = Migrate(ctx, db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return NewStores(db)
+	})
+}
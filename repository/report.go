@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/SomeSuperCoder/global-chat/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type ReportRepo struct {
+	Database *mongo.Database
+}
+
+// Returns false if the reporter already has an open report for this message
+func (r *ReportRepo) AddReport(ctx context.Context, report models.Report) (bool, error) {
+	filter := bson.M{
+		"message_id":  report.MessageID,
+		"reporter":    report.Reporter,
+		"resolved_at": nil,
+	}
+	update := bson.M{
+		"$setOnInsert": report,
+	}
+
+	res, err := r.Database.Collection("reports").UpdateOne(ctx, filter, update, options.UpdateOne().SetUpsert(true))
+	// A concurrent upsert of the same report loses against the unique index
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return res.UpsertedCount > 0, nil
+}
+
+func (r *ReportRepo) CountOpen(ctx context.Context, messageID bson.ObjectID) (int64, error) {
+	return r.Database.Collection("reports").CountDocuments(ctx, bson.M{
+		"message_id":  messageID,
+		"resolved_at": nil,
+	})
+}
+
+func (r *ReportRepo) FindOpenGrouped(ctx context.Context, page, limit int64) ([]models.ReportGroup, int64, error) {
+	skip := (page - 1) * limit
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"resolved_at": nil}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":               "$message_id",
+			"count":             bson.M{"$sum": 1},
+			"categories":        bson.M{"$addToSet": "$category"},
+			"first_reported_at": bson.M{"$min": "$created_at"},
+			"last_reported_at":  bson.M{"$max": "$created_at"},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "last_reported_at", Value: -1}}}},
+		{{Key: "$facet", Value: bson.M{
+			"groups": bson.A{
+				bson.M{"$skip": skip},
+				bson.M{"$limit": limit},
+				bson.M{"$lookup": bson.M{
+					"from":         "messages",
+					"localField":   "_id",
+					"foreignField": "_id",
+					"as":           "message",
+				}},
+				bson.M{"$unwind": bson.M{"path": "$message", "preserveNullAndEmptyArrays": true}},
+			},
+			"total": bson.A{
+				bson.M{"$count": "count"},
+			},
+		}}},
+	}
+
+	cursor, err := r.Database.Collection("reports").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var result []struct {
+		Groups []models.ReportGroup `bson:"groups"`
+		Total  []struct {
+			Count int64 `bson:"count"`
+		} `bson:"total"`
+	}
+	err = cursor.All(ctx, &result)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	groups := []models.ReportGroup{}
+	var total int64
+	if len(result) > 0 {
+		if result[0].Groups != nil {
+			groups = result[0].Groups
+		}
+		if len(result[0].Total) > 0 {
+			total = result[0].Total[0].Count
+		}
+	}
+
+	return groups, total, nil
+}
+
+func (r *ReportRepo) ResolveAll(ctx context.Context, messageID bson.ObjectID, resolution models.ReportResolution, resolvedBy bson.ObjectID, resolvedAt time.Time) (int64, error) {
+	filter := bson.M{
+		"message_id":  messageID,
+		"resolved_at": nil,
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"resolution":  resolution,
+			"resolved_by": resolvedBy,
+			"resolved_at": resolvedAt,
+		},
+	}
+
+	res, err := r.Database.Collection("reports").UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.ModifiedCount, nil
+}
@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/SomeSuperCoder/global-chat/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type SettingsRepo struct {
+	Database *mongo.Database
+}
+
+func (r *SettingsRepo) GetSetting(ctx context.Context, key string) (*models.Setting, error) {
+	var setting models.Setting
+	err := r.Database.Collection("settings").FindOne(ctx, bson.M{"_id": key}).Decode(&setting)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	return &setting, nil
+}
+
+func (r *SettingsRepo) PutSetting(ctx context.Context, setting models.Setting) error {
+	opts := options.Replace().SetUpsert(true)
+	_, err := r.Database.Collection("settings").ReplaceOne(ctx, bson.M{"_id": setting.Key}, setting, opts)
+	return err
+}
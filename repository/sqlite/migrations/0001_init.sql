@@ -0,0 +1,92 @@
+-- IDs are stored as the hex form of a MongoDB ObjectID, so they look the
+-- same in the API no matter which backend is used. Timestamps are Unix
+-- milliseconds.
+
+CREATE TABLE users (
+    id              TEXT    PRIMARY KEY,
+    username        TEXT    NOT NULL,
+    hashed_password TEXT    NOT NULL,
+    role            TEXT    NOT NULL DEFAULT 'user',
+    created_at      INTEGER NOT NULL
+);
+
+CREATE UNIQUE INDEX users_username_idx ON users (username);
+
+CREATE TABLE sessions (
+    session_token TEXT    PRIMARY KEY,
+    csrf_token    TEXT    NOT NULL,
+    user_id       TEXT    NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at    INTEGER NOT NULL
+);
+
+CREATE INDEX sessions_user_id_idx ON sessions (user_id);
+
+CREATE TABLE sanctions (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id    TEXT    NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    kind       TEXT    NOT NULL,
+    reason     TEXT    NOT NULL,
+    issued_by  TEXT    NOT NULL,
+    created_at INTEGER NOT NULL,
+    expires_at INTEGER,
+    lifted_at  INTEGER
+);
+
+CREATE INDEX sanctions_user_id_idx ON sanctions (user_id, id);
+
+CREATE TABLE messages (
+    id         TEXT    PRIMARY KEY,
+    author     TEXT    NOT NULL,
+    text       TEXT    NOT NULL,
+    rendered   TEXT    NOT NULL,
+    hidden     INTEGER NOT NULL DEFAULT 0,
+    created_at INTEGER NOT NULL
+);
+
+CREATE INDEX messages_visible_created_at_idx ON messages (created_at DESC) WHERE NOT hidden;
+
+CREATE TABLE reports (
+    id          TEXT    PRIMARY KEY,
+    message_id  TEXT    NOT NULL,
+    reporter    TEXT    NOT NULL,
+    category    TEXT    NOT NULL,
+    reason      TEXT    NOT NULL,
+    created_at  INTEGER NOT NULL,
+    resolution  TEXT,
+    resolved_by TEXT,
+    resolved_at INTEGER
+);
+
+-- One open report per reporter and message
+CREATE UNIQUE INDEX reports_open_reporter_idx ON reports (message_id, reporter) WHERE resolved_at IS NULL;
+
+CREATE TABLE audit_events (
+    id         TEXT    PRIMARY KEY,
+    action     TEXT    NOT NULL,
+    actor      TEXT,
+    username   TEXT,
+    target     TEXT,
+    message_id TEXT,
+    reason     TEXT,
+    expires_at INTEGER,
+    ip         TEXT    NOT NULL,
+    user_agent TEXT    NOT NULL,
+    created_at INTEGER NOT NULL
+);
+
+CREATE INDEX audit_events_created_at_idx ON audit_events (created_at DESC);
+CREATE INDEX audit_events_actor_idx ON audit_events (actor, created_at DESC);
+CREATE INDEX audit_events_target_idx ON audit_events (target, created_at DESC);
+CREATE INDEX audit_events_action_idx ON audit_events (action, created_at DESC);
+
+CREATE TRIGGER audit_events_no_update
+    BEFORE UPDATE ON audit_events
+BEGIN
+    SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
+
+CREATE TRIGGER audit_events_no_delete
+    BEFORE DELETE ON audit_events
+BEGIN
+    SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
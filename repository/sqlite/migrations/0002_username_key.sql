@@ -0,0 +1,5 @@
+-- Canonical form of the username used for comparisons, see usernames.Key.
+-- Existing rows are filled in by the application after migrating.
+ALTER TABLE users ADD COLUMN username_key TEXT;
+
+CREATE UNIQUE INDEX users_username_key_idx ON users (username_key);
@@ -0,0 +1,14 @@
+-- Scopes are stored comma separated
+CREATE TABLE api_tokens (
+    id           TEXT    PRIMARY KEY,
+    user_id      TEXT    NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name         TEXT    NOT NULL,
+    prefix       TEXT    NOT NULL,
+    hashed_token TEXT    NOT NULL,
+    scopes       TEXT    NOT NULL,
+    created_at   INTEGER NOT NULL,
+    expires_at   INTEGER
+);
+
+CREATE UNIQUE INDEX api_tokens_hashed_token_idx ON api_tokens (hashed_token);
+CREATE INDEX api_tokens_user_id_idx ON api_tokens (user_id, created_at DESC);
@@ -0,0 +1,15 @@
+ALTER TABLE users ADD COLUMN is_bot INTEGER NOT NULL DEFAULT 0;
+
+-- The secret is kept in plain text, it is needed to verify signatures
+CREATE TABLE incoming_webhooks (
+    id           TEXT    PRIMARY KEY,
+    bot_id       TEXT    NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name         TEXT    NOT NULL,
+    hashed_token TEXT    NOT NULL,
+    secret       TEXT    NOT NULL,
+    rate_limit   INTEGER NOT NULL,
+    created_by   TEXT    NOT NULL,
+    created_at   INTEGER NOT NULL
+);
+
+CREATE UNIQUE INDEX incoming_webhooks_hashed_token_idx ON incoming_webhooks (hashed_token);
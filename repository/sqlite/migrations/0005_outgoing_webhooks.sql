@@ -0,0 +1,28 @@
+-- Events are stored comma separated
+CREATE TABLE outgoing_webhooks (
+    id         TEXT    PRIMARY KEY,
+    name       TEXT    NOT NULL,
+    url        TEXT    NOT NULL,
+    secret     TEXT    NOT NULL,
+    events     TEXT    NOT NULL,
+    created_by TEXT    NOT NULL,
+    created_at INTEGER NOT NULL
+);
+
+-- Delivery queue, a claimed row is hidden by moving next_attempt_at forward
+CREATE TABLE webhook_deliveries (
+    id               TEXT    PRIMARY KEY,
+    webhook_id       TEXT    NOT NULL REFERENCES outgoing_webhooks (id) ON DELETE CASCADE,
+    event            TEXT    NOT NULL,
+    payload          TEXT    NOT NULL,
+    status           TEXT    NOT NULL CHECK (status IN ('pending', 'delivered', 'dead')),
+    attempts         INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at  INTEGER NOT NULL,
+    last_status_code INTEGER NOT NULL DEFAULT 0,
+    last_error       TEXT    NOT NULL DEFAULT '',
+    created_at       INTEGER NOT NULL,
+    delivered_at     INTEGER
+);
+
+CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries (status, next_attempt_at);
+CREATE INDEX webhook_deliveries_webhook_id_idx ON webhook_deliveries (webhook_id, created_at DESC);
@@ -0,0 +1,6 @@
+CREATE TABLE settings (
+    name       TEXT    PRIMARY KEY,
+    value      TEXT    NOT NULL,
+    updated_by TEXT,
+    updated_at INTEGER NOT NULL
+);
@@ -0,0 +1,95 @@
+// Package sqlite implements the repository interfaces on top of an embedded
+// SQLite database file, using a pure Go driver so no cgo is required
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"time"
+
+	"github.com/SomeSuperCoder/global-chat/repository"
+	"github.com/SomeSuperCoder/global-chat/repository/sqlstore"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Open opens or creates the database file at path.
+// WAL mode lets readers proceed while a write is in progress, and immediate
+// transactions together with the busy timeout make concurrent writers wait
+// for each other instead of failing.
+func Open(ctx context.Context, path string) (*sqlstore.DB, error) {
+	params := url.Values{}
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Add("_pragma", "foreign_keys(1)")
+	params.Add("_pragma", "busy_timeout(5000)")
+	params.Add("_pragma", "synchronous(NORMAL)")
+	params.Set("_txlock", "immediate")
+
+	db, err := sql.Open("sqlite", "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.PingContext(ctx)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return sqlstore.New(db, dialect()), nil
+}
+
+func dialect() *sqlstore.Dialect {
+	migrations, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		panic(err)
+	}
+
+	return &sqlstore.Dialect{
+		Name: "sqlite",
+		// LIMIT -1 is the same as no limit
+		NoLimit: -1,
+		GroupConcat: func(expr string) string {
+			return fmt.Sprintf("group_concat(DISTINCT %s)", expr)
+		},
+		// Timestamps are stored as Unix milliseconds, so they sort and compare as numbers
+		TimeValue: func(t time.Time) any { return t.UnixMilli() },
+		MigrationsTable: `
+			CREATE TABLE IF NOT EXISTS schema_migrations (
+				version    INTEGER PRIMARY KEY,
+				name       TEXT    NOT NULL,
+				applied_at INTEGER NOT NULL
+			)`,
+		MigrationsTableExists: `SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations')`,
+		Migrations:            migrations,
+		IsUniqueViolation: func(err error) bool {
+			var sqliteErr *sqlite.Error
+			if !errors.As(err, &sqliteErr) {
+				return false
+			}
+			code := sqliteErr.Code()
+			return code == sqlite3.SQLITE_CONSTRAINT_UNIQUE || code == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+		},
+	}
+}
+
+func Migrate(ctx context.Context, db *sqlstore.DB) error {
+	return sqlstore.Migrate(ctx, db)
+}
+
+func PendingMigrations(ctx context.Context, db *sqlstore.DB) ([]string, error) {
+	return sqlstore.PendingMigrations(ctx, db)
+}
+
+func NewStores(db *sqlstore.DB) *repository.Stores {
+	return sqlstore.NewStores(db)
+}
@@ -0,0 +1,124 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"io/fs"
+	"path/filepath"
+	"testing"
+
+	"github.com/SomeSuperCoder/global-chat/repository"
+	"github.com/SomeSuperCoder/global-chat/repository/storetest"
+	"github.com/SomeSuperCoder/global-chat/usernames"
+)
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) *repository.Stores {
+		db, err := Open(context.Background(), filepath.Join(t.TempDir(), "chat.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.SQL.Close() })
+
+		err = Migrate(context.Background(), db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return NewStores(db)
+	})
+}
+
+func TestMigrateRenamesCollidingUsernames(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(ctx, filepath.Join(t.TempDir(), "chat.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.SQL.Close()
+
+	err = Migrate(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Users from before username_key, whose names only collide under the key
+	users := []struct {
+		id, username string
+		createdAt    int64
+	}{
+		{"000000000000000000000002", "аdmin", 2000},
+		{"000000000000000000000001", "Admin", 1000},
+		{"000000000000000000000003", "PayPaI", 1000},
+		{"000000000000000000000004", "paypal", 3000},
+	}
+	for _, user := range users {
+		_, err = db.SQL.ExecContext(ctx, `INSERT INTO users (id, username, hashed_password, created_at) VALUES (?, ?, '', ?)`,
+			user.id, user.username, user.createdAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = Migrate(ctx, db)
+	if err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+
+	want := map[string]string{
+		"000000000000000000000001": "Admin",
+		"000000000000000000000002": "аdmin_000002",
+		"000000000000000000000003": "PayPaI",
+		"000000000000000000000004": "paypal_000004",
+	}
+	for id, username := range want {
+		var got string
+		var key sql.NullString
+		err = db.SQL.QueryRowContext(ctx, `SELECT username, username_key FROM users WHERE id = ?`, id).Scan(&got, &key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != username || key.String != usernames.Key(username) {
+			t.Errorf("user %s = %q with key %q, want %q with key %q", id, got, key.String, username, usernames.Key(username))
+		}
+	}
+}
+
+func TestPendingMigrationsOnlyReads(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(ctx, filepath.Join(t.TempDir(), "chat.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.SQL.Close()
+
+	all, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pending, err := PendingMigrations(ctx, db)
+	if err != nil {
+		t.Fatalf("PendingMigrations() error = %v", err)
+	}
+	if len(pending) != len(all) {
+		t.Errorf("PendingMigrations() = %v, want all %d migrations", pending, len(all))
+	}
+
+	var tables int
+	err = db.SQL.QueryRowContext(ctx, `SELECT count(*) FROM sqlite_master WHERE name = 'schema_migrations'`).Scan(&tables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tables != 0 {
+		t.Error("PendingMigrations() created schema_migrations")
+	}
+
+	err = Migrate(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending, err = PendingMigrations(ctx, db)
+	if err != nil || len(pending) != 0 {
+		t.Errorf("PendingMigrations() after Migrate = %v, %v, want none", pending, err)
+	}
+}
@@ -0,0 +1,109 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/SomeSuperCoder/global-chat/models"
+	"github.com/SomeSuperCoder/global-chat/repository"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Audit events are append-only, a trigger rejects updates and deletes
+type AuditRepo struct {
+	DB *DB
+}
+
+func (r *AuditRepo) Record(ctx context.Context, event models.AuditEvent) error {
+	id := event.ID
+	if id.IsZero() {
+		id = bson.NewObjectID()
+	}
+
+	_, err := r.DB.exec(ctx, `
+		INSERT INTO audit_events (id, action, actor, username, target, message_id, reason, expires_at, ip, user_agent, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		id.Hex(), event.Action, nullableID(event.Actor), nullableString(event.Username), nullableID(event.Target),
+		nullableID(event.MessageID), nullableString(event.Reason), r.DB.nullableTime(event.ExpiresAt),
+		event.IP, event.UserAgent, r.DB.timeValue(event.CratedAt),
+	)
+	return err
+}
+
+func (r *AuditRepo) FindPaged(ctx context.Context, filter repository.AuditFilter, page, limit int64) ([]models.AuditEvent, int64, error) {
+	var events = []models.AuditEvent{}
+
+	// Build the filter
+	var conditions []string
+	var args []any
+	where := func(condition string, values ...any) {
+		conditions = append(conditions, condition)
+		args = append(args, values...)
+	}
+	if !filter.Actor.IsZero() {
+		where("actor = ?", filter.Actor.Hex())
+	}
+	if !filter.Target.IsZero() {
+		where("target = ?", filter.Target.Hex())
+	}
+	if !filter.MessageID.IsZero() {
+		where("message_id = ?", filter.MessageID.Hex())
+	}
+	if len(filter.Actions) > 0 {
+		actionList, actionArgs := in(filter.Actions)
+		where("action IN "+actionList, actionArgs...)
+	}
+	if filter.IP != "" {
+		where("ip = ?", filter.IP)
+	}
+	if !filter.Since.IsZero() {
+		where("created_at >= ?", r.DB.timeValue(filter.Since))
+	}
+	if !filter.Until.IsZero() {
+		where("created_at < ?", r.DB.timeValue(filter.Until))
+	}
+
+	whereClause := ""
+	if len(conditions) > 0 {
+		whereClause = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	rows, err := r.DB.query(ctx, `
+		SELECT id, action, actor, username, target, message_id, reason, expires_at, ip, user_agent, created_at
+		FROM audit_events
+		`+whereClause+`
+		ORDER BY created_at DESC
+		LIMIT ? OFFSET ?`,
+		append(args, r.DB.limit(limit), offset(page, limit))...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event models.AuditEvent
+		var username, reason sql.NullString
+
+		err = rows.Scan(
+			scanID(&event.ID), &event.Action, scanID(&event.Actor), &username, scanID(&event.Target),
+			scanID(&event.MessageID), &reason, scanNullableTime(&event.ExpiresAt), &event.IP, &event.UserAgent, scanTime(&event.CratedAt),
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+		event.Username = username.String
+		event.Reason = reason.String
+
+		events = append(events, event)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	var count int64
+	err = r.DB.queryRow(ctx, `SELECT COUNT(*) FROM audit_events `+whereClause, args...).Scan(&count)
+
+	return events, count, err
+}
@@ -0,0 +1,148 @@
+package sqlstore
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/SomeSuperCoder/global-chat/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type DeliveryRepo struct {
+	DB *DB
+}
+
+const deliveryColumns = `id, webhook_id, event, payload, status, attempts, next_attempt_at, last_status_code, last_error, created_at, delivered_at`
+
+func scanDelivery(row row) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+
+	err := row.Scan(scanID(&delivery.ID), scanID(&delivery.WebhookID), &delivery.Event, &delivery.Payload,
+		&delivery.Status, &delivery.Attempts, scanTime(&delivery.NextAttemptAt), &delivery.LastStatusCode,
+		&delivery.LastError, scanTime(&delivery.CratedAt), scanNullableTime(&delivery.DeliveredAt))
+	if err != nil {
+		return nil, err
+	}
+
+	return &delivery, nil
+}
+
+func (r *DeliveryRepo) EnqueueDeliveries(ctx context.Context, deliveries []models.WebhookDelivery) error {
+	return r.DB.withTx(ctx, func(tx runner) error {
+		for _, delivery := range deliveries {
+			id := delivery.ID
+			if id.IsZero() {
+				id = bson.NewObjectID()
+			}
+
+			_, err := tx.exec(ctx, `
+				INSERT INTO webhook_deliveries (`+deliveryColumns+`)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				id.Hex(), delivery.WebhookID.Hex(), delivery.Event, delivery.Payload, delivery.Status,
+				delivery.Attempts, tx.timeValue(delivery.NextAttemptAt), delivery.LastStatusCode,
+				delivery.LastError, tx.timeValue(delivery.CratedAt), tx.nullableTime(delivery.DeliveredAt),
+			)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// The conditions are repeated outside the subquery, so a worker that lost
+// the race for a row updates nothing instead of claiming it twice
+func (r *DeliveryRepo) ClaimDelivery(ctx context.Context, now, leaseUntil time.Time) (*models.WebhookDelivery, error) {
+	row := r.DB.queryRow(ctx, `
+		UPDATE webhook_deliveries
+		SET next_attempt_at = ?
+		WHERE id = (
+			SELECT id FROM webhook_deliveries
+			WHERE status = ? AND next_attempt_at <= ?
+			ORDER BY next_attempt_at
+			LIMIT 1
+		) AND status = ? AND next_attempt_at <= ?
+		RETURNING `+deliveryColumns,
+		r.DB.timeValue(leaseUntil),
+		models.DeliveryPending, r.DB.timeValue(now),
+		models.DeliveryPending, r.DB.timeValue(now),
+	)
+
+	delivery, err := scanDelivery(row)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	return delivery, nil
+}
+
+func (r *DeliveryRepo) UpdateDelivery(ctx context.Context, delivery models.WebhookDelivery) error {
+	_, err := r.DB.exec(ctx, `
+		UPDATE webhook_deliveries
+		SET status = ?, attempts = ?, next_attempt_at = ?, last_status_code = ?, last_error = ?, delivered_at = ?
+		WHERE id = ?`,
+		delivery.Status, delivery.Attempts, r.DB.timeValue(delivery.NextAttemptAt), delivery.LastStatusCode,
+		delivery.LastError, r.DB.nullableTime(delivery.DeliveredAt), delivery.ID.Hex(),
+	)
+	return err
+}
+
+func (r *DeliveryRepo) FindDeliveries(ctx context.Context, hookID bson.ObjectID, status models.DeliveryStatus, page, limit int64) ([]models.WebhookDelivery, int64, error) {
+	var deliveries = []models.WebhookDelivery{}
+
+	conditions := []string{"webhook_id = ?"}
+	args := []any{hookID.Hex()}
+	if status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, status)
+	}
+	whereClause := "WHERE " + strings.Join(conditions, " AND ")
+
+	rows, err := r.DB.query(ctx, `
+		SELECT `+deliveryColumns+`
+		FROM webhook_deliveries
+		`+whereClause+`
+		ORDER BY created_at DESC
+		LIMIT ? OFFSET ?`,
+		append(args, r.DB.limit(limit), offset(page, limit))...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		deliveries = append(deliveries, *delivery)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	var count int64
+	err = r.DB.queryRow(ctx, `SELECT COUNT(*) FROM webhook_deliveries `+whereClause, args...).Scan(&count)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return deliveries, count, nil
+}
+
+func (r *DeliveryRepo) RetryDelivery(ctx context.Context, deliveryID bson.ObjectID, now time.Time) (bool, error) {
+	res, err := r.DB.exec(ctx, `
+		UPDATE webhook_deliveries
+		SET status = ?, attempts = 0, next_attempt_at = ?
+		WHERE id = ? AND status = ?`,
+		models.DeliveryPending, r.DB.timeValue(now), deliveryID.Hex(), models.DeliveryDead,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	retried, err := res.RowsAffected()
+	return retried > 0, err
+}
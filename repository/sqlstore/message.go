@@ -0,0 +1,132 @@
+package sqlstore
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/SomeSuperCoder/global-chat/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type MessageRepo struct {
+	DB *DB
+}
+
+const messageColumns = `id, author, text, rendered, hidden, created_at`
+
+type row interface {
+	Scan(dest ...any) error
+}
+
+func scanMessage(row row) (*models.Message, error) {
+	var message models.Message
+	var rendered string
+
+	err := row.Scan(scanID(&message.ID), scanID(&message.Author), &message.Text, &rendered, &message.Hidden, scanTime(&message.CratedAt))
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal([]byte(rendered), &message.Rendered)
+	if err != nil {
+		return nil, err
+	}
+
+	return &message, nil
+}
+
+func (r *MessageRepo) FindPaged(ctx context.Context, page, limit int64) ([]models.Message, int64, error) {
+	var messages = []models.Message{}
+
+	// Messages hidden by moderation are not listed
+	rows, err := r.DB.query(ctx, `
+		SELECT `+messageColumns+`
+		FROM messages
+		WHERE NOT hidden
+		ORDER BY created_at DESC
+		LIMIT ? OFFSET ?`,
+		r.DB.limit(limit), offset(page, limit),
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		message, err := scanMessage(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		messages = append(messages, *message)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	// Get total message count
+	var count int64
+	err = r.DB.queryRow(ctx, `SELECT COUNT(*) FROM messages WHERE NOT hidden`).Scan(&count)
+
+	return messages, count, err
+}
+
+func (r *MessageRepo) GetMessageByID(ctx context.Context, messageID bson.ObjectID) (*models.Message, error) {
+	row := r.DB.queryRow(ctx, `SELECT `+messageColumns+` FROM messages WHERE id = ?`, messageID.Hex())
+
+	message, err := scanMessage(row)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	return message, nil
+}
+
+func (r *MessageRepo) CreateMessage(ctx context.Context, message models.Message) error {
+	id := message.ID
+	if id.IsZero() {
+		id = bson.NewObjectID()
+	}
+
+	rendered, err := json.Marshal(message.Rendered)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.DB.exec(ctx, `
+		INSERT INTO messages (id, author, text, rendered, hidden, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		id.Hex(), message.Author.Hex(), message.Text, string(rendered), message.Hidden, r.DB.timeValue(message.CratedAt),
+	)
+	return err
+}
+
+func (r *MessageRepo) DeleteMessage(ctx context.Context, messageID bson.ObjectID) error {
+	_, err := r.DB.exec(ctx, `DELETE FROM messages WHERE id = ?`, messageID.Hex())
+	return err
+}
+
+func (r *MessageRepo) UpdateMessage(ctx context.Context, messageID bson.ObjectID, update models.MessageUpdate) error {
+	var rendered *string
+	if update.Rendered != nil {
+		encoded, err := json.Marshal(update.Rendered)
+		if err != nil {
+			return err
+		}
+		s := string(encoded)
+		rendered = &s
+	}
+
+	// NULL parameters keep the current value
+	_, err := r.DB.exec(ctx, `
+		UPDATE messages
+		SET text = COALESCE(?, text), rendered = COALESCE(?, rendered)
+		WHERE id = ?`,
+		update.Text, rendered, messageID.Hex(),
+	)
+	return err
+}
+
+func (r *MessageRepo) SetHidden(ctx context.Context, messageID bson.ObjectID, hidden bool) error {
+	_, err := r.DB.exec(ctx, `UPDATE messages SET hidden = ? WHERE id = ?`, hidden, messageID.Hex())
+	return err
+}
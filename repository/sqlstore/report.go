@@ -0,0 +1,135 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/SomeSuperCoder/global-chat/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type ReportRepo struct {
+	DB *DB
+}
+
+// Returns false if the reporter already has an open report for this message
+func (r *ReportRepo) AddReport(ctx context.Context, report models.Report) (bool, error) {
+	id := report.ID
+	if id.IsZero() {
+		id = bson.NewObjectID()
+	}
+
+	res, err := r.DB.exec(ctx, `
+		INSERT INTO reports (id, message_id, reporter, category, reason, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (message_id, reporter) WHERE resolved_at IS NULL DO NOTHING`,
+		id.Hex(), report.MessageID.Hex(), report.Reporter.Hex(), report.Category, report.Reason, r.DB.timeValue(report.CratedAt),
+	)
+	if err != nil {
+		return false, err
+	}
+
+	inserted, err := res.RowsAffected()
+	return inserted > 0, err
+}
+
+func (r *ReportRepo) CountOpen(ctx context.Context, messageID bson.ObjectID) (int64, error) {
+	var count int64
+	err := r.DB.queryRow(ctx, `
+		SELECT COUNT(*) FROM reports
+		WHERE message_id = ? AND resolved_at IS NULL`, messageID.Hex(),
+	).Scan(&count)
+	return count, err
+}
+
+func (r *ReportRepo) FindOpenGrouped(ctx context.Context, page, limit int64) ([]models.ReportGroup, int64, error) {
+	groups := []models.ReportGroup{}
+
+	rows, err := r.DB.query(ctx, `
+		WITH grouped AS (
+			SELECT message_id,
+			       COUNT(*) AS count,
+			       `+r.DB.dialect.GroupConcat("category")+` AS categories,
+			       MIN(created_at) AS first_reported_at,
+			       MAX(created_at) AS last_reported_at
+			FROM reports
+			WHERE resolved_at IS NULL
+			GROUP BY message_id
+		)
+		SELECT g.message_id, g.count, g.categories, g.first_reported_at, g.last_reported_at,
+		       m.id, m.author, m.text, m.rendered, m.hidden, m.created_at
+		FROM grouped g
+		LEFT JOIN messages m ON m.id = g.message_id
+		ORDER BY g.count DESC, g.last_reported_at DESC
+		LIMIT ? OFFSET ?`,
+		r.DB.limit(limit), offset(page, limit),
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var group models.ReportGroup
+		var categories string
+		var message models.Message
+		var text, rendered sql.NullString
+		var hidden sql.NullBool
+		var createdAt *time.Time
+
+		err = rows.Scan(
+			scanID(&group.MessageID), &group.Count, &categories, scanTime(&group.FirstReportedAt), scanTime(&group.LastReportedAt),
+			scanID(&message.ID), scanID(&message.Author), &text, &rendered, &hidden, scanNullableTime(&createdAt),
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		for _, category := range strings.Split(categories, ",") {
+			group.Categories = append(group.Categories, models.ReportCategory(category))
+		}
+
+		// The message may have been deleted in the meantime
+		if !message.ID.IsZero() {
+			message.Text = text.String
+			message.Hidden = hidden.Bool
+			if createdAt != nil {
+				message.CratedAt = *createdAt
+			}
+			err = json.Unmarshal([]byte(rendered.String), &message.Rendered)
+			if err != nil {
+				return nil, 0, err
+			}
+			group.Message = &message
+		}
+
+		groups = append(groups, group)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	var total int64
+	err = r.DB.queryRow(ctx, `
+		SELECT COUNT(DISTINCT message_id) FROM reports WHERE resolved_at IS NULL`,
+	).Scan(&total)
+
+	return groups, total, err
+}
+
+func (r *ReportRepo) ResolveAll(ctx context.Context, messageID bson.ObjectID, resolution models.ReportResolution, resolvedBy bson.ObjectID, resolvedAt time.Time) (int64, error) {
+	res, err := r.DB.exec(ctx, `
+		UPDATE reports
+		SET resolution = ?, resolved_by = ?, resolved_at = ?
+		WHERE message_id = ? AND resolved_at IS NULL`,
+		resolution, resolvedBy.Hex(), r.DB.timeValue(resolvedAt), messageID.Hex(),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
@@ -0,0 +1,36 @@
+package sqlstore
+
+import (
+	"context"
+
+	"github.com/SomeSuperCoder/global-chat/models"
+)
+
+type SettingsRepo struct {
+	DB *DB
+}
+
+func (r *SettingsRepo) GetSetting(ctx context.Context, key string) (*models.Setting, error) {
+	var setting models.Setting
+	err := r.DB.queryRow(ctx, `
+		SELECT name, value, updated_by, updated_at
+		FROM settings
+		WHERE name = ?`, key,
+	).Scan(&setting.Key, &setting.Value, scanID(&setting.UpdatedBy), scanTime(&setting.UpdatedAt))
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	return &setting, nil
+}
+
+func (r *SettingsRepo) PutSetting(ctx context.Context, setting models.Setting) error {
+	_, err := r.DB.exec(ctx, `
+		INSERT INTO settings (name, value, updated_by, updated_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (name) DO UPDATE
+		SET value = excluded.value, updated_by = excluded.updated_by, updated_at = excluded.updated_at`,
+		setting.Key, setting.Value, nullableID(setting.UpdatedBy), r.DB.timeValue(setting.UpdatedAt),
+	)
+	return err
+}
@@ -0,0 +1,481 @@
+// Package sqlstore implements the repository interfaces on top of
+// database/sql. The SQL differences between the supported databases are
+// described by a Dialect, the drivers themselves live in the postgres and
+// sqlite packages.
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SomeSuperCoder/global-chat/logging"
+	"github.com/SomeSuperCoder/global-chat/repository"
+	"github.com/SomeSuperCoder/global-chat/usernames"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+var log = logging.Package("sqlstore")
+
+type Dialect struct {
+	Name string
+
+	// Postgres uses $1, $2... instead of ?
+	NumberedPlaceholders bool
+
+	// LIMIT value that means no limit
+	NoLimit any
+
+	// Aggregates distinct values of an expression into a comma separated string
+	GroupConcat func(expr string) string
+
+	// Converts a timestamp into the value stored in the database
+	TimeValue func(t time.Time) any
+
+	// Statement that creates the schema_migrations table if it does not exist
+	MigrationsTable string
+	// Query that reports whether the schema_migrations table exists
+	MigrationsTableExists string
+
+	// Files named <version>_<name>.sql
+	Migrations fs.FS
+
+	// Prevents concurrent migrations, called inside the migration transaction
+	LockMigrations func(ctx context.Context, tx Execer) error
+
+	// Reports whether an error is caused by a unique constraint
+	IsUniqueViolation func(err error) bool
+}
+
+type DB struct {
+	runner
+	SQL *sql.DB
+}
+
+func New(db *sql.DB, dialect *Dialect) *DB {
+	return &DB{
+		runner: runner{q: db, dialect: dialect},
+		SQL:    db,
+	}
+}
+
+func NewStores(db *DB) *repository.Stores {
+	users := &UserRepo{DB: db}
+
+	return &repository.Stores{
+		Users:      users,
+		Sessions:   users,
+		Tokens:     &TokenRepo{DB: db},
+		Webhooks:   &WebhookRepo{DB: db},
+		Deliveries: &DeliveryRepo{DB: db},
+		Messages:   &MessageRepo{DB: db},
+		Settings:   &SettingsRepo{DB: db},
+		Reports:    &ReportRepo{DB: db},
+		Audit:      &AuditRepo{DB: db},
+	}
+}
+
+// ==============================================================
+// ================ Query helpers ===============================
+// ==============================================================
+
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Runs queries on either the database or a transaction
+type runner struct {
+	q       querier
+	dialect *Dialect
+}
+
+func (r runner) exec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return r.q.ExecContext(ctx, r.rebind(query), args...)
+}
+
+func (r runner) query(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return r.q.QueryContext(ctx, r.rebind(query), args...)
+}
+
+func (r runner) queryRow(ctx context.Context, query string, args ...any) *sql.Row {
+	return r.q.QueryRowContext(ctx, r.rebind(query), args...)
+}
+
+func (db *DB) withTx(ctx context.Context, fn func(tx runner) error) error {
+	tx, err := db.SQL.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = fn(runner{q: tx, dialect: db.dialect})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Queries are written with ? placeholders, none of them contain a literal ?
+func (r runner) rebind(query string) string {
+	if !r.dialect.NumberedPlaceholders {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// Returns "(?, ?, ?)" and the matching arguments
+func in[T ~string](values []T) (string, []any) {
+	placeholders := make([]string, len(values))
+	args := make([]any, len(values))
+	for i, v := range values {
+		placeholders[i] = "?"
+		args[i] = string(v)
+	}
+	return "(" + strings.Join(placeholders, ", ") + ")", args
+}
+
+func (r runner) limit(limit int64) any {
+	if limit <= 0 {
+		return r.dialect.NoLimit
+	}
+	return limit
+}
+
+func offset(page, limit int64) int64 {
+	if skip := (page - 1) * limit; skip > 0 {
+		return skip
+	}
+	return 0
+}
+
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return repository.ErrNotFound
+	}
+	return err
+}
+
+// ==============================================================
+// ================ Column helpers ==============================
+// ==============================================================
+
+// Zero IDs are stored as NULL
+func nullableID(id bson.ObjectID) any {
+	if id.IsZero() {
+		return nil
+	}
+	return id.Hex()
+}
+
+func nullableString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func (r runner) timeValue(t time.Time) any {
+	return r.dialect.TimeValue(t)
+}
+
+func (r runner) nullableTime(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return r.dialect.TimeValue(*t)
+}
+
+// Scans a nullable ID column into an ObjectID
+type idColumn struct {
+	dst *bson.ObjectID
+}
+
+func scanID(dst *bson.ObjectID) *idColumn {
+	return &idColumn{dst: dst}
+}
+
+func (c *idColumn) Scan(src any) error {
+	var hex string
+	switch v := src.(type) {
+	case nil:
+		*c.dst = bson.ObjectID{}
+		return nil
+	case string:
+		hex = v
+	case []byte:
+		hex = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into an ObjectID", src)
+	}
+
+	id, err := bson.ObjectIDFromHex(strings.TrimSpace(hex))
+	if err != nil {
+		return err
+	}
+	*c.dst = id
+	return nil
+}
+
+// Scans a timestamp that is either native or stored as Unix milliseconds
+type timeColumn struct {
+	dst      *time.Time
+	nullable **time.Time
+}
+
+func scanTime(dst *time.Time) *timeColumn {
+	return &timeColumn{dst: dst}
+}
+
+func scanNullableTime(dst **time.Time) *timeColumn {
+	return &timeColumn{nullable: dst}
+}
+
+func (c *timeColumn) Scan(src any) error {
+	var t time.Time
+	switch v := src.(type) {
+	case nil:
+		if c.nullable == nil {
+			return errors.New("cannot scan NULL into a time")
+		}
+		*c.nullable = nil
+		return nil
+	case time.Time:
+		t = v
+	case int64:
+		t = time.UnixMilli(v)
+	default:
+		return fmt.Errorf("cannot scan %T into a time", src)
+	}
+
+	if c.nullable != nil {
+		*c.nullable = &t
+		return nil
+	}
+	*c.dst = t
+	return nil
+}
+
+// ==============================================================
+// ================ Migrations ==================================
+// ==============================================================
+
+type migration struct {
+	version int
+	name    string
+	sql     string
+}
+
+func loadMigrations(files fs.FS) ([]migration, error) {
+	entries, err := fs.ReadDir(files, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []migration
+	for _, entry := range entries {
+		name := entry.Name()
+		prefix, _, ok := strings.Cut(name, "_")
+		if !ok || !strings.HasSuffix(name, ".sql") {
+			return nil, fmt.Errorf("migration %s is not named <version>_<name>.sql", name)
+		}
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s has an invalid version: %w", name, err)
+		}
+
+		content, err := fs.ReadFile(files, name)
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, migration{version: version, name: name, sql: string(content)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	return migrations, nil
+}
+
+// Migrate applies all migrations that are not recorded in schema_migrations yet.
+// Every migration runs in its own transaction.
+func Migrate(ctx context.Context, db *DB) error {
+	migrations, err := loadMigrations(db.dialect.Migrations)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.exec(ctx, db.dialect.MigrationsTable)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	for _, m := range migrations {
+		err = db.applyMigration(ctx, m)
+		if err != nil {
+			return fmt.Errorf("failed to apply migration %s: %w", m.name, err)
+		}
+	}
+
+	err = db.backfillUsernameKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fill in username keys: %w", err)
+	}
+
+	return nil
+}
+
+// Users created before username_key existed or before it changed get their
+// key here, since it cannot be computed in SQL. Users whose names end up with
+// the same key cannot all keep them: the oldest one does, the others are
+// renamed and every rename is logged.
+func (db *DB) backfillUsernameKeys(ctx context.Context) error {
+	var missing bool
+	err := db.queryRow(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE username_key IS NULL)`).Scan(&missing)
+	if err != nil || !missing {
+		return err
+	}
+
+	rows, err := db.query(ctx, `SELECT id, username, username_key, created_at FROM users`)
+	if err != nil {
+		return err
+	}
+
+	var accounts []usernames.Account
+	keys := map[string]string{}
+	for rows.Next() {
+		var account usernames.Account
+		var key sql.NullString
+		err = rows.Scan(&account.ID, &account.Username, &key, scanTime(&account.CreatedAt))
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		accounts = append(accounts, account)
+		keys[account.ID] = key.String
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	// Renames go first, so the names they free up can be taken by the
+	// users that keep them
+	renamed := map[string]bool{}
+	for _, rename := range usernames.Deduplicate(accounts) {
+		_, err = db.exec(ctx, `UPDATE users SET username = ?, username_key = ? WHERE id = ?`,
+			rename.To, usernames.Key(rename.To), rename.ID)
+		if err != nil {
+			return err
+		}
+		log.Warnf("Renamed %s. The new name works for logging in, "+
+			"change it in the users table if it should be something else.", rename)
+		renamed[rename.ID] = true
+	}
+
+	for _, account := range accounts {
+		if renamed[account.ID] || keys[account.ID] != "" {
+			continue
+		}
+		_, err = db.exec(ctx, `UPDATE users SET username_key = ? WHERE id = ?`, usernames.Key(account.Username), account.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PendingMigrations returns the names of the migrations Migrate would apply.
+// It only reads, so it is safe for readiness checks; without a
+// schema_migrations table every migration is pending.
+func PendingMigrations(ctx context.Context, db *DB) ([]string, error) {
+	migrations, err := loadMigrations(db.dialect.Migrations)
+	if err != nil {
+		return nil, err
+	}
+
+	var exists bool
+	err = db.queryRow(ctx, db.dialect.MigrationsTableExists).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look for schema_migrations: %w", err)
+	}
+
+	applied := map[int]bool{}
+	if exists {
+		rows, err := db.query(ctx, `SELECT version FROM schema_migrations`)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var version int
+			err = rows.Scan(&version)
+			if err != nil {
+				return nil, err
+			}
+			applied[version] = true
+		}
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	var pending []string
+	for _, m := range migrations {
+		if !applied[m.version] {
+			pending = append(pending, m.name)
+		}
+	}
+
+	return pending, nil
+}
+
+func (db *DB) applyMigration(ctx context.Context, m migration) error {
+	return db.withTx(ctx, func(tx runner) error {
+		if db.dialect.LockMigrations != nil {
+			err := db.dialect.LockMigrations(ctx, tx.q)
+			if err != nil {
+				return err
+			}
+		}
+
+		var applied bool
+		err := tx.queryRow(ctx, `SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = ?)`, m.version).Scan(&applied)
+		if err != nil || applied {
+			return err
+		}
+
+		// Migrations are plain SQL for the specific database and are not rebound
+		_, err = tx.q.ExecContext(ctx, m.sql)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.exec(ctx, `INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+			m.version, m.name, tx.timeValue(time.Now()))
+		return err
+	})
+}
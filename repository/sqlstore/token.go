@@ -0,0 +1,90 @@
+package sqlstore
+
+import (
+	"context"
+	"strings"
+
+	"github.com/SomeSuperCoder/global-chat/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type TokenRepo struct {
+	DB *DB
+}
+
+const tokenColumns = `id, user_id, name, prefix, hashed_token, scopes, created_at, expires_at`
+
+func scanToken(row row) (*models.APIToken, error) {
+	var token models.APIToken
+	var scopes string
+
+	err := row.Scan(scanID(&token.ID), scanID(&token.UserID), &token.Name, &token.Prefix, &token.HashedToken,
+		&scopes, scanTime(&token.CratedAt), scanNullableTime(&token.ExpiresAt))
+	if err != nil {
+		return nil, err
+	}
+
+	token.Scopes = []models.TokenScope{}
+	for _, scope := range strings.Split(scopes, ",") {
+		if scope != "" {
+			token.Scopes = append(token.Scopes, models.TokenScope(scope))
+		}
+	}
+
+	return &token, nil
+}
+
+func (r *TokenRepo) CreateToken(ctx context.Context, token models.APIToken) error {
+	id := token.ID
+	if id.IsZero() {
+		id = bson.NewObjectID()
+	}
+
+	scopes := make([]string, len(token.Scopes))
+	for i, scope := range token.Scopes {
+		scopes[i] = string(scope)
+	}
+
+	_, err := r.DB.exec(ctx, `
+		INSERT INTO api_tokens (`+tokenColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		id.Hex(), token.UserID.Hex(), token.Name, token.Prefix, token.HashedToken,
+		strings.Join(scopes, ","), r.DB.timeValue(token.CratedAt), r.DB.nullableTime(token.ExpiresAt),
+	)
+	return err
+}
+
+func (r *TokenRepo) FindTokens(ctx context.Context, userID bson.ObjectID) ([]models.APIToken, error) {
+	tokens := []models.APIToken{}
+
+	rows, err := r.DB.query(ctx, `
+		SELECT `+tokenColumns+`
+		FROM api_tokens
+		WHERE user_id = ?
+		ORDER BY created_at DESC`, userID.Hex(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		token, err := scanToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, *token)
+	}
+
+	return tokens, rows.Err()
+}
+
+func (r *TokenRepo) DeleteToken(ctx context.Context, userID bson.ObjectID, tokenID bson.ObjectID) (bool, error) {
+	res, err := r.DB.exec(ctx, `DELETE FROM api_tokens WHERE id = ? AND user_id = ?`, tokenID.Hex(), userID.Hex())
+	if err != nil {
+		return false, err
+	}
+
+	deleted, err := res.RowsAffected()
+	return deleted > 0, err
+}
@@ -0,0 +1,204 @@
+package sqlstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/SomeSuperCoder/global-chat/models"
+	"github.com/SomeSuperCoder/global-chat/repository"
+	"github.com/SomeSuperCoder/global-chat/usernames"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type UserRepo struct {
+	DB *DB
+}
+
+// The unique index on username_key rejects names that are already taken
+func (r *UserRepo) CreateUser(ctx context.Context, user *models.User) error {
+	if user.ID.IsZero() {
+		user.ID = bson.NewObjectID()
+	}
+	user.UsernameKey = usernames.Key(user.Username)
+	role := user.Role
+	if role == "" {
+		role = models.RoleUser
+	}
+
+	_, err := r.DB.exec(ctx, `
+		INSERT INTO users (id, username, username_key, hashed_password, role, is_bot, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		user.ID.Hex(), user.Username, user.UsernameKey, user.HashedPassword, role, user.IsBot, r.DB.timeValue(user.CratedAt),
+	)
+	if err != nil && r.DB.dialect.IsUniqueViolation(err) {
+		return repository.ErrAlreadyExists
+	}
+	return err
+}
+
+func (r *UserRepo) GetUserByID(ctx context.Context, userID bson.ObjectID) (*models.User, error) {
+	return r.getUserCommon(ctx, `WHERE id = ?`, userID.Hex())
+}
+
+func (r *UserRepo) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	return r.getUserCommon(ctx, `WHERE username_key = ?`, usernames.Key(username))
+}
+
+// Sessions are never loaded, like the projection of the Mongo repo
+func (r *UserRepo) getUserCommon(ctx context.Context, where string, args ...any) (*models.User, error) {
+	var user models.User
+	err := r.DB.queryRow(ctx, `
+		SELECT id, username, username_key, hashed_password, role, is_bot, created_at
+		FROM users `+where, args...,
+	).Scan(scanID(&user.ID), &user.Username, &user.UsernameKey, &user.HashedPassword, &user.Role, &user.IsBot, scanTime(&user.CratedAt))
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	user.Sanctions, err = r.getSanctions(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (r *UserRepo) getSanctions(ctx context.Context, userID bson.ObjectID) ([]models.Sanction, error) {
+	rows, err := r.DB.query(ctx, `
+		SELECT kind, reason, issued_by, created_at, expires_at, lifted_at
+		FROM sanctions
+		WHERE user_id = ?
+		ORDER BY id`, userID.Hex(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sanctions := []models.Sanction{}
+	for rows.Next() {
+		var s models.Sanction
+		err = rows.Scan(&s.Kind, &s.Reason, scanID(&s.IssuedBy), scanTime(&s.CratedAt), scanNullableTime(&s.ExpiresAt), scanNullableTime(&s.LiftedAt))
+		if err != nil {
+			return nil, err
+		}
+		sanctions = append(sanctions, s)
+	}
+
+	return sanctions, rows.Err()
+}
+
+func (r *UserRepo) DoesExist(ctx context.Context, username string) (bool, error) {
+	var exists bool
+	err := r.DB.queryRow(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE username_key = ?)`, usernames.Key(username)).Scan(&exists)
+	return exists, err
+}
+
+func (r *UserRepo) SetRole(ctx context.Context, userID bson.ObjectID, role models.Role) error {
+	_, err := r.DB.exec(ctx, `UPDATE users SET role = ? WHERE id = ?`, role, userID.Hex())
+	return err
+}
+
+func (r *UserRepo) SetPasswordHash(ctx context.Context, userID bson.ObjectID, hashedPassword string) error {
+	_, err := r.DB.exec(ctx, `UPDATE users SET hashed_password = ? WHERE id = ?`, hashedPassword, userID.Hex())
+	return err
+}
+
+// Adding a ban also terminates every session of the user
+func (r *UserRepo) AddSanction(ctx context.Context, userID bson.ObjectID, sanction models.Sanction) error {
+	return r.DB.withTx(ctx, func(tx runner) error {
+		// Unknown users are ignored instead of violating the foreign key
+		_, err := tx.exec(ctx, `
+			INSERT INTO sanctions (user_id, kind, reason, issued_by, created_at, expires_at, lifted_at)
+			SELECT id, ?, ?, ?, ?, ?, ? FROM users WHERE id = ?`,
+			sanction.Kind, sanction.Reason, sanction.IssuedBy.Hex(), tx.timeValue(sanction.CratedAt),
+			tx.nullableTime(sanction.ExpiresAt), tx.nullableTime(sanction.LiftedAt), userID.Hex(),
+		)
+		if err != nil {
+			return err
+		}
+
+		if sanction.Kind == models.SanctionBan {
+			_, err = tx.exec(ctx, `DELETE FROM sessions WHERE user_id = ?`, userID.Hex())
+		}
+		return err
+	})
+}
+
+func (r *UserRepo) LiftSanctions(ctx context.Context, userID bson.ObjectID, kinds []models.SanctionKind, liftedAt time.Time) error {
+	if len(kinds) == 0 {
+		return nil
+	}
+	kindList, kindArgs := in(kinds)
+
+	args := append([]any{r.DB.timeValue(liftedAt), userID.Hex()}, kindArgs...)
+	_, err := r.DB.exec(ctx, `
+		UPDATE sanctions SET lifted_at = ?
+		WHERE user_id = ? AND lifted_at IS NULL AND kind IN `+kindList,
+		args...,
+	)
+	return err
+}
+
+func (r *UserRepo) AddLoginSession(ctx context.Context, username string, session models.UserSession) error {
+	_, err := r.DB.exec(ctx, `
+		INSERT INTO sessions (session_token, csrf_token, user_id, created_at)
+		SELECT ?, ?, id, ? FROM users WHERE username = ?`,
+		session.SessionToken, session.CSRFToken, r.DB.timeValue(session.CratedAt), username,
+	)
+	return err
+}
+
+func (r *UserRepo) FinalizeSession(ctx context.Context, username string, sessionToken string) error {
+	_, err := r.DB.exec(ctx, `
+		DELETE FROM sessions
+		WHERE session_token = ? AND user_id = (SELECT id FROM users WHERE username = ?)`,
+		sessionToken, username,
+	)
+	return err
+}
+
+func (r *UserRepo) AuthCheck(ctx context.Context, sessionToken string, csrfToken string) (*repository.UserAuth, error) {
+	var userAuth repository.UserAuth
+
+	err := r.DB.queryRow(ctx, `
+		SELECT u.id, u.username, u.role
+		FROM sessions s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.session_token = ? AND s.csrf_token = ?`,
+		sessionToken, csrfToken,
+	).Scan(scanID(&userAuth.UserID), &userAuth.Username, &userAuth.Role)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	userAuth.Sanctions, err = r.getSanctions(ctx, userAuth.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &userAuth, nil
+}
+
+func (r *UserRepo) TokenAuthCheck(ctx context.Context, hashedToken string) (*repository.UserAuth, error) {
+	row := r.DB.queryRow(ctx, `SELECT `+tokenColumns+` FROM api_tokens WHERE hashed_token = ?`, hashedToken)
+	token, err := scanToken(row)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	user, err := r.GetUserByID(ctx, token.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	userAuth := &repository.UserAuth{
+		Username:  user.Username,
+		UserID:    user.ID,
+		Role:      user.Role,
+		Sanctions: user.Sanctions,
+		Token:     token,
+	}
+
+	return userAuth, nil
+}
@@ -0,0 +1,174 @@
+package sqlstore
+
+import (
+	"context"
+	"strings"
+
+	"github.com/SomeSuperCoder/global-chat/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type WebhookRepo struct {
+	DB *DB
+}
+
+const incomingWebhookColumns = `id, bot_id, name, hashed_token, secret, rate_limit, created_by, created_at`
+
+func scanIncomingWebhook(row row) (*models.IncomingWebhook, error) {
+	var hook models.IncomingWebhook
+
+	err := row.Scan(scanID(&hook.ID), scanID(&hook.BotID), &hook.Name, &hook.HashedToken, &hook.Secret,
+		&hook.RateLimit, scanID(&hook.CreatedBy), scanTime(&hook.CratedAt))
+	if err != nil {
+		return nil, err
+	}
+
+	return &hook, nil
+}
+
+func (r *WebhookRepo) CreateIncomingWebhook(ctx context.Context, hook models.IncomingWebhook) error {
+	id := hook.ID
+	if id.IsZero() {
+		id = bson.NewObjectID()
+	}
+
+	_, err := r.DB.exec(ctx, `
+		INSERT INTO incoming_webhooks (`+incomingWebhookColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		id.Hex(), hook.BotID.Hex(), hook.Name, hook.HashedToken, hook.Secret,
+		hook.RateLimit, hook.CreatedBy.Hex(), r.DB.timeValue(hook.CratedAt),
+	)
+	return err
+}
+
+func (r *WebhookRepo) GetIncomingWebhook(ctx context.Context, hashedToken string) (*models.IncomingWebhook, error) {
+	row := r.DB.queryRow(ctx, `SELECT `+incomingWebhookColumns+` FROM incoming_webhooks WHERE hashed_token = ?`, hashedToken)
+	hook, err := scanIncomingWebhook(row)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	return hook, nil
+}
+
+func (r *WebhookRepo) FindIncomingWebhooks(ctx context.Context) ([]models.IncomingWebhook, error) {
+	hooks := []models.IncomingWebhook{}
+
+	rows, err := r.DB.query(ctx, `
+		SELECT `+incomingWebhookColumns+`
+		FROM incoming_webhooks
+		ORDER BY created_at DESC`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		hook, err := scanIncomingWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, *hook)
+	}
+
+	return hooks, rows.Err()
+}
+
+func (r *WebhookRepo) DeleteIncomingWebhook(ctx context.Context, hookID bson.ObjectID) (bool, error) {
+	res, err := r.DB.exec(ctx, `DELETE FROM incoming_webhooks WHERE id = ?`, hookID.Hex())
+	if err != nil {
+		return false, err
+	}
+
+	deleted, err := res.RowsAffected()
+	return deleted > 0, err
+}
+
+const outgoingWebhookColumns = `id, name, url, secret, events, created_by, created_at`
+
+func scanOutgoingWebhook(row row) (*models.OutgoingWebhook, error) {
+	var hook models.OutgoingWebhook
+	var events string
+
+	err := row.Scan(scanID(&hook.ID), &hook.Name, &hook.URL, &hook.Secret, &events,
+		scanID(&hook.CreatedBy), scanTime(&hook.CratedAt))
+	if err != nil {
+		return nil, err
+	}
+
+	hook.Events = []models.WebhookEvent{}
+	for _, event := range strings.Split(events, ",") {
+		if event != "" {
+			hook.Events = append(hook.Events, models.WebhookEvent(event))
+		}
+	}
+
+	return &hook, nil
+}
+
+// Events are stored comma separated
+func (r *WebhookRepo) CreateOutgoingWebhook(ctx context.Context, hook models.OutgoingWebhook) error {
+	id := hook.ID
+	if id.IsZero() {
+		id = bson.NewObjectID()
+	}
+
+	events := make([]string, len(hook.Events))
+	for i, event := range hook.Events {
+		events[i] = string(event)
+	}
+
+	_, err := r.DB.exec(ctx, `
+		INSERT INTO outgoing_webhooks (`+outgoingWebhookColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		id.Hex(), hook.Name, hook.URL, hook.Secret, strings.Join(events, ","),
+		hook.CreatedBy.Hex(), r.DB.timeValue(hook.CratedAt),
+	)
+	return err
+}
+
+func (r *WebhookRepo) GetOutgoingWebhook(ctx context.Context, hookID bson.ObjectID) (*models.OutgoingWebhook, error) {
+	row := r.DB.queryRow(ctx, `SELECT `+outgoingWebhookColumns+` FROM outgoing_webhooks WHERE id = ?`, hookID.Hex())
+	hook, err := scanOutgoingWebhook(row)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	return hook, nil
+}
+
+func (r *WebhookRepo) FindOutgoingWebhooks(ctx context.Context) ([]models.OutgoingWebhook, error) {
+	hooks := []models.OutgoingWebhook{}
+
+	rows, err := r.DB.query(ctx, `
+		SELECT `+outgoingWebhookColumns+`
+		FROM outgoing_webhooks
+		ORDER BY created_at DESC`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		hook, err := scanOutgoingWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, *hook)
+	}
+
+	return hooks, rows.Err()
+}
+
+// Deliveries are removed by the foreign key
+func (r *WebhookRepo) DeleteOutgoingWebhook(ctx context.Context, hookID bson.ObjectID) (bool, error) {
+	res, err := r.DB.exec(ctx, `DELETE FROM outgoing_webhooks WHERE id = ?`, hookID.Hex())
+	if err != nil {
+		return false, err
+	}
+
+	deleted, err := res.RowsAffected()
+	return deleted > 0, err
+}
@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/SomeSuperCoder/global-chat/logging"
+	"github.com/SomeSuperCoder/global-chat/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+var log = logging.Package("repository")
+
+// Every backend reports missing documents with this error
+var ErrNotFound = errors.New("not found")
+
+// Returned when a unique constraint, like the username, is violated
+var ErrAlreadyExists = errors.New("already exists")
+
+type MessageStore interface {
+	FindPaged(ctx context.Context, page, limit int64) ([]models.Message, int64, error)
+	GetMessageByID(ctx context.Context, messageID bson.ObjectID) (*models.Message, error)
+	CreateMessage(ctx context.Context, message models.Message) error
+	UpdateMessage(ctx context.Context, messageID bson.ObjectID, update models.MessageUpdate) error
+	DeleteMessage(ctx context.Context, messageID bson.ObjectID) error
+	SetHidden(ctx context.Context, messageID bson.ObjectID, hidden bool) error
+}
+
+type UserStore interface {
+	CreateUser(ctx context.Context, user *models.User) error
+	GetUserByID(ctx context.Context, userID bson.ObjectID) (*models.User, error)
+	GetUserByUsername(ctx context.Context, username string) (*models.User, error)
+	DoesExist(ctx context.Context, username string) (bool, error)
+	SetRole(ctx context.Context, userID bson.ObjectID, role models.Role) error
+	SetPasswordHash(ctx context.Context, userID bson.ObjectID, hashedPassword string) error
+	AddSanction(ctx context.Context, userID bson.ObjectID, sanction models.Sanction) error
+	LiftSanctions(ctx context.Context, userID bson.ObjectID, kinds []models.SanctionKind, liftedAt time.Time) error
+}
+
+type SessionStore interface {
+	AddLoginSession(ctx context.Context, username string, session models.UserSession) error
+	FinalizeSession(ctx context.Context, username string, sessionToken string) error
+	AuthCheck(ctx context.Context, sessionToken string, csrfToken string) (*UserAuth, error)
+	// Looks up the owner of an API token by the hash of the token
+	TokenAuthCheck(ctx context.Context, hashedToken string) (*UserAuth, error)
+}
+
+type TokenStore interface {
+	CreateToken(ctx context.Context, token models.APIToken) error
+	FindTokens(ctx context.Context, userID bson.ObjectID) ([]models.APIToken, error)
+	// Returns false if the user has no such token
+	DeleteToken(ctx context.Context, userID bson.ObjectID, tokenID bson.ObjectID) (bool, error)
+}
+
+type WebhookStore interface {
+	CreateIncomingWebhook(ctx context.Context, hook models.IncomingWebhook) error
+	// Looks up a webhook by the hash of the token in its URL
+	GetIncomingWebhook(ctx context.Context, hashedToken string) (*models.IncomingWebhook, error)
+	FindIncomingWebhooks(ctx context.Context) ([]models.IncomingWebhook, error)
+	// Returns false if there is no such webhook
+	DeleteIncomingWebhook(ctx context.Context, hookID bson.ObjectID) (bool, error)
+
+	CreateOutgoingWebhook(ctx context.Context, hook models.OutgoingWebhook) error
+	GetOutgoingWebhook(ctx context.Context, hookID bson.ObjectID) (*models.OutgoingWebhook, error)
+	FindOutgoingWebhooks(ctx context.Context) ([]models.OutgoingWebhook, error)
+	// Queued deliveries of the webhook are deleted with it
+	DeleteOutgoingWebhook(ctx context.Context, hookID bson.ObjectID) (bool, error)
+}
+
+// Durable queue of outgoing webhook deliveries
+type DeliveryStore interface {
+	EnqueueDeliveries(ctx context.Context, deliveries []models.WebhookDelivery) error
+	// Takes the pending delivery that has been due the longest and hides it
+	// from other workers until leaseUntil. Returns ErrNotFound if none is due.
+	ClaimDelivery(ctx context.Context, now, leaseUntil time.Time) (*models.WebhookDelivery, error)
+	// Stores the outcome of an attempt
+	UpdateDelivery(ctx context.Context, delivery models.WebhookDelivery) error
+	// An empty status matches all deliveries
+	FindDeliveries(ctx context.Context, hookID bson.ObjectID, status models.DeliveryStatus, page, limit int64) ([]models.WebhookDelivery, int64, error)
+	// Moves a dead delivery back to the queue with a fresh set of attempts,
+	// returns false if there is no such dead delivery
+	RetryDelivery(ctx context.Context, deliveryID bson.ObjectID, now time.Time) (bool, error)
+}
+
+type SettingsStore interface {
+	GetSetting(ctx context.Context, key string) (*models.Setting, error)
+	// Creates the setting or replaces its value
+	PutSetting(ctx context.Context, setting models.Setting) error
+}
+
+type ReportStore interface {
+	AddReport(ctx context.Context, report models.Report) (bool, error)
+	CountOpen(ctx context.Context, messageID bson.ObjectID) (int64, error)
+	FindOpenGrouped(ctx context.Context, page, limit int64) ([]models.ReportGroup, int64, error)
+	ResolveAll(ctx context.Context, messageID bson.ObjectID, resolution models.ReportResolution, resolvedBy bson.ObjectID, resolvedAt time.Time) (int64, error)
+}
+
+type AuditStore interface {
+	Record(ctx context.Context, event models.AuditEvent) error
+	FindPaged(ctx context.Context, filter AuditFilter, page, limit int64) ([]models.AuditEvent, int64, error)
+}
+
+// All stores of one backend
+type Stores struct {
+	Users      UserStore
+	Sessions   SessionStore
+	Tokens     TokenStore
+	Webhooks   WebhookStore
+	Deliveries DeliveryStore
+	Messages   MessageStore
+	Settings   SettingsStore
+	Reports    ReportStore
+	Audit      AuditStore
+}
+
+func NewMongoStores(db *mongo.Database) *Stores {
+	users := &UserRepo{Database: db}
+
+	return &Stores{
+		Users:      users,
+		Sessions:   users,
+		Tokens:     &TokenRepo{Database: db},
+		Webhooks:   &WebhookRepo{Database: db},
+		Deliveries: &DeliveryRepo{Database: db},
+		Messages:   &MessageRepo{Database: db},
+		Settings:   &SettingsRepo{Database: db},
+		Reports:    &ReportRepo{Database: db},
+		Audit:      &AuditRepo{Database: db},
+	}
+}
+
+func notFound(err error) error {
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,867 @@
+// Package storetest is a conformance suite for the repository interfaces.
+// Every backend is expected to pass it, a backend wires it up with
+//
+//	func TestConformance(t *testing.T) {
+//		storetest.Run(t, func(t *testing.T) *repository.Stores {
+//			return newEmptyStores(t)
+//		})
+//	}
+package storetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/SomeSuperCoder/global-chat/markdown"
+	"github.com/SomeSuperCoder/global-chat/models"
+	"github.com/SomeSuperCoder/global-chat/repository"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Factory must return stores backed by an empty database on every call
+type Factory func(t *testing.T) *repository.Stores
+
+func Run(t *testing.T, newStores Factory) {
+	t.Run("Users", func(t *testing.T) { testUsers(t, newStores(t)) })
+	t.Run("Sanctions", func(t *testing.T) { testSanctions(t, newStores(t)) })
+	t.Run("Sessions", func(t *testing.T) { testSessions(t, newStores(t)) })
+	t.Run("Tokens", func(t *testing.T) { testTokens(t, newStores(t)) })
+	t.Run("Webhooks", func(t *testing.T) { testWebhooks(t, newStores(t)) })
+	t.Run("Deliveries", func(t *testing.T) { testDeliveries(t, newStores(t)) })
+	t.Run("Messages", func(t *testing.T) { testMessages(t, newStores(t)) })
+	t.Run("Settings", func(t *testing.T) { testSettings(t, newStores(t)) })
+	t.Run("Reports", func(t *testing.T) { testReports(t, newStores(t)) })
+	t.Run("Audit", func(t *testing.T) { testAudit(t, newStores(t)) })
+	t.Run("Concurrency", func(t *testing.T) { testConcurrency(t, newStores(t)) })
+}
+
+// Backends may store timestamps with millisecond precision only
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
+
+func check(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func createUser(t *testing.T, stores *repository.Stores, username string) *models.User {
+	t.Helper()
+	ctx := context.Background()
+
+	check(t, stores.Users.CreateUser(ctx, &models.User{
+		Username:       username,
+		HashedPassword: "hash-" + username,
+		Role:           models.RoleUser,
+		Sessions:       []models.UserSession{},
+		Sanctions:      []models.Sanction{},
+		CratedAt:       now(),
+	}))
+
+	user, err := stores.Users.GetUserByUsername(ctx, username)
+	check(t, err)
+	return user
+}
+
+func createMessage(t *testing.T, stores *repository.Stores, author bson.ObjectID, text string, at time.Time) bson.ObjectID {
+	t.Helper()
+
+	id := bson.NewObjectID()
+	check(t, stores.Messages.CreateMessage(context.Background(), models.Message{
+		ID:       id,
+		Author:   author,
+		Text:     text,
+		Rendered: markdown.Render(text),
+		CratedAt: at,
+	}))
+	return id
+}
+
+func testUsers(t *testing.T, stores *repository.Stores) {
+	ctx := context.Background()
+
+	exists, err := stores.Users.DoesExist(ctx, "alice_wonder")
+	check(t, err)
+	if exists {
+		t.Fatal("user exists in an empty store")
+	}
+
+	alice := createUser(t, stores, "alice_wonder")
+	if alice.ID.IsZero() {
+		t.Fatal("created user has no ID")
+	}
+	if alice.HashedPassword != "hash-alice_wonder" || alice.Role != models.RoleUser {
+		t.Fatalf("user not stored as created: %+v", alice)
+	}
+	exists, err = stores.Users.DoesExist(ctx, "Alice_Wonder")
+	check(t, err)
+	if !exists {
+		t.Fatal("created user does not exist")
+	}
+
+	// Names that only differ in case or by lookalike letters are the same user
+	for _, name := range []string{"alice_wonder", "ALICE_WONDER", "аlice_wonder"} {
+		err = stores.Users.CreateUser(ctx, &models.User{Username: name, HashedPassword: "hash", CratedAt: now()})
+		if !errors.Is(err, repository.ErrAlreadyExists) {
+			t.Fatalf("expected ErrAlreadyExists for %q, got %v", name, err)
+		}
+	}
+	byName, err := stores.Users.GetUserByUsername(ctx, "ALICE_WONDER")
+	check(t, err)
+	if byName.ID != alice.ID || byName.Username != "alice_wonder" {
+		t.Fatalf("case-insensitive lookup returned %+v", byName)
+	}
+
+	byID, err := stores.Users.GetUserByID(ctx, alice.ID)
+	check(t, err)
+	if byID.Username != "alice_wonder" {
+		t.Fatalf("GetUserByID returned %q", byID.Username)
+	}
+
+	_, err = stores.Users.GetUserByID(ctx, bson.NewObjectID())
+	if !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for unknown ID, got %v", err)
+	}
+	_, err = stores.Users.GetUserByUsername(ctx, "nobody_here")
+	if !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for unknown username, got %v", err)
+	}
+
+	check(t, stores.Users.SetRole(ctx, alice.ID, models.RoleModerator))
+	byID, err = stores.Users.GetUserByID(ctx, alice.ID)
+	check(t, err)
+	if byID.Role != models.RoleModerator {
+		t.Fatalf("role not updated, got %q", byID.Role)
+	}
+
+	check(t, stores.Users.SetPasswordHash(ctx, alice.ID, "rehashed"))
+	byID, err = stores.Users.GetUserByID(ctx, alice.ID)
+	check(t, err)
+	if byID.HashedPassword != "rehashed" {
+		t.Fatalf("password hash not updated, got %q", byID.HashedPassword)
+	}
+
+	// Writes to unknown users are no-ops
+	check(t, stores.Users.SetRole(ctx, bson.NewObjectID(), models.RoleAdmin))
+	check(t, stores.Users.SetPasswordHash(ctx, bson.NewObjectID(), "hash"))
+}
+
+func testSanctions(t *testing.T, stores *repository.Stores) {
+	ctx := context.Background()
+	bob := createUser(t, stores, "bob_builder")
+	moderator := bson.NewObjectID()
+
+	expires := now().Add(time.Hour)
+	check(t, stores.Users.AddSanction(ctx, bob.ID, models.Sanction{
+		Kind:      models.SanctionTimeout,
+		Reason:    "spam",
+		IssuedBy:  moderator,
+		CratedAt:  now(),
+		ExpiresAt: &expires,
+	}))
+
+	user, err := stores.Users.GetUserByID(ctx, bob.ID)
+	check(t, err)
+	mute := models.ActiveMute(user.Sanctions, time.Now())
+	if mute == nil || mute.Reason != "spam" || mute.IssuedBy != moderator || !mute.ExpiresAt.Equal(expires) {
+		t.Fatalf("timeout not stored: %+v", user.Sanctions)
+	}
+
+	// A ban drops all sessions
+	session := models.UserSession{SessionToken: "st-bob", CSRFToken: "csrf-bob", CratedAt: now()}
+	check(t, stores.Sessions.AddLoginSession(ctx, bob.Username, session))
+	check(t, stores.Users.AddSanction(ctx, bob.ID, models.Sanction{
+		Kind:     models.SanctionBan,
+		Reason:   "abuse",
+		IssuedBy: moderator,
+		CratedAt: now(),
+	}))
+	_, err = stores.Sessions.AuthCheck(ctx, session.SessionToken, session.CSRFToken)
+	if !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("session survived a ban, got %v", err)
+	}
+
+	user, err = stores.Users.GetUserByID(ctx, bob.ID)
+	check(t, err)
+	if models.ActiveBan(user.Sanctions, time.Now()) == nil {
+		t.Fatal("ban is not active")
+	}
+
+	// Lifting only affects the given kinds
+	check(t, stores.Users.LiftSanctions(ctx, bob.ID, []models.SanctionKind{models.SanctionMute, models.SanctionTimeout}, now()))
+	user, err = stores.Users.GetUserByID(ctx, bob.ID)
+	check(t, err)
+	if models.ActiveMute(user.Sanctions, time.Now()) != nil {
+		t.Fatal("timeout still active after lifting")
+	}
+	if models.ActiveBan(user.Sanctions, time.Now()) == nil {
+		t.Fatal("ban lifted together with the timeout")
+	}
+
+	check(t, stores.Users.LiftSanctions(ctx, bob.ID, []models.SanctionKind{models.SanctionBan}, now()))
+	user, err = stores.Users.GetUserByID(ctx, bob.ID)
+	check(t, err)
+	if models.ActiveBan(user.Sanctions, time.Now()) != nil {
+		t.Fatal("ban still active after lifting")
+	}
+	if len(user.Sanctions) != 2 {
+		t.Fatalf("sanction history lost, got %d entries", len(user.Sanctions))
+	}
+
+	// Lifting on a user without sanctions must not fail
+	carol := createUser(t, stores, "carol_singer")
+	check(t, stores.Users.LiftSanctions(ctx, carol.ID, []models.SanctionKind{models.SanctionBan}, now()))
+}
+
+func testSessions(t *testing.T, stores *repository.Stores) {
+	ctx := context.Background()
+	dave := createUser(t, stores, "dave_grohl")
+	createUser(t, stores, "erin_brock")
+
+	first := models.UserSession{SessionToken: "st-1", CSRFToken: "csrf-1", CratedAt: now()}
+	second := models.UserSession{SessionToken: "st-2", CSRFToken: "csrf-2", CratedAt: now()}
+	check(t, stores.Sessions.AddLoginSession(ctx, dave.Username, first))
+	check(t, stores.Sessions.AddLoginSession(ctx, dave.Username, second))
+
+	userAuth, err := stores.Sessions.AuthCheck(ctx, "st-1", "csrf-1")
+	check(t, err)
+	if userAuth.UserID != dave.ID || userAuth.Username != dave.Username || userAuth.Role != models.RoleUser {
+		t.Fatalf("wrong user auth: %+v", userAuth)
+	}
+
+	// Tokens of different sessions must not be combined
+	_, err = stores.Sessions.AuthCheck(ctx, "st-1", "csrf-2")
+	if !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("mixed tokens accepted, got %v", err)
+	}
+
+	// Sessions are never part of fetched users
+	user, err := stores.Users.GetUserByID(ctx, dave.ID)
+	check(t, err)
+	if len(user.Sessions) != 0 {
+		t.Fatal("fetched user contains sessions")
+	}
+
+	check(t, stores.Sessions.FinalizeSession(ctx, dave.Username, "st-1"))
+	_, err = stores.Sessions.AuthCheck(ctx, "st-1", "csrf-1")
+	if !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("finalized session still valid, got %v", err)
+	}
+	_, err = stores.Sessions.AuthCheck(ctx, "st-2", "csrf-2")
+	check(t, err)
+
+	// Finalizing someone else's session does nothing
+	check(t, stores.Sessions.FinalizeSession(ctx, "erin_brock", "st-2"))
+	_, err = stores.Sessions.AuthCheck(ctx, "st-2", "csrf-2")
+	check(t, err)
+}
+
+func testTokens(t *testing.T, stores *repository.Stores) {
+	ctx := context.Background()
+	frank := createUser(t, stores, "frank_ocean")
+	grace := createUser(t, stores, "grace_hopper")
+
+	tokens, err := stores.Tokens.FindTokens(ctx, frank.ID)
+	check(t, err)
+	if tokens == nil || len(tokens) != 0 {
+		t.Fatalf("expected an empty list, got %+v", tokens)
+	}
+
+	expires := now().Add(time.Hour)
+	first := models.APIToken{
+		ID:          bson.NewObjectID(),
+		UserID:      frank.ID,
+		Name:        "ci",
+		Prefix:      "gct_first",
+		HashedToken: "hash-1",
+		Scopes:      []models.TokenScope{models.ScopeReadMessages, models.ScopePostMessages},
+		CratedAt:    now().Add(-time.Minute),
+		ExpiresAt:   &expires,
+	}
+	second := models.APIToken{
+		ID:          bson.NewObjectID(),
+		UserID:      frank.ID,
+		Name:        "alerts",
+		Prefix:      "gct_second",
+		HashedToken: "hash-2",
+		Scopes:      []models.TokenScope{models.ScopeAdmin},
+		CratedAt:    now(),
+	}
+	check(t, stores.Tokens.CreateToken(ctx, first))
+	check(t, stores.Tokens.CreateToken(ctx, second))
+
+	tokens, err = stores.Tokens.FindTokens(ctx, frank.ID)
+	check(t, err)
+	if len(tokens) != 2 || tokens[0].ID != second.ID || tokens[1].ID != first.ID {
+		t.Fatalf("expected newest token first, got %+v", tokens)
+	}
+	got := tokens[1]
+	if got.Name != "ci" || got.Prefix != "gct_first" || got.HashedToken != "hash-1" || len(got.Scopes) != 2 ||
+		got.ExpiresAt == nil || !got.ExpiresAt.Equal(expires) || !got.CratedAt.Equal(first.CratedAt) {
+		t.Fatalf("token not stored as created: %+v", got)
+	}
+	if tokens[0].ExpiresAt != nil {
+		t.Fatal("token without expiry got one")
+	}
+
+	userAuth, err := stores.Sessions.TokenAuthCheck(ctx, "hash-1")
+	check(t, err)
+	if userAuth.UserID != frank.ID || userAuth.Username != frank.Username || userAuth.Token == nil || userAuth.Token.ID != first.ID {
+		t.Fatalf("wrong token auth: %+v", userAuth)
+	}
+	_, err = stores.Sessions.TokenAuthCheck(ctx, "unknown")
+	if !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for an unknown token, got %v", err)
+	}
+
+	// Only the owner can delete a token
+	deleted, err := stores.Tokens.DeleteToken(ctx, grace.ID, first.ID)
+	check(t, err)
+	if deleted {
+		t.Fatal("deleted the token of another user")
+	}
+	deleted, err = stores.Tokens.DeleteToken(ctx, frank.ID, first.ID)
+	check(t, err)
+	if !deleted {
+		t.Fatal("token not deleted")
+	}
+	_, err = stores.Sessions.TokenAuthCheck(ctx, "hash-1")
+	if !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("deleted token still valid, got %v", err)
+	}
+}
+
+func testWebhooks(t *testing.T, stores *repository.Stores) {
+	ctx := context.Background()
+	admin := createUser(t, stores, "henry_admin")
+
+	bot := &models.User{Username: "ci_results", Role: models.RoleUser, IsBot: true, CratedAt: now()}
+	check(t, stores.Users.CreateUser(ctx, bot))
+	stored, err := stores.Users.GetUserByID(ctx, bot.ID)
+	check(t, err)
+	if !stored.IsBot || stored.HashedPassword != "" {
+		t.Fatalf("bot not stored as created: %+v", stored)
+	}
+	if admin.IsBot {
+		t.Fatal("regular user stored as a bot")
+	}
+
+	hooks, err := stores.Webhooks.FindIncomingWebhooks(ctx)
+	check(t, err)
+	if hooks == nil || len(hooks) != 0 {
+		t.Fatalf("expected an empty list, got %+v", hooks)
+	}
+
+	first := models.IncomingWebhook{
+		ID:          bson.NewObjectID(),
+		BotID:       bot.ID,
+		Name:        "ci",
+		HashedToken: "hook-1",
+		Secret:      "secret-1",
+		RateLimit:   30,
+		CreatedBy:   admin.ID,
+		CratedAt:    now().Add(-time.Minute),
+	}
+	second := first
+	second.ID = bson.NewObjectID()
+	second.Name = "alerts"
+	second.HashedToken = "hook-2"
+	second.CratedAt = now()
+	check(t, stores.Webhooks.CreateIncomingWebhook(ctx, first))
+	check(t, stores.Webhooks.CreateIncomingWebhook(ctx, second))
+
+	hooks, err = stores.Webhooks.FindIncomingWebhooks(ctx)
+	check(t, err)
+	if len(hooks) != 2 || hooks[0].ID != second.ID || hooks[1].ID != first.ID {
+		t.Fatalf("expected newest webhook first, got %+v", hooks)
+	}
+
+	hook, err := stores.Webhooks.GetIncomingWebhook(ctx, "hook-1")
+	check(t, err)
+	if hook.ID != first.ID || hook.BotID != bot.ID || hook.Name != "ci" || hook.Secret != "secret-1" ||
+		hook.RateLimit != 30 || hook.CreatedBy != admin.ID || !hook.CratedAt.Equal(first.CratedAt) {
+		t.Fatalf("webhook not stored as created: %+v", hook)
+	}
+	_, err = stores.Webhooks.GetIncomingWebhook(ctx, "unknown")
+	if !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for an unknown webhook, got %v", err)
+	}
+
+	deleted, err := stores.Webhooks.DeleteIncomingWebhook(ctx, first.ID)
+	check(t, err)
+	if !deleted {
+		t.Fatal("webhook not deleted")
+	}
+	deleted, err = stores.Webhooks.DeleteIncomingWebhook(ctx, first.ID)
+	check(t, err)
+	if deleted {
+		t.Fatal("deleted a webhook twice")
+	}
+	_, err = stores.Webhooks.GetIncomingWebhook(ctx, "hook-1")
+	if !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("deleted webhook still found, got %v", err)
+	}
+}
+
+func testDeliveries(t *testing.T, stores *repository.Stores) {
+	ctx := context.Background()
+	admin := createUser(t, stores, "irene_admin")
+
+	hook := models.OutgoingWebhook{
+		ID:        bson.NewObjectID(),
+		Name:      "search",
+		URL:       "http://localhost:9999/events",
+		Secret:    "secret",
+		Events:    []models.WebhookEvent{models.EventMessageCreated, models.EventMessageDeleted},
+		CreatedBy: admin.ID,
+		CratedAt:  now(),
+	}
+	check(t, stores.Webhooks.CreateOutgoingWebhook(ctx, hook))
+
+	stored, err := stores.Webhooks.GetOutgoingWebhook(ctx, hook.ID)
+	check(t, err)
+	if stored.Name != "search" || stored.URL != hook.URL || stored.Secret != "secret" || len(stored.Events) != 2 ||
+		!stored.Subscribed(models.EventMessageDeleted) || stored.Subscribed(models.EventMessageUpdated) {
+		t.Fatalf("webhook not stored as created: %+v", stored)
+	}
+	hooks, err := stores.Webhooks.FindOutgoingWebhooks(ctx)
+	check(t, err)
+	if len(hooks) != 1 || hooks[0].ID != hook.ID {
+		t.Fatalf("expected one webhook, got %+v", hooks)
+	}
+
+	// Nothing is due yet
+	_, err = stores.Deliveries.ClaimDelivery(ctx, now(), now().Add(time.Minute))
+	if !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound on an empty queue, got %v", err)
+	}
+
+	older := models.WebhookDelivery{
+		ID:            bson.NewObjectID(),
+		WebhookID:     hook.ID,
+		Event:         models.EventMessageCreated,
+		Payload:       `{"event":"message.created"}`,
+		Status:        models.DeliveryPending,
+		NextAttemptAt: now().Add(-2 * time.Minute),
+		CratedAt:      now().Add(-2 * time.Minute),
+	}
+	newer := older
+	newer.ID = bson.NewObjectID()
+	newer.Event = models.EventMessageDeleted
+	newer.NextAttemptAt = now().Add(-time.Minute)
+	newer.CratedAt = now().Add(-time.Minute)
+	later := older
+	later.ID = bson.NewObjectID()
+	later.NextAttemptAt = now().Add(time.Hour)
+	later.CratedAt = now()
+	check(t, stores.Deliveries.EnqueueDeliveries(ctx, []models.WebhookDelivery{newer, older, later}))
+
+	// The delivery that has been due the longest comes first, claimed ones are hidden
+	claimed, err := stores.Deliveries.ClaimDelivery(ctx, now(), now().Add(time.Minute))
+	check(t, err)
+	if claimed.ID != older.ID || claimed.Payload != older.Payload || claimed.Status != models.DeliveryPending {
+		t.Fatalf("claimed the wrong delivery: %+v", claimed)
+	}
+	second, err := stores.Deliveries.ClaimDelivery(ctx, now(), now().Add(5*time.Minute))
+	check(t, err)
+	if second.ID != newer.ID {
+		t.Fatalf("claimed the wrong delivery: %+v", second)
+	}
+	_, err = stores.Deliveries.ClaimDelivery(ctx, now(), now().Add(time.Minute))
+	if !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("claimed a delivery twice or one that is not due, got %v", err)
+	}
+
+	// An expired lease makes the delivery available again
+	again, err := stores.Deliveries.ClaimDelivery(ctx, now().Add(2*time.Minute), now().Add(3*time.Minute))
+	check(t, err)
+	if again.ID != older.ID {
+		t.Fatalf("expected the delivery with the expired lease, got %+v", again)
+	}
+
+	deliveredAt := now()
+	claimed.Status = models.DeliveryDelivered
+	claimed.Attempts = 2
+	claimed.LastStatusCode = 204
+	claimed.DeliveredAt = &deliveredAt
+	check(t, stores.Deliveries.UpdateDelivery(ctx, *claimed))
+
+	second.Status = models.DeliveryDead
+	second.Attempts = 8
+	second.LastStatusCode = 500
+	second.LastError = "receiver responded with 500 Internal Server Error"
+	check(t, stores.Deliveries.UpdateDelivery(ctx, *second))
+
+	deliveries, count, err := stores.Deliveries.FindDeliveries(ctx, hook.ID, "", 1, 10)
+	check(t, err)
+	if count != 3 || len(deliveries) != 3 || deliveries[0].ID != later.ID || deliveries[2].ID != older.ID {
+		t.Fatalf("expected newest delivery first, got %d %+v", count, deliveries)
+	}
+	got := deliveries[2]
+	if got.Status != models.DeliveryDelivered || got.Attempts != 2 || got.LastStatusCode != 204 ||
+		got.DeliveredAt == nil || !got.DeliveredAt.Equal(deliveredAt) {
+		t.Fatalf("delivery not updated: %+v", got)
+	}
+
+	dead, count, err := stores.Deliveries.FindDeliveries(ctx, hook.ID, models.DeliveryDead, 1, 10)
+	check(t, err)
+	if count != 1 || len(dead) != 1 || dead[0].ID != newer.ID || dead[0].LastError != second.LastError {
+		t.Fatalf("expected the dead delivery, got %d %+v", count, dead)
+	}
+
+	// Only dead deliveries can be retried
+	retried, err := stores.Deliveries.RetryDelivery(ctx, older.ID, now())
+	check(t, err)
+	if retried {
+		t.Fatal("retried a delivered delivery")
+	}
+	retried, err = stores.Deliveries.RetryDelivery(ctx, newer.ID, now())
+	check(t, err)
+	if !retried {
+		t.Fatal("dead delivery not retried")
+	}
+	claimed, err = stores.Deliveries.ClaimDelivery(ctx, now(), now().Add(time.Minute))
+	check(t, err)
+	if claimed.ID != newer.ID || claimed.Attempts != 0 || claimed.Status != models.DeliveryPending {
+		t.Fatalf("retried delivery not queued again: %+v", claimed)
+	}
+
+	// Deliveries go with their webhook
+	deleted, err := stores.Webhooks.DeleteOutgoingWebhook(ctx, hook.ID)
+	check(t, err)
+	if !deleted {
+		t.Fatal("webhook not deleted")
+	}
+	_, count, err = stores.Deliveries.FindDeliveries(ctx, hook.ID, "", 1, 10)
+	check(t, err)
+	if count != 0 {
+		t.Fatalf("deliveries of a deleted webhook remain: %d", count)
+	}
+	_, err = stores.Webhooks.GetOutgoingWebhook(ctx, hook.ID)
+	if !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("deleted webhook still found, got %v", err)
+	}
+}
+
+func testMessages(t *testing.T, stores *repository.Stores) {
+	ctx := context.Background()
+	author := bson.NewObjectID()
+	base := now()
+
+	var ids []bson.ObjectID
+	for i := range 5 {
+		ids = append(ids, createMessage(t, stores, author, fmt.Sprintf("message %d", i), base.Add(time.Duration(i)*time.Second)))
+	}
+
+	// Newest first
+	messages, total, err := stores.Messages.FindPaged(ctx, 1, 2)
+	check(t, err)
+	if total != 5 || len(messages) != 2 {
+		t.Fatalf("expected 2 of 5 messages, got %d of %d", len(messages), total)
+	}
+	if messages[0].ID != ids[4] || messages[1].ID != ids[3] {
+		t.Fatalf("wrong order: %s, %s", messages[0].Text, messages[1].Text)
+	}
+
+	messages, _, err = stores.Messages.FindPaged(ctx, 3, 2)
+	check(t, err)
+	if len(messages) != 1 || messages[0].ID != ids[0] {
+		t.Fatalf("wrong last page: %+v", messages)
+	}
+
+	messages, _, err = stores.Messages.FindPaged(ctx, 10, 2)
+	check(t, err)
+	if messages == nil || len(messages) != 0 {
+		t.Fatalf("expected an empty non-nil page, got %#v", messages)
+	}
+
+	// Get
+	message, err := stores.Messages.GetMessageByID(ctx, ids[2])
+	check(t, err)
+	if message.Text != "message 2" || message.Author != author || !message.CratedAt.Equal(base.Add(2*time.Second)) {
+		t.Fatalf("message not stored as created: %+v", message)
+	}
+	if message.Rendered.HTML != "message 2" {
+		t.Fatalf("rendered text not stored: %+v", message.Rendered)
+	}
+	_, err = stores.Messages.GetMessageByID(ctx, bson.NewObjectID())
+	if !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	// Update
+	text := "**edited**"
+	rendered := markdown.Render(text)
+	check(t, stores.Messages.UpdateMessage(ctx, ids[2], models.MessageUpdate{Text: &text, Rendered: &rendered}))
+	message, err = stores.Messages.GetMessageByID(ctx, ids[2])
+	check(t, err)
+	if message.Text != text || message.Rendered.HTML != "<strong>edited</strong>" {
+		t.Fatalf("message not updated: %+v", message)
+	}
+	if len(message.Rendered.Tokens) != 1 || message.Rendered.Tokens[0].Type != markdown.TokenBold {
+		t.Fatalf("tokens not updated: %+v", message.Rendered.Tokens)
+	}
+
+	// Hidden messages are only reachable by ID
+	check(t, stores.Messages.SetHidden(ctx, ids[4], true))
+	messages, total, err = stores.Messages.FindPaged(ctx, 1, 10)
+	check(t, err)
+	if total != 4 || len(messages) != 4 || messages[0].ID != ids[3] {
+		t.Fatalf("hidden message listed, total %d", total)
+	}
+	message, err = stores.Messages.GetMessageByID(ctx, ids[4])
+	check(t, err)
+	if !message.Hidden {
+		t.Fatal("hidden flag not stored")
+	}
+	check(t, stores.Messages.SetHidden(ctx, ids[4], false))
+
+	// Delete
+	check(t, stores.Messages.DeleteMessage(ctx, ids[0]))
+	_, err = stores.Messages.GetMessageByID(ctx, ids[0])
+	if !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("deleted message still found, got %v", err)
+	}
+	check(t, stores.Messages.DeleteMessage(ctx, ids[0]))
+
+	_, total, err = stores.Messages.FindPaged(ctx, 1, 10)
+	check(t, err)
+	if total != 4 {
+		t.Fatalf("expected 4 messages after delete, got %d", total)
+	}
+}
+
+func testSettings(t *testing.T, stores *repository.Stores) {
+	ctx := context.Background()
+
+	_, err := stores.Settings.GetSetting(ctx, models.SettingTopic)
+	if !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for an unset setting, got %v", err)
+	}
+
+	// Without an author, like a setting written by the server
+	check(t, stores.Settings.PutSetting(ctx, models.Setting{
+		Key:       models.SettingTopic,
+		Value:     "first",
+		UpdatedAt: now(),
+	}))
+
+	author := bson.NewObjectID()
+	updatedAt := now().Add(time.Minute)
+	check(t, stores.Settings.PutSetting(ctx, models.Setting{
+		Key:       models.SettingTopic,
+		Value:     "second",
+		UpdatedBy: author,
+		UpdatedAt: updatedAt,
+	}))
+
+	setting, err := stores.Settings.GetSetting(ctx, models.SettingTopic)
+	check(t, err)
+	if setting.Value != "second" || setting.UpdatedBy != author || !setting.UpdatedAt.Equal(updatedAt) {
+		t.Fatalf("setting not replaced: %+v", setting)
+	}
+}
+
+func testReports(t *testing.T, stores *repository.Stores) {
+	ctx := context.Background()
+	author := bson.NewObjectID()
+	moderator := bson.NewObjectID()
+	reporters := []bson.ObjectID{bson.NewObjectID(), bson.NewObjectID(), bson.NewObjectID()}
+	base := now()
+
+	popular := createMessage(t, stores, author, "popular", base)
+	quiet := createMessage(t, stores, author, "quiet", base)
+
+	report := func(messageID, reporter bson.ObjectID, category models.ReportCategory, at time.Time) bool {
+		t.Helper()
+		created, err := stores.Reports.AddReport(ctx, models.Report{
+			MessageID: messageID,
+			Reporter:  reporter,
+			Category:  category,
+			Reason:    "because",
+			CratedAt:  at,
+		})
+		check(t, err)
+		return created
+	}
+
+	for i, reporter := range reporters {
+		if !report(popular, reporter, models.ReportSpam, base.Add(time.Duration(i)*time.Minute)) {
+			t.Fatal("first report of a reporter was not created")
+		}
+	}
+	if report(popular, reporters[0], models.ReportHate, base) {
+		t.Fatal("duplicate report was created")
+	}
+	report(quiet, reporters[0], models.ReportNSFW, base)
+
+	count, err := stores.Reports.CountOpen(ctx, popular)
+	check(t, err)
+	if count != 3 {
+		t.Fatalf("expected 3 open reports, got %d", count)
+	}
+
+	groups, total, err := stores.Reports.FindOpenGrouped(ctx, 1, 10)
+	check(t, err)
+	if total != 2 || len(groups) != 2 {
+		t.Fatalf("expected 2 groups, got %d of %d", len(groups), total)
+	}
+	first := groups[0]
+	if first.MessageID != popular || first.Count != 3 {
+		t.Fatalf("wrong first group: %+v", first)
+	}
+	if len(first.Categories) != 1 || first.Categories[0] != models.ReportSpam {
+		t.Fatalf("wrong categories: %v", first.Categories)
+	}
+	if !first.FirstReportedAt.Equal(base) || !first.LastReportedAt.Equal(base.Add(2*time.Minute)) {
+		t.Fatalf("wrong report times: %v - %v", first.FirstReportedAt, first.LastReportedAt)
+	}
+	if first.Message == nil || first.Message.Text != "popular" {
+		t.Fatalf("message not attached to group: %+v", first.Message)
+	}
+
+	groups, total, err = stores.Reports.FindOpenGrouped(ctx, 2, 1)
+	check(t, err)
+	if total != 2 || len(groups) != 1 || groups[0].MessageID != quiet {
+		t.Fatalf("wrong second page: %+v", groups)
+	}
+
+	// Resolving closes all open reports and allows reporting again
+	resolved, err := stores.Reports.ResolveAll(ctx, popular, models.ResolutionDismissed, moderator, now())
+	check(t, err)
+	if resolved != 3 {
+		t.Fatalf("expected 3 resolved reports, got %d", resolved)
+	}
+	resolved, err = stores.Reports.ResolveAll(ctx, popular, models.ResolutionDismissed, moderator, now())
+	check(t, err)
+	if resolved != 0 {
+		t.Fatalf("resolved reports resolved again: %d", resolved)
+	}
+
+	count, err = stores.Reports.CountOpen(ctx, popular)
+	check(t, err)
+	if count != 0 {
+		t.Fatalf("expected no open reports, got %d", count)
+	}
+	if !report(popular, reporters[0], models.ReportSpam, base) {
+		t.Fatal("report after resolution was not created")
+	}
+
+	// Groups of deleted messages stay in the queue
+	check(t, stores.Messages.DeleteMessage(ctx, quiet))
+	groups, _, err = stores.Reports.FindOpenGrouped(ctx, 1, 10)
+	check(t, err)
+	for _, group := range groups {
+		if group.MessageID == quiet && group.Message != nil {
+			t.Fatal("deleted message attached to group")
+		}
+	}
+}
+
+func testAudit(t *testing.T, stores *repository.Stores) {
+	ctx := context.Background()
+	alice := bson.NewObjectID()
+	bob := bson.NewObjectID()
+	base := now()
+
+	events := []models.AuditEvent{
+		{Action: models.AuditLoginSucceeded, Actor: alice, IP: "10.0.0.1", CratedAt: base},
+		{Action: models.AuditLoginFailed, Username: "bob_builder", IP: "10.0.0.2", CratedAt: base.Add(time.Minute)},
+		{Action: models.AuditUserBanned, Actor: alice, Target: bob, Reason: "spam", CratedAt: base.Add(2 * time.Minute)},
+	}
+	for _, event := range events {
+		check(t, stores.Audit.Record(ctx, event))
+	}
+
+	all, total, err := stores.Audit.FindPaged(ctx, repository.AuditFilter{}, 1, 10)
+	check(t, err)
+	if total != 3 || len(all) != 3 {
+		t.Fatalf("expected 3 events, got %d of %d", len(all), total)
+	}
+	if all[0].Action != models.AuditUserBanned || all[0].Target != bob || all[0].Reason != "spam" || all[0].ID.IsZero() {
+		t.Fatalf("newest event not first or not stored as recorded: %+v", all[0])
+	}
+
+	cases := []struct {
+		name   string
+		filter repository.AuditFilter
+		want   int64
+	}{
+		{"actor", repository.AuditFilter{Actor: alice}, 2},
+		{"target", repository.AuditFilter{Target: bob}, 1},
+		{"actions", repository.AuditFilter{Actions: []models.AuditAction{models.AuditLoginFailed, models.AuditLoginSucceeded}}, 2},
+		{"ip", repository.AuditFilter{IP: "10.0.0.2"}, 1},
+		{"since", repository.AuditFilter{Since: base.Add(time.Minute)}, 2},
+		{"until", repository.AuditFilter{Until: base.Add(time.Minute)}, 1},
+		{"combined", repository.AuditFilter{Actor: alice, Since: base.Add(time.Second)}, 1},
+	}
+	for _, c := range cases {
+		_, total, err := stores.Audit.FindPaged(ctx, c.filter, 1, 10)
+		check(t, err)
+		if total != c.want {
+			t.Errorf("filter %s: expected %d events, got %d", c.name, c.want, total)
+		}
+	}
+}
+
+func testConcurrency(t *testing.T, stores *repository.Stores) {
+	ctx := context.Background()
+	author := bson.NewObjectID()
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			text := fmt.Sprintf("concurrent %d", i)
+			err := stores.Messages.CreateMessage(ctx, models.Message{Author: author, Text: text, CratedAt: now()})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			_, _, err := stores.Messages.FindPaged(ctx, 1, 5)
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	_, total, err := stores.Messages.FindPaged(ctx, 1, 5)
+	check(t, err)
+	if total != 20 {
+		t.Fatalf("expected 20 messages, got %d", total)
+	}
+
+	// Exactly one of concurrent registrations of the same name wins
+	var created atomic.Int32
+	names := []string{"race_winner", "RACE_WINNER", "Race_Winner", "rаce_winner"}
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			user := &models.User{Username: names[i%len(names)], HashedPassword: "hash", CratedAt: now()}
+			err := stores.Users.CreateUser(ctx, user)
+			switch {
+			case err == nil:
+				created.Add(1)
+			case !errors.Is(err, repository.ErrAlreadyExists):
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if created.Load() != 1 {
+		t.Fatalf("expected one registration to succeed, got %d", created.Load())
+	}
+}
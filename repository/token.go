@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/SomeSuperCoder/global-chat/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type TokenRepo struct {
+	Database *mongo.Database
+}
+
+func (r *TokenRepo) CreateToken(ctx context.Context, token models.APIToken) error {
+	_, err := r.Database.Collection("api_tokens").InsertOne(ctx, token)
+	return err
+}
+
+func (r *TokenRepo) FindTokens(ctx context.Context, userID bson.ObjectID) ([]models.APIToken, error) {
+	var tokens = []models.APIToken{}
+
+	opts := options.Find().SetSort(bson.M{"created_at": -1})
+	cursor, err := r.Database.Collection("api_tokens").Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cursor.All(ctx, &tokens)
+	return tokens, err
+}
+
+func (r *TokenRepo) DeleteToken(ctx context.Context, userID bson.ObjectID, tokenID bson.ObjectID) (bool, error) {
+	res, err := r.Database.Collection("api_tokens").DeleteOne(ctx, bson.M{
+		"_id":     tokenID,
+		"user_id": userID,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return res.DeletedCount > 0, nil
+}
@@ -0,0 +1,359 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/SomeSuperCoder/global-chat/models"
+	"github.com/SomeSuperCoder/global-chat/tracing"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.opentelemetry.io/otel"
+)
+
+var tracer = otel.Tracer("github.com/SomeSuperCoder/global-chat/repository")
+
+// Traced wraps every store so each call gets a span, whatever the backend.
+// Missing documents are not marked as errors.
+func Traced(stores *Stores) *Stores {
+	return &Stores{
+		Users:      &tracedUsers{next: stores.Users},
+		Sessions:   &tracedSessions{next: stores.Sessions},
+		Tokens:     &tracedTokens{next: stores.Tokens},
+		Webhooks:   &tracedWebhooks{next: stores.Webhooks},
+		Deliveries: &tracedDeliveries{next: stores.Deliveries},
+		Messages:   &tracedMessages{next: stores.Messages},
+		Settings:   &tracedSettings{next: stores.Settings},
+		Reports:    &tracedReports{next: stores.Reports},
+		Audit:      &tracedAudit{next: stores.Audit},
+	}
+}
+
+type tracedMessages struct {
+	next MessageStore
+}
+
+func (t *tracedMessages) FindPaged(ctx context.Context, page, limit int64) ([]models.Message, int64, error) {
+	ctx, span := tracer.Start(ctx, "MessageStore.FindPaged")
+	result, count, err := t.next.FindPaged(ctx, page, limit)
+	tracing.End(span, err, ErrNotFound)
+	return result, count, err
+}
+
+func (t *tracedMessages) GetMessageByID(ctx context.Context, messageID bson.ObjectID) (*models.Message, error) {
+	ctx, span := tracer.Start(ctx, "MessageStore.GetMessageByID")
+	result, err := t.next.GetMessageByID(ctx, messageID)
+	tracing.End(span, err, ErrNotFound)
+	return result, err
+}
+
+func (t *tracedMessages) CreateMessage(ctx context.Context, message models.Message) error {
+	ctx, span := tracer.Start(ctx, "MessageStore.CreateMessage")
+	err := t.next.CreateMessage(ctx, message)
+	tracing.End(span, err, ErrNotFound)
+	return err
+}
+
+func (t *tracedMessages) UpdateMessage(ctx context.Context, messageID bson.ObjectID, update models.MessageUpdate) error {
+	ctx, span := tracer.Start(ctx, "MessageStore.UpdateMessage")
+	err := t.next.UpdateMessage(ctx, messageID, update)
+	tracing.End(span, err, ErrNotFound)
+	return err
+}
+
+func (t *tracedMessages) DeleteMessage(ctx context.Context, messageID bson.ObjectID) error {
+	ctx, span := tracer.Start(ctx, "MessageStore.DeleteMessage")
+	err := t.next.DeleteMessage(ctx, messageID)
+	tracing.End(span, err, ErrNotFound)
+	return err
+}
+
+func (t *tracedMessages) SetHidden(ctx context.Context, messageID bson.ObjectID, hidden bool) error {
+	ctx, span := tracer.Start(ctx, "MessageStore.SetHidden")
+	err := t.next.SetHidden(ctx, messageID, hidden)
+	tracing.End(span, err, ErrNotFound)
+	return err
+}
+
+type tracedUsers struct {
+	next UserStore
+}
+
+func (t *tracedUsers) CreateUser(ctx context.Context, user *models.User) error {
+	ctx, span := tracer.Start(ctx, "UserStore.CreateUser")
+	err := t.next.CreateUser(ctx, user)
+	tracing.End(span, err, ErrNotFound)
+	return err
+}
+
+func (t *tracedUsers) GetUserByID(ctx context.Context, userID bson.ObjectID) (*models.User, error) {
+	ctx, span := tracer.Start(ctx, "UserStore.GetUserByID")
+	result, err := t.next.GetUserByID(ctx, userID)
+	tracing.End(span, err, ErrNotFound)
+	return result, err
+}
+
+func (t *tracedUsers) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	ctx, span := tracer.Start(ctx, "UserStore.GetUserByUsername")
+	result, err := t.next.GetUserByUsername(ctx, username)
+	tracing.End(span, err, ErrNotFound)
+	return result, err
+}
+
+func (t *tracedUsers) DoesExist(ctx context.Context, username string) (bool, error) {
+	ctx, span := tracer.Start(ctx, "UserStore.DoesExist")
+	result, err := t.next.DoesExist(ctx, username)
+	tracing.End(span, err, ErrNotFound)
+	return result, err
+}
+
+func (t *tracedUsers) SetRole(ctx context.Context, userID bson.ObjectID, role models.Role) error {
+	ctx, span := tracer.Start(ctx, "UserStore.SetRole")
+	err := t.next.SetRole(ctx, userID, role)
+	tracing.End(span, err, ErrNotFound)
+	return err
+}
+
+func (t *tracedUsers) SetPasswordHash(ctx context.Context, userID bson.ObjectID, hashedPassword string) error {
+	ctx, span := tracer.Start(ctx, "UserStore.SetPasswordHash")
+	err := t.next.SetPasswordHash(ctx, userID, hashedPassword)
+	tracing.End(span, err, ErrNotFound)
+	return err
+}
+
+func (t *tracedUsers) AddSanction(ctx context.Context, userID bson.ObjectID, sanction models.Sanction) error {
+	ctx, span := tracer.Start(ctx, "UserStore.AddSanction")
+	err := t.next.AddSanction(ctx, userID, sanction)
+	tracing.End(span, err, ErrNotFound)
+	return err
+}
+
+func (t *tracedUsers) LiftSanctions(ctx context.Context, userID bson.ObjectID, kinds []models.SanctionKind, liftedAt time.Time) error {
+	ctx, span := tracer.Start(ctx, "UserStore.LiftSanctions")
+	err := t.next.LiftSanctions(ctx, userID, kinds, liftedAt)
+	tracing.End(span, err, ErrNotFound)
+	return err
+}
+
+type tracedSessions struct {
+	next SessionStore
+}
+
+func (t *tracedSessions) AddLoginSession(ctx context.Context, username string, session models.UserSession) error {
+	ctx, span := tracer.Start(ctx, "SessionStore.AddLoginSession")
+	err := t.next.AddLoginSession(ctx, username, session)
+	tracing.End(span, err, ErrNotFound)
+	return err
+}
+
+func (t *tracedSessions) FinalizeSession(ctx context.Context, username string, sessionToken string) error {
+	ctx, span := tracer.Start(ctx, "SessionStore.FinalizeSession")
+	err := t.next.FinalizeSession(ctx, username, sessionToken)
+	tracing.End(span, err, ErrNotFound)
+	return err
+}
+
+func (t *tracedSessions) AuthCheck(ctx context.Context, sessionToken string, csrfToken string) (*UserAuth, error) {
+	ctx, span := tracer.Start(ctx, "SessionStore.AuthCheck")
+	result, err := t.next.AuthCheck(ctx, sessionToken, csrfToken)
+	tracing.End(span, err, ErrNotFound)
+	return result, err
+}
+
+func (t *tracedSessions) TokenAuthCheck(ctx context.Context, hashedToken string) (*UserAuth, error) {
+	ctx, span := tracer.Start(ctx, "SessionStore.TokenAuthCheck")
+	result, err := t.next.TokenAuthCheck(ctx, hashedToken)
+	tracing.End(span, err, ErrNotFound)
+	return result, err
+}
+
+type tracedTokens struct {
+	next TokenStore
+}
+
+func (t *tracedTokens) CreateToken(ctx context.Context, token models.APIToken) error {
+	ctx, span := tracer.Start(ctx, "TokenStore.CreateToken")
+	err := t.next.CreateToken(ctx, token)
+	tracing.End(span, err, ErrNotFound)
+	return err
+}
+
+func (t *tracedTokens) FindTokens(ctx context.Context, userID bson.ObjectID) ([]models.APIToken, error) {
+	ctx, span := tracer.Start(ctx, "TokenStore.FindTokens")
+	result, err := t.next.FindTokens(ctx, userID)
+	tracing.End(span, err, ErrNotFound)
+	return result, err
+}
+
+func (t *tracedTokens) DeleteToken(ctx context.Context, userID bson.ObjectID, tokenID bson.ObjectID) (bool, error) {
+	ctx, span := tracer.Start(ctx, "TokenStore.DeleteToken")
+	result, err := t.next.DeleteToken(ctx, userID, tokenID)
+	tracing.End(span, err, ErrNotFound)
+	return result, err
+}
+
+type tracedWebhooks struct {
+	next WebhookStore
+}
+
+func (t *tracedWebhooks) CreateIncomingWebhook(ctx context.Context, hook models.IncomingWebhook) error {
+	ctx, span := tracer.Start(ctx, "WebhookStore.CreateIncomingWebhook")
+	err := t.next.CreateIncomingWebhook(ctx, hook)
+	tracing.End(span, err, ErrNotFound)
+	return err
+}
+
+func (t *tracedWebhooks) GetIncomingWebhook(ctx context.Context, hashedToken string) (*models.IncomingWebhook, error) {
+	ctx, span := tracer.Start(ctx, "WebhookStore.GetIncomingWebhook")
+	result, err := t.next.GetIncomingWebhook(ctx, hashedToken)
+	tracing.End(span, err, ErrNotFound)
+	return result, err
+}
+
+func (t *tracedWebhooks) FindIncomingWebhooks(ctx context.Context) ([]models.IncomingWebhook, error) {
+	ctx, span := tracer.Start(ctx, "WebhookStore.FindIncomingWebhooks")
+	result, err := t.next.FindIncomingWebhooks(ctx)
+	tracing.End(span, err, ErrNotFound)
+	return result, err
+}
+
+func (t *tracedWebhooks) DeleteIncomingWebhook(ctx context.Context, hookID bson.ObjectID) (bool, error) {
+	ctx, span := tracer.Start(ctx, "WebhookStore.DeleteIncomingWebhook")
+	result, err := t.next.DeleteIncomingWebhook(ctx, hookID)
+	tracing.End(span, err, ErrNotFound)
+	return result, err
+}
+
+func (t *tracedWebhooks) CreateOutgoingWebhook(ctx context.Context, hook models.OutgoingWebhook) error {
+	ctx, span := tracer.Start(ctx, "WebhookStore.CreateOutgoingWebhook")
+	err := t.next.CreateOutgoingWebhook(ctx, hook)
+	tracing.End(span, err, ErrNotFound)
+	return err
+}
+
+func (t *tracedWebhooks) GetOutgoingWebhook(ctx context.Context, hookID bson.ObjectID) (*models.OutgoingWebhook, error) {
+	ctx, span := tracer.Start(ctx, "WebhookStore.GetOutgoingWebhook")
+	result, err := t.next.GetOutgoingWebhook(ctx, hookID)
+	tracing.End(span, err, ErrNotFound)
+	return result, err
+}
+
+func (t *tracedWebhooks) FindOutgoingWebhooks(ctx context.Context) ([]models.OutgoingWebhook, error) {
+	ctx, span := tracer.Start(ctx, "WebhookStore.FindOutgoingWebhooks")
+	result, err := t.next.FindOutgoingWebhooks(ctx)
+	tracing.End(span, err, ErrNotFound)
+	return result, err
+}
+
+func (t *tracedWebhooks) DeleteOutgoingWebhook(ctx context.Context, hookID bson.ObjectID) (bool, error) {
+	ctx, span := tracer.Start(ctx, "WebhookStore.DeleteOutgoingWebhook")
+	result, err := t.next.DeleteOutgoingWebhook(ctx, hookID)
+	tracing.End(span, err, ErrNotFound)
+	return result, err
+}
+
+type tracedDeliveries struct {
+	next DeliveryStore
+}
+
+func (t *tracedDeliveries) EnqueueDeliveries(ctx context.Context, deliveries []models.WebhookDelivery) error {
+	ctx, span := tracer.Start(ctx, "DeliveryStore.EnqueueDeliveries")
+	err := t.next.EnqueueDeliveries(ctx, deliveries)
+	tracing.End(span, err, ErrNotFound)
+	return err
+}
+
+func (t *tracedDeliveries) ClaimDelivery(ctx context.Context, now, leaseUntil time.Time) (*models.WebhookDelivery, error) {
+	ctx, span := tracer.Start(ctx, "DeliveryStore.ClaimDelivery")
+	result, err := t.next.ClaimDelivery(ctx, now, leaseUntil)
+	tracing.End(span, err, ErrNotFound)
+	return result, err
+}
+
+func (t *tracedDeliveries) UpdateDelivery(ctx context.Context, delivery models.WebhookDelivery) error {
+	ctx, span := tracer.Start(ctx, "DeliveryStore.UpdateDelivery")
+	err := t.next.UpdateDelivery(ctx, delivery)
+	tracing.End(span, err, ErrNotFound)
+	return err
+}
+
+func (t *tracedDeliveries) FindDeliveries(ctx context.Context, hookID bson.ObjectID, status models.DeliveryStatus, page, limit int64) ([]models.WebhookDelivery, int64, error) {
+	ctx, span := tracer.Start(ctx, "DeliveryStore.FindDeliveries")
+	result, count, err := t.next.FindDeliveries(ctx, hookID, status, page, limit)
+	tracing.End(span, err, ErrNotFound)
+	return result, count, err
+}
+
+func (t *tracedDeliveries) RetryDelivery(ctx context.Context, deliveryID bson.ObjectID, now time.Time) (bool, error) {
+	ctx, span := tracer.Start(ctx, "DeliveryStore.RetryDelivery")
+	result, err := t.next.RetryDelivery(ctx, deliveryID, now)
+	tracing.End(span, err, ErrNotFound)
+	return result, err
+}
+
+type tracedSettings struct {
+	next SettingsStore
+}
+
+func (t *tracedSettings) GetSetting(ctx context.Context, key string) (*models.Setting, error) {
+	ctx, span := tracer.Start(ctx, "SettingsStore.GetSetting")
+	result, err := t.next.GetSetting(ctx, key)
+	tracing.End(span, err, ErrNotFound)
+	return result, err
+}
+
+func (t *tracedSettings) PutSetting(ctx context.Context, setting models.Setting) error {
+	ctx, span := tracer.Start(ctx, "SettingsStore.PutSetting")
+	err := t.next.PutSetting(ctx, setting)
+	tracing.End(span, err, ErrNotFound)
+	return err
+}
+
+type tracedReports struct {
+	next ReportStore
+}
+
+func (t *tracedReports) AddReport(ctx context.Context, report models.Report) (bool, error) {
+	ctx, span := tracer.Start(ctx, "ReportStore.AddReport")
+	result, err := t.next.AddReport(ctx, report)
+	tracing.End(span, err, ErrNotFound)
+	return result, err
+}
+
+func (t *tracedReports) CountOpen(ctx context.Context, messageID bson.ObjectID) (int64, error) {
+	ctx, span := tracer.Start(ctx, "ReportStore.CountOpen")
+	result, err := t.next.CountOpen(ctx, messageID)
+	tracing.End(span, err, ErrNotFound)
+	return result, err
+}
+
+func (t *tracedReports) FindOpenGrouped(ctx context.Context, page, limit int64) ([]models.ReportGroup, int64, error) {
+	ctx, span := tracer.Start(ctx, "ReportStore.FindOpenGrouped")
+	result, count, err := t.next.FindOpenGrouped(ctx, page, limit)
+	tracing.End(span, err, ErrNotFound)
+	return result, count, err
+}
+
+func (t *tracedReports) ResolveAll(ctx context.Context, messageID bson.ObjectID, resolution models.ReportResolution, resolvedBy bson.ObjectID, resolvedAt time.Time) (int64, error) {
+	ctx, span := tracer.Start(ctx, "ReportStore.ResolveAll")
+	result, err := t.next.ResolveAll(ctx, messageID, resolution, resolvedBy, resolvedAt)
+	tracing.End(span, err, ErrNotFound)
+	return result, err
+}
+
+type tracedAudit struct {
+	next AuditStore
+}
+
+func (t *tracedAudit) Record(ctx context.Context, event models.AuditEvent) error {
+	ctx, span := tracer.Start(ctx, "AuditStore.Record")
+	err := t.next.Record(ctx, event)
+	tracing.End(span, err, ErrNotFound)
+	return err
+}
+
+func (t *tracedAudit) FindPaged(ctx context.Context, filter AuditFilter, page, limit int64) ([]models.AuditEvent, int64, error) {
+	ctx, span := tracer.Start(ctx, "AuditStore.FindPaged")
+	result, count, err := t.next.FindPaged(ctx, filter, page, limit)
+	tracing.End(span, err, ErrNotFound)
+	return result, count, err
+}
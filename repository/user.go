@@ -3,9 +3,10 @@ package repository
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/SomeSuperCoder/global-chat/models"
-	"github.com/sirupsen/logrus"
+	"github.com/SomeSuperCoder/global-chat/usernames"
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
@@ -16,12 +17,26 @@ type UserRepo struct {
 }
 
 type UserAuth struct {
-	Username string
-	UserID   bson.ObjectID
+	Username  string
+	UserID    bson.ObjectID
+	Role      models.Role
+	Sanctions []models.Sanction
+
+	// Set when the request was authenticated with an API token
+	Token *models.APIToken
 }
 
+// The unique index on username_key rejects names that are already taken
 func (r *UserRepo) CreateUser(ctx context.Context, user *models.User) error {
+	if user.ID.IsZero() {
+		user.ID = bson.NewObjectID()
+	}
+	user.UsernameKey = usernames.Key(user.Username)
+
 	_, err := r.Database.Collection("users").InsertOne(ctx, user)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrAlreadyExists
+	}
 	return err
 }
 
@@ -30,7 +45,7 @@ func (r *UserRepo) GetUserByID(ctx context.Context, userID bson.ObjectID) (*mode
 }
 
 func (r *UserRepo) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
-	return r.getUserCommon(ctx, bson.M{"username": username})
+	return r.getUserCommon(ctx, bson.M{"username_key": usernames.Key(username)})
 }
 
 func (r *UserRepo) getUserCommon(ctx context.Context, filter bson.M) (*models.User, error) {
@@ -41,16 +56,67 @@ func (r *UserRepo) getUserCommon(ctx context.Context, filter bson.M) (*models.Us
 	var user models.User
 	err := r.Database.Collection("users").FindOne(ctx, filter, opts).Decode(&user)
 	if err != nil {
-		return nil, err
+		return nil, notFound(err)
 	}
 
 	return &user, err
 
 }
 
-func (r *UserRepo) DoesExist(ctx context.Context, username string) bool {
-	res := r.Database.Collection("users").FindOne(ctx, bson.M{"username": username})
-	return !errors.Is(res.Err(), mongo.ErrNoDocuments)
+func (r *UserRepo) DoesExist(ctx context.Context, username string) (bool, error) {
+	count, err := r.Database.Collection("users").CountDocuments(ctx, bson.M{
+		"username_key": usernames.Key(username),
+	}, options.Count().SetLimit(1))
+	return count > 0, err
+}
+
+func (r *UserRepo) SetPasswordHash(ctx context.Context, userID bson.ObjectID, hashedPassword string) error {
+	_, err := r.Database.Collection("users").UpdateByID(ctx, userID, bson.M{
+		"$set": bson.M{"hashed_password": hashedPassword},
+	})
+	return err
+}
+
+func (r *UserRepo) SetRole(ctx context.Context, userID bson.ObjectID, role models.Role) error {
+	_, err := r.Database.Collection("users").UpdateByID(ctx, userID, bson.M{
+		"$set": bson.M{"role": role},
+	})
+	return err
+}
+
+// Adding a ban also terminates every session of the user
+func (r *UserRepo) AddSanction(ctx context.Context, userID bson.ObjectID, sanction models.Sanction) error {
+	update := bson.M{
+		"$push": bson.M{
+			"sanctions": sanction,
+		},
+	}
+	if sanction.Kind == models.SanctionBan {
+		update["$set"] = bson.M{"sessions": []models.UserSession{}}
+	}
+
+	_, err := r.Database.Collection("users").UpdateByID(ctx, userID, update)
+	return err
+}
+
+func (r *UserRepo) LiftSanctions(ctx context.Context, userID bson.ObjectID, kinds []models.SanctionKind, liftedAt time.Time) error {
+	opts := options.UpdateOne().SetArrayFilters([]any{
+		bson.M{
+			"s.kind":      bson.M{"$in": kinds},
+			"s.lifted_at": nil,
+		},
+	})
+
+	// Array filters fail on users that never had a sanction
+	filter := bson.M{
+		"_id":       userID,
+		"sanctions": bson.M{"$type": "array"},
+	}
+
+	_, err := r.Database.Collection("users").UpdateOne(ctx, filter, bson.M{
+		"$set": bson.M{"sanctions.$[s].lifted_at": liftedAt},
+	}, opts)
+	return err
 }
 
 func (r *UserRepo) AddLoginSession(ctx context.Context, username string, session models.UserSession) error {
@@ -91,15 +157,40 @@ func (r *UserRepo) AuthCheck(ctx context.Context, sessionToken string, csrfToken
 
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
-			return nil, err
+			return nil, ErrNotFound
 		}
-		logrus.Error(err.Error())
+		log.WithContext(ctx).Errorf("Failed to check a session: %v", err)
+		return nil, err
+	}
+
+	userAuth := &UserAuth{
+		Username:  user.Username,
+		UserID:    user.ID,
+		Role:      user.Role,
+		Sanctions: user.Sanctions,
+	}
+
+	return userAuth, nil
+}
+
+func (r *UserRepo) TokenAuthCheck(ctx context.Context, hashedToken string) (*UserAuth, error) {
+	var token models.APIToken
+	err := r.Database.Collection("api_tokens").FindOne(ctx, bson.M{"hashed_token": hashedToken}).Decode(&token)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	user, err := r.GetUserByID(ctx, token.UserID)
+	if err != nil {
 		return nil, err
 	}
 
 	userAuth := &UserAuth{
-		Username: user.Username,
-		UserID:   user.ID,
+		Username:  user.Username,
+		UserID:    user.ID,
+		Role:      user.Role,
+		Sanctions: user.Sanctions,
+		Token:     &token,
 	}
 
 	return userAuth, nil
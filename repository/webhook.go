@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/SomeSuperCoder/global-chat/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type WebhookRepo struct {
+	Database *mongo.Database
+}
+
+func (r *WebhookRepo) CreateIncomingWebhook(ctx context.Context, hook models.IncomingWebhook) error {
+	_, err := r.Database.Collection("incoming_webhooks").InsertOne(ctx, hook)
+	return err
+}
+
+func (r *WebhookRepo) GetIncomingWebhook(ctx context.Context, hashedToken string) (*models.IncomingWebhook, error) {
+	var hook models.IncomingWebhook
+	err := r.Database.Collection("incoming_webhooks").FindOne(ctx, bson.M{"hashed_token": hashedToken}).Decode(&hook)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	return &hook, nil
+}
+
+func (r *WebhookRepo) FindIncomingWebhooks(ctx context.Context) ([]models.IncomingWebhook, error) {
+	var hooks = []models.IncomingWebhook{}
+
+	opts := options.Find().SetSort(bson.M{"created_at": -1})
+	cursor, err := r.Database.Collection("incoming_webhooks").Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cursor.All(ctx, &hooks)
+	return hooks, err
+}
+
+func (r *WebhookRepo) DeleteIncomingWebhook(ctx context.Context, hookID bson.ObjectID) (bool, error) {
+	res, err := r.Database.Collection("incoming_webhooks").DeleteOne(ctx, bson.M{"_id": hookID})
+	if err != nil {
+		return false, err
+	}
+
+	return res.DeletedCount > 0, nil
+}
+
+func (r *WebhookRepo) CreateOutgoingWebhook(ctx context.Context, hook models.OutgoingWebhook) error {
+	_, err := r.Database.Collection("outgoing_webhooks").InsertOne(ctx, hook)
+	return err
+}
+
+func (r *WebhookRepo) GetOutgoingWebhook(ctx context.Context, hookID bson.ObjectID) (*models.OutgoingWebhook, error) {
+	var hook models.OutgoingWebhook
+	err := r.Database.Collection("outgoing_webhooks").FindOne(ctx, bson.M{"_id": hookID}).Decode(&hook)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	return &hook, nil
+}
+
+func (r *WebhookRepo) FindOutgoingWebhooks(ctx context.Context) ([]models.OutgoingWebhook, error) {
+	var hooks = []models.OutgoingWebhook{}
+
+	opts := options.Find().SetSort(bson.M{"created_at": -1})
+	cursor, err := r.Database.Collection("outgoing_webhooks").Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cursor.All(ctx, &hooks)
+	return hooks, err
+}
+
+func (r *WebhookRepo) DeleteOutgoingWebhook(ctx context.Context, hookID bson.ObjectID) (bool, error) {
+	res, err := r.Database.Collection("outgoing_webhooks").DeleteOne(ctx, bson.M{"_id": hookID})
+	if err != nil {
+		return false, err
+	}
+
+	_, err = r.Database.Collection("webhook_deliveries").DeleteMany(ctx, bson.M{"webhook_id": hookID})
+	if err != nil {
+		return false, err
+	}
+
+	return res.DeletedCount > 0, nil
+}
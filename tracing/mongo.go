@@ -0,0 +1,54 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/v2/event"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// MongoMonitor starts a span for every command sent to MongoDB, as a child of
+// the span in the context of the operation. Pass it to
+// options.Client().SetMonitor.
+func MongoMonitor() *event.CommandMonitor {
+	tracer := otel.Tracer("github.com/SomeSuperCoder/global-chat/mongo")
+
+	// Running commands by request ID
+	var spans sync.Map
+
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			attributes := []attribute.KeyValue{
+				semconv.DBSystemNameMongoDB,
+				semconv.DBOperationName(e.CommandName),
+				semconv.DBNamespace(e.DatabaseName),
+			}
+			// The first element of a command names the collection
+			if collection, ok := e.Command.Index(0).Value().StringValueOK(); ok {
+				attributes = append(attributes, semconv.DBCollectionName(collection))
+			}
+
+			_, span := tracer.Start(ctx, "mongo."+e.CommandName,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(attributes...),
+			)
+			spans.Store(e.RequestID, span)
+		},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			if span, ok := spans.LoadAndDelete(e.RequestID); ok {
+				span.(trace.Span).End()
+			}
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			if span, ok := spans.LoadAndDelete(e.RequestID); ok {
+				span.(trace.Span).SetStatus(codes.Error, e.Failure.Error())
+				span.(trace.Span).End()
+			}
+		},
+	}
+}
@@ -0,0 +1,160 @@
+// Package usernames decides when two usernames are considered the same
+package usernames
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/mtibben/confusables"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Key returns the canonical form of a username. Usernames with the same key
+// are the same name: compatibility characters (fullwidth letters, ligatures,
+// math alphabets) are normalized with NFKC, the comparison is
+// case-insensitive, and characters that look alike are replaced by the
+// skeleton from the Unicode confusables list (UTS #39), so "Admin" and
+// "аdmin" with a Cyrillic "а", "rn" and "m", or "PayPaI" and "paypal" share
+// a key.
+//
+// The key is only used for comparison, the username is displayed as entered.
+func Key(username string) string {
+	key := fold(username)
+	// Folding can turn a skeleton into something that has a skeleton again
+	for range maxRounds {
+		next := fold(skeleton(key))
+		if next == key {
+			break
+		}
+		key = next
+	}
+	return norm.NFC.String(key)
+}
+
+// Enough for every chain in the confusables list
+const maxRounds = 8
+
+func fold(s string) string {
+	return norm.NFKC.String(cases.Fold().String(norm.NFKC.String(s)))
+}
+
+// skeleton maps every character of a folded string to its confusables
+// prototype. The list is case-sensitive, and a lowercase letter can look like
+// something else than its uppercase form ("η" and "Η"). Since both cases
+// share a key, the uppercase form wins when it is confusable, because that is
+// the form that passes for Latin in a name, and "I" looking like "l" has to
+// make "i" and "l" the same too.
+func skeleton(s string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(s) {
+		if invisible[r] {
+			continue
+		}
+		if upper := unicode.ToUpper(r); upper != r {
+			if prototype, ok := prototypeOf(upper); ok {
+				b.WriteString(prototype)
+				continue
+			}
+		}
+		if prototype, ok := prototypeOf(r); ok {
+			b.WriteString(prototype)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return norm.NFD.String(b.String())
+}
+
+func prototypeOf(r rune) (string, bool) {
+	char := norm.NFD.String(string(r))
+	prototype := confusables.Skeleton(char)
+	return prototype, prototype != char
+}
+
+// Characters that render as nothing
+var invisible = map[rune]bool{
+	'\u00ad': true, // soft hyphen
+	'\u034f': true, // combining grapheme joiner
+	'\u200b': true, // zero width space
+	'\u200c': true, // zero width non-joiner
+	'\u200d': true, // zero width joiner
+	'\u2060': true, // word joiner
+	'\ufeff': true, // zero width no-break space
+}
+
+// Account is what Deduplicate needs to know about a user
+type Account struct {
+	ID        string
+	Username  string
+	CreatedAt time.Time
+}
+
+// Rename moves an account off a username that has the same key as the
+// username of an older account
+type Rename struct {
+	ID        string
+	From      string
+	To        string
+	OwnerID   string // The older account that keeps the name
+	OwnerName string // The name it keeps
+}
+
+func (r Rename) String() string {
+	return fmt.Sprintf("user %s %q to %q, user %s %q has the same username",
+		r.ID, r.From, r.To, r.OwnerID, r.OwnerName)
+}
+
+// Deduplicate finds the accounts whose usernames share a key and renames all
+// but the oldest one of every group by appending the end of their ID, which
+// is enough to make the key unique again. The result does not depend on the
+// order of the accounts.
+func Deduplicate(accounts []Account) []Rename {
+	sorted := slices.Clone(accounts)
+	slices.SortFunc(sorted, func(a, b Account) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+
+	owners := make(map[string]Account, len(sorted))
+	for _, account := range sorted {
+		key := Key(account.Username)
+		if _, ok := owners[key]; !ok {
+			owners[key] = account
+		}
+	}
+
+	var renames []Rename
+	for _, account := range sorted {
+		owner := owners[Key(account.Username)]
+		if owner.ID == account.ID {
+			continue
+		}
+
+		to := suffixed(account, owners)
+		owners[Key(to)] = account
+		renames = append(renames, Rename{
+			ID:        account.ID,
+			From:      account.Username,
+			To:        to,
+			OwnerID:   owner.ID,
+			OwnerName: owner.Username,
+		})
+	}
+	return renames
+}
+
+func suffixed(account Account, taken map[string]Account) string {
+	for n := 6; ; n++ {
+		suffix := account.ID[max(len(account.ID)-n, 0):]
+		name := account.Username + "_" + suffix
+		if _, ok := taken[Key(name)]; !ok || n >= len(account.ID) {
+			return name
+		}
+	}
+}
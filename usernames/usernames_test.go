@@ -0,0 +1,97 @@
+package usernames
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		// Case
+		{"Admin", "ADMIN", true},
+		{"Томас", "ТОМАС", true},
+		{"Нина", "нина", true},
+		{"Алексей", "АЛЕКСЕЙ", true},
+		{"ΑΘΗΝΑ", "αθηνα", true},
+		{"Straße", "STRASSE", true},
+
+		// Compatibility characters
+		{"Ａｄｍｉｎ", "admin", true},
+		{"ﬁsh", "fish", true},
+
+		// Confusables
+		{"аdmin", "admin", true},
+		{"НELLO", "hello", true},
+		{"ΗELLO", "hello", true},
+		{"КОТ", "kot", true},
+		{"rοοt", "root", true},
+		{"adm\u200bin", "admin", true},
+		{"Admin", "аdmin", true},
+		{"rn", "m", true},
+		{"modern", "rnodern", true},
+		{"O0", "oo", true},
+		{"O0", "OO", true},
+		{"PayPaI", "paypal", true},
+		{"PayPaI", "PAYPAL", true},
+		{"I1l", "lll", true},
+		{"ѕсоре", "scope", true},
+
+		// Different names
+		{"admin", "admins", false},
+		{"Томас", "Тома", false},
+		{"нина", "нана", false},
+		{"αθηνα", "αθηνη", false},
+		{"rn", "n", false},
+		{"O0", "o", false},
+	}
+
+	for _, test := range tests {
+		keyA, keyB := Key(test.a), Key(test.b)
+		if (keyA == keyB) != test.same {
+			t.Errorf("Key(%q) = %q, Key(%q) = %q, same = %v, want %v",
+				test.a, keyA, test.b, keyB, keyA == keyB, test.same)
+		}
+	}
+}
+
+func TestDeduplicate(t *testing.T) {
+	day := func(n int) time.Time { return time.Date(2024, 1, n, 0, 0, 0, 0, time.UTC) }
+	accounts := []Account{
+		{ID: "000000000000000000000003", Username: "аdmin", CreatedAt: day(3)},
+		{ID: "000000000000000000000001", Username: "Admin", CreatedAt: day(1)},
+		{ID: "000000000000000000000002", Username: "ADMIN", CreatedAt: day(2)},
+		{ID: "00000000000000000000000a", Username: "paypal", CreatedAt: day(1)},
+		{ID: "00000000000000000000000b", Username: "PayPaI", CreatedAt: day(1)},
+		{ID: "00000000000000000000000c", Username: "alone", CreatedAt: day(1)},
+	}
+
+	want := []Rename{
+		{ID: "00000000000000000000000b", From: "PayPaI", To: "PayPaI_00000b", OwnerID: "00000000000000000000000a", OwnerName: "paypal"},
+		{ID: "000000000000000000000002", From: "ADMIN", To: "ADMIN_000002", OwnerID: "000000000000000000000001", OwnerName: "Admin"},
+		{ID: "000000000000000000000003", From: "аdmin", To: "аdmin_000003", OwnerID: "000000000000000000000001", OwnerName: "Admin"},
+	}
+
+	reversed := slices.Clone(accounts)
+	slices.Reverse(reversed)
+	for _, order := range [][]Account{accounts, reversed} {
+		got := Deduplicate(order)
+		if !slices.Equal(got, want) {
+			t.Errorf("Deduplicate() = %+v, want %+v", got, want)
+		}
+	}
+
+	// A suffix that is taken already gets longer
+	taken := []Account{
+		{ID: "aaaaaaaaaaaaaaaaaa000001", Username: "bob", CreatedAt: day(1)},
+		{ID: "bbbbbbbbbbbbbbbbbb000002", Username: "bob_000002", CreatedAt: day(1)},
+		{ID: "cccccccccccccccccc000002", Username: "BOB", CreatedAt: day(2)},
+	}
+	got := Deduplicate(taken)
+	if len(got) != 1 || got[0].To != "BOB_c000002" {
+		t.Errorf("Deduplicate() = %+v, want BOB renamed to BOB_c000002", got)
+	}
+}
@@ -1,22 +1,17 @@
 package utils
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"log"
-
-	"golang.org/x/crypto/bcrypt"
+	"strconv"
 )
 
-func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 10)
-	return string(bytes), err
-}
-
-func CheckPasswordhash(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
-}
+// Prefix of personal API tokens, makes them easy to recognize in leaked secrets
+const APITokenPrefix = "gct_"
 
 func GenerateToken(length int) string {
 	bytes := make([]byte, length)
@@ -27,3 +22,27 @@ func GenerateToken(length int) string {
 
 	return base64.URLEncoding.EncodeToString(bytes)
 }
+
+func GenerateAPIToken() string {
+	return APITokenPrefix + GenerateToken(32)
+}
+
+// API tokens are random enough that a plain SHA-256 is safe to store
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Value of the signature header of webhook payloads, sha256=<hex HMAC> of
+// "<timestamp>.<payload>". The timestamp is in Unix seconds and is signed
+// too, so a captured request cannot be sent again later with a new one.
+func SignPayload(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func VerifySignature(secret string, timestamp int64, payload []byte, signature string) bool {
+	return hmac.Equal([]byte(SignPayload(secret, timestamp, payload)), []byte(signature))
+}
@@ -3,23 +3,33 @@ package utils
 import (
 	"errors"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/SomeSuperCoder/global-chat/cookies"
 	"github.com/SomeSuperCoder/global-chat/repository"
-	"go.mongodb.org/mongo-driver/v2/mongo"
 )
 
 var AuthError = errors.New("Unauthorized")
-
-func Authorize(r *http.Request, db *mongo.Database) (*repository.UserAuth, error) {
-	// Init repo
-	repo := repository.UserRepo{
-		Database: db,
+var TokenExpiredError = errors.New("API token expired")
+
+func Authorize(r *http.Request, sessions repository.SessionStore) (*repository.UserAuth, error) {
+	// API tokens need no CSRF token, browsers never attach them on their own
+	if token, ok := bearerToken(r); ok {
+		userAuth, err := sessions.TokenAuthCheck(r.Context(), HashToken(token))
+		if err != nil {
+			return nil, err
+		}
+		if userAuth.Token.Expired(time.Now()) {
+			return nil, TokenExpiredError
+		}
+		return userAuth, nil
 	}
 
 	// Get the session token from the cookie
-	st, err := r.Cookie("session_token")
+	st, err := r.Cookie(cookies.FromContext(r.Context()).SessionName())
 	if err != nil || st.Value == "" {
-		return nil, err
+		return nil, AuthError
 	}
 
 	// Get the CSRF token from the headers
@@ -29,10 +39,25 @@ func Authorize(r *http.Request, db *mongo.Database) (*repository.UserAuth, error
 	}
 
 	// Verify
-	userAuth, err := repo.AuthCheck(r.Context(), st.Value, csrf)
+	userAuth, err := sessions.AuthCheck(r.Context(), st.Value, csrf)
 	if err != nil {
 		return nil, err
 	}
 
 	return userAuth, nil
 }
+
+// HasBearerToken reports whether the request authenticates with an API token
+func HasBearerToken(r *http.Request) bool {
+	_, ok := bearerToken(r)
+	return ok
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
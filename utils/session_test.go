@@ -0,0 +1,31 @@
+package utils_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SomeSuperCoder/global-chat/cookies"
+	"github.com/SomeSuperCoder/global-chat/repository/memory"
+	"github.com/SomeSuperCoder/global-chat/utils"
+)
+
+func TestAuthorizeWithoutSession(t *testing.T) {
+	sessions := memory.NewStores().Sessions
+
+	requests := map[string]*http.Request{
+		"no cookie": httptest.NewRequest(http.MethodGet, "/", nil),
+	}
+	empty := httptest.NewRequest(http.MethodGet, "/", nil)
+	empty.AddCookie(&http.Cookie{Name: cookies.Default.SessionName(), Value: ""})
+	requests["empty cookie"] = empty
+
+	for name, r := range requests {
+		r.Header.Set("X-CSRF-Token", "csrf")
+		userAuth, err := utils.Authorize(r, sessions)
+		if userAuth != nil || !errors.Is(err, utils.AuthError) {
+			t.Errorf("%s: got %v, %v, want nil, %v", name, userAuth, err, utils.AuthError)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+// Package webhooks delivers chat events to outgoing webhooks. Events are
+// queued in the store first, so deliveries survive restarts, and workers send
+// them with retries and exponential backoff.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/SomeSuperCoder/global-chat/logging"
+	"github.com/SomeSuperCoder/global-chat/models"
+	"github.com/SomeSuperCoder/global-chat/repository"
+	"github.com/SomeSuperCoder/global-chat/utils"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Headers sent with every delivery. Incoming webhooks are signed the same
+// way, with the same header.
+const (
+	SignatureHeader = "X-Signature-256"
+	TimestampHeader = "X-Signature-Timestamp"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+)
+
+var log = logging.Package("webhooks")
+
+// Only this much of a response body is read, the rest is discarded
+const maxResponseBody = 64 << 10
+
+// Body of every delivery
+type Payload struct {
+	ID       bson.ObjectID       `json:"id"` // Same for every webhook that receives the event
+	Event    models.WebhookEvent `json:"event"`
+	CratedAt time.Time           `json:"created_at"`
+	Data     any                 `json:"data"`
+}
+
+type Dispatcher struct {
+	Webhooks   repository.WebhookStore
+	Deliveries repository.DeliveryStore
+	Client     *http.Client
+
+	// A delivery is dead after this many failed attempts
+	MaxAttempts int
+	// Wait after the first failed attempt, doubled after every further one
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// How often the queue is checked for retries that became due
+	PollInterval time.Duration
+	// How long a claimed delivery is hidden from other workers, has to be
+	// longer than the client timeout
+	Lease   time.Duration
+	Workers int
+
+	// Replaceable for tests
+	Now func() time.Time
+
+	wake chan struct{}
+}
+
+func New(webhooks repository.WebhookStore, deliveries repository.DeliveryStore) *Dispatcher {
+	return &Dispatcher{
+		Webhooks:     webhooks,
+		Deliveries:   deliveries,
+		Client:       &http.Client{Timeout: 10 * time.Second},
+		MaxAttempts:  8,
+		BaseBackoff:  30 * time.Second,
+		MaxBackoff:   time.Hour,
+		PollInterval: 5 * time.Second,
+		Lease:        time.Minute,
+		Workers:      2,
+		Now:          time.Now,
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+// Publish queues the event for every webhook subscribed to it. The event is
+// sent later by the workers.
+func (d *Dispatcher) Publish(ctx context.Context, event models.WebhookEvent, data any) error {
+	hooks, err := d.Webhooks.FindOutgoingWebhooks(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := d.Now()
+	payload, err := json.Marshal(Payload{
+		ID:       bson.NewObjectID(),
+		Event:    event,
+		CratedAt: now,
+		Data:     data,
+	})
+	if err != nil {
+		return err
+	}
+
+	var deliveries []models.WebhookDelivery
+	for _, hook := range hooks {
+		if !hook.Subscribed(event) {
+			continue
+		}
+		deliveries = append(deliveries, models.WebhookDelivery{
+			ID:            bson.NewObjectID(),
+			WebhookID:     hook.ID,
+			Event:         event,
+			Payload:       string(payload),
+			Status:        models.DeliveryPending,
+			NextAttemptAt: now,
+			CratedAt:      now,
+		})
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	err = d.Deliveries.EnqueueDeliveries(ctx, deliveries)
+	if err != nil {
+		return err
+	}
+
+	// Don't wait for the next poll
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// Run starts the workers and blocks until the context is canceled
+func (d *Dispatcher) Run(ctx context.Context) {
+	done := make(chan struct{})
+	for range d.Workers {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			d.work(ctx)
+		}()
+	}
+
+	for range d.Workers {
+		<-done
+	}
+}
+
+func (d *Dispatcher) work(ctx context.Context) {
+	ticker := time.NewTicker(d.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		_, err := d.DeliverDue(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Errorf("Failed to process webhook deliveries: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// DeliverDue makes one attempt at every delivery that is due and returns how
+// many were attempted. Tests can call it instead of running the workers.
+func (d *Dispatcher) DeliverDue(ctx context.Context) (int, error) {
+	attempted := 0
+	for ctx.Err() == nil {
+		now := d.Now()
+		delivery, err := d.Deliveries.ClaimDelivery(ctx, now, now.Add(d.Lease))
+		if errors.Is(err, repository.ErrNotFound) {
+			return attempted, nil
+		}
+		if err != nil {
+			return attempted, err
+		}
+
+		err = d.attempt(ctx, delivery)
+		if err != nil {
+			return attempted, err
+		}
+		attempted++
+	}
+
+	return attempted, ctx.Err()
+}
+
+// If the outcome cannot be stored, the lease runs out and the delivery is
+// attempted again
+func (d *Dispatcher) attempt(ctx context.Context, delivery *models.WebhookDelivery) error {
+	hook, err := d.Webhooks.GetOutgoingWebhook(ctx, delivery.WebhookID)
+	if errors.Is(err, repository.ErrNotFound) {
+		// Deleted while the delivery was claimed
+		delivery.Status = models.DeliveryDead
+		delivery.LastError = "webhook deleted"
+		return d.Deliveries.UpdateDelivery(ctx, *delivery)
+	}
+	if err != nil {
+		return err
+	}
+
+	statusCode, err := d.send(ctx, hook, delivery)
+	now := d.Now()
+	delivery.Attempts++
+	delivery.LastStatusCode = statusCode
+
+	switch {
+	case err == nil:
+		delivery.Status = models.DeliveryDelivered
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+	case delivery.Attempts >= d.MaxAttempts:
+		delivery.Status = models.DeliveryDead
+		delivery.LastError = err.Error()
+		log.Warnf("Webhook delivery %s to %s is dead after %d attempts: %v", delivery.ID.Hex(), hook.Name, delivery.Attempts, err)
+	default:
+		delivery.Status = models.DeliveryPending
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = now.Add(d.backoff(delivery.Attempts))
+	}
+
+	return d.Deliveries.UpdateDelivery(ctx, *delivery)
+}
+
+// Only a 2xx response counts as delivered
+func (d *Dispatcher) send(ctx context.Context, hook *models.OutgoingWebhook, delivery *models.WebhookDelivery) (int, error) {
+	payload := []byte(delivery.Payload)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// Every attempt is signed again, retries would be too old for receivers
+	// that check the timestamp otherwise
+	timestamp := time.Now().Unix()
+	req.Header.Set(SignatureHeader, utils.SignPayload(hook.Secret, timestamp, payload))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(EventHeader, string(delivery.Event))
+	req.Header.Set(DeliveryHeader, delivery.ID.Hex())
+
+	res, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	// Drained so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(res.Body, maxResponseBody))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("receiver responded with %s", res.Status)
+	}
+	return res.StatusCode, nil
+}
+
+// Wait before the next attempt after the given number of failed attempts
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	wait := d.BaseBackoff
+	for i := 1; i < attempts && wait < d.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, d.MaxBackoff)
+}
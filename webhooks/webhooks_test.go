@@ -0,0 +1,202 @@
+package webhooks_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/SomeSuperCoder/global-chat/models"
+	"github.com/SomeSuperCoder/global-chat/repository"
+	"github.com/SomeSuperCoder/global-chat/repository/memory"
+	"github.com/SomeSuperCoder/global-chat/utils"
+	"github.com/SomeSuperCoder/global-chat/webhooks"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const secret = "s3cret"
+
+// A receiver that checks every delivery and fails while fail is set
+type receiver struct {
+	t        *testing.T
+	server   *httptest.Server
+	fail     atomic.Bool
+	payloads chan webhooks.Payload
+}
+
+func newReceiver(t *testing.T) *receiver {
+	rec := &receiver{t: t, payloads: make(chan webhooks.Payload, 10)}
+	rec.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		timestamp, err := strconv.ParseInt(r.Header.Get(webhooks.TimestampHeader), 10, 64)
+		if err != nil {
+			t.Errorf("Invalid timestamp %q", r.Header.Get(webhooks.TimestampHeader))
+		}
+		if !utils.VerifySignature(secret, timestamp, body, r.Header.Get(webhooks.SignatureHeader)) {
+			t.Errorf("Invalid signature %q", r.Header.Get(webhooks.SignatureHeader))
+		}
+		if r.Header.Get(webhooks.EventHeader) != string(models.EventMessageCreated) {
+			t.Errorf("Unexpected event header %q", r.Header.Get(webhooks.EventHeader))
+		}
+		if r.Header.Get(webhooks.DeliveryHeader) == "" {
+			t.Error("Missing delivery header")
+		}
+
+		if rec.fail.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		var payload webhooks.Payload
+		err = json.Unmarshal(body, &payload)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		rec.payloads <- payload
+	}))
+	t.Cleanup(rec.server.Close)
+	return rec
+}
+
+// A dispatcher with a clock that only moves when the test says so
+func newDispatcher(t *testing.T, url string) (*webhooks.Dispatcher, *repository.Stores, *models.OutgoingWebhook, *time.Time) {
+	stores := memory.NewStores()
+	hook := &models.OutgoingWebhook{
+		ID:     bson.NewObjectID(),
+		Name:   "test",
+		URL:    url,
+		Secret: secret,
+		Events: []models.WebhookEvent{models.EventMessageCreated},
+	}
+	err := stores.Webhooks.CreateOutgoingWebhook(context.Background(), *hook)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	d := webhooks.New(stores.Webhooks, stores.Deliveries)
+	d.Now = func() time.Time { return now }
+	d.MaxAttempts = 4
+	d.BaseBackoff = time.Second
+	d.MaxBackoff = 3 * time.Second
+	d.Lease = time.Minute
+	return d, stores, hook, &now
+}
+
+func delivery(t *testing.T, stores *repository.Stores, hook *models.OutgoingWebhook) models.WebhookDelivery {
+	deliveries, count, err := stores.Deliveries.FindDeliveries(context.Background(), hook.ID, "", 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Fatalf("Expected one delivery, got %d", count)
+	}
+	return deliveries[0]
+}
+
+func deliverDue(t *testing.T, d *webhooks.Dispatcher, want int) {
+	attempted, err := d.DeliverDue(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempted != want {
+		t.Fatalf("Attempted %d deliveries, want %d", attempted, want)
+	}
+}
+
+func TestDelivery(t *testing.T) {
+	rec := newReceiver(t)
+	d, stores, hook, _ := newDispatcher(t, rec.server.URL)
+
+	err := d.Publish(context.Background(), models.EventMessageCreated, map[string]string{"text": "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deliverDue(t, d, 1)
+
+	payload := <-rec.payloads
+	if payload.Event != models.EventMessageCreated || payload.Data.(map[string]any)["text"] != "hi" {
+		t.Fatalf("Unexpected payload %+v", payload)
+	}
+
+	got := delivery(t, stores, hook)
+	if got.Status != models.DeliveryDelivered || got.Attempts != 1 || got.DeliveredAt == nil {
+		t.Fatalf("Unexpected delivery %+v", got)
+	}
+}
+
+func TestBackoffAndDeadLetter(t *testing.T) {
+	rec := newReceiver(t)
+	rec.fail.Store(true)
+	d, stores, hook, now := newDispatcher(t, rec.server.URL)
+
+	err := d.Publish(context.Background(), models.EventMessageCreated, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Doubled after every failure and capped at MaxBackoff
+	for attempt, wait := range []time.Duration{time.Second, 2 * time.Second, 3 * time.Second} {
+		deliverDue(t, d, 1)
+
+		got := delivery(t, stores, hook)
+		if got.Status != models.DeliveryPending || got.Attempts != attempt+1 || got.LastStatusCode != http.StatusInternalServerError {
+			t.Fatalf("Unexpected delivery after attempt %d: %+v", attempt+1, got)
+		}
+		if !got.NextAttemptAt.Equal(now.Add(wait)) {
+			t.Fatalf("Attempt %d backed off until %v, want %v", attempt+1, got.NextAttemptAt, now.Add(wait))
+		}
+
+		// Not due yet
+		*now = now.Add(wait - time.Millisecond)
+		deliverDue(t, d, 0)
+		*now = now.Add(time.Millisecond)
+	}
+
+	// The last attempt fails for good
+	deliverDue(t, d, 1)
+	got := delivery(t, stores, hook)
+	if got.Status != models.DeliveryDead || got.Attempts != d.MaxAttempts || got.LastError == "" {
+		t.Fatalf("Expected a dead delivery, got %+v", got)
+	}
+
+	*now = now.Add(time.Hour)
+	deliverDue(t, d, 0)
+}
+
+func TestLeaseReclaim(t *testing.T) {
+	rec := newReceiver(t)
+	d, stores, hook, now := newDispatcher(t, rec.server.URL)
+
+	err := d.Publish(context.Background(), models.EventMessageCreated, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A worker claims the delivery and dies before it is done
+	_, err = stores.Deliveries.ClaimDelivery(context.Background(), *now, now.Add(d.Lease))
+	if err != nil {
+		t.Fatal(err)
+	}
+	deliverDue(t, d, 0)
+
+	// Once the lease runs out another worker takes over
+	*now = now.Add(d.Lease)
+	deliverDue(t, d, 1)
+	<-rec.payloads
+
+	got := delivery(t, stores, hook)
+	if got.Status != models.DeliveryDelivered {
+		t.Fatalf("Expected a delivered delivery, got %+v", got)
+	}
+}